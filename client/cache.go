@@ -1,9 +1,13 @@
 package client
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"github.com/hackbeex/configcenter/local"
+	"github.com/hackbeex/configcenter/util"
 	"github.com/hackbeex/configcenter/util/log"
+	"github.com/pkg/errors"
 	"io/ioutil"
 	"os"
 	"sync"
@@ -16,11 +20,17 @@ type Cache struct {
 	dir      string
 	filename string
 	filepath string
+	key      []byte
 }
 
-func NewCache(filename string) *Cache {
+//the configs are encrypted by the key derived from the secret if not empty, e.g. the access key,
+//so that the secrets are not stored in plaintext
+func NewCache(filename string, secret string) *Cache {
 	cache := &Cache{
-		version: "1.0.0",
+		version: "1.1.0",
+	}
+	if secret != "" {
+		cache.key = cacheKey(secret)
 	}
 
 	dir := local.Conf.Server.CacheDir
@@ -53,9 +63,15 @@ func NewCache(filename string) *Cache {
 	return cache
 }
 
+func cacheKey(secret string) []byte {
+	sum := sha256.Sum256([]byte("configcenter cache:" + secret))
+	return sum[:]
+}
+
 type cacheData struct {
 	Version string            `json:"version"`
-	Data    map[string]string `json:"data"`
+	Data    map[string]string `json:"data,omitempty"`
+	Cipher  string            `json:"cipher,omitempty"` //the encrypted json of data
 }
 
 func (c *Cache) Store(data map[string]string) error {
@@ -63,6 +79,20 @@ func (c *Cache) Store(data map[string]string) error {
 		Version: c.version,
 		Data:    data,
 	}
+	if c.key != nil {
+		plaintext, err := json.Marshal(data)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		ciphertext, err := util.AesGcmEncrypt(c.key, plaintext)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		writeData.Data = nil
+		writeData.Cipher = base64.StdEncoding.EncodeToString(ciphertext)
+	}
 	d, err := json.Marshal(writeData)
 	if err != nil {
 		log.Error(err)
@@ -97,5 +127,26 @@ func (c *Cache) Load() (map[string]string, error) {
 		log.Error(err)
 		return readData.Data, err
 	}
+	if readData.Cipher == "" {
+		return readData.Data, nil
+	}
+
+	if c.key == nil {
+		return nil, errors.New("the cache is encrypted, need the key to load it")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(readData.Cipher)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	plaintext, err := util.AesGcmDecrypt(c.key, ciphertext)
+	if err != nil {
+		log.Error(err)
+		return nil, errors.Wrap(err, "decrypt cache")
+	}
+	if err := json.Unmarshal(plaintext, &readData.Data); err != nil {
+		log.Error(err)
+		return nil, err
+	}
 	return readData.Data, nil
 }
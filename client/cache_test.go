@@ -0,0 +1,49 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestCache(dir, secret string) *Cache {
+	c := &Cache{
+		version:  "1.1.0",
+		filepath: filepath.Join(dir, "test.cache.json"),
+	}
+	if secret != "" {
+		c.key = cacheKey(secret)
+	}
+	return c
+}
+
+func TestCacheEncrypt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := newTestCache(dir, "access-key")
+	if err := c.Store(map[string]string{"db.password": "p@ssw0rd"}); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := ioutil.ReadFile(c.filepath)
+	if strings.Contains(string(raw), "p@ssw0rd") {
+		t.Errorf("cache stores plaintext: %s", raw)
+	}
+
+	data, err := c.Load()
+	if err != nil || data["db.password"] != "p@ssw0rd" {
+		t.Errorf("Load: %v %v", data, err)
+	}
+
+	if _, err := newTestCache(dir, "other-key").Load(); err == nil {
+		t.Error("Load by other key should fail")
+	}
+	if _, err := newTestCache(dir, "").Load(); err == nil {
+		t.Error("Load without key should fail")
+	}
+}
@@ -8,12 +8,16 @@ import (
 	"github.com/hackbeex/configcenter/util/log"
 	"github.com/hackbeex/configcenter/util/response"
 	"github.com/pkg/errors"
+	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 )
 
+const accessKeyHeader = "X-Access-Key"
+
 type Client struct {
 	Host    string
 	Port    int
@@ -21,6 +25,7 @@ type Client struct {
 	App     string
 	Env     com.EnvType
 
+	accessKey  string
 	instanceId string
 
 	discover discoverInfo
@@ -30,6 +35,10 @@ type Client struct {
 	cache               *Cache
 	watchConfigInterval time.Duration
 	listens             *ListenTable
+	documents           sync.Map
+
+	bindLock sync.Mutex
+	binds    []*Binding
 }
 
 type discoverInfo struct {
@@ -51,6 +60,7 @@ type Config struct {
 	ClientCluster string
 	ClientApp     string
 	ClientEnv     com.EnvType
+	AccessKey     string //the access key of the app
 	DiscoverHost  string
 	DiscoverPort  int
 }
@@ -63,13 +73,15 @@ func New(cf *Config) *Client {
 		Env:     cf.ClientEnv,
 		App:     cf.ClientApp,
 		Cluster: cf.ClientCluster,
+
+		accessKey: cf.AccessKey,
 		discover: discoverInfo{
 			Host: cf.DiscoverHost,
 			Port: cf.DiscoverPort,
 		},
 		config:  NewConfigTable(),
 		listens: NewListenTable(),
-		cache:   NewCache(filename),
+		cache:   NewCache(filename, cf.AccessKey),
 	}
 }
 
@@ -177,10 +189,17 @@ func (c *Client) DoServerExit() error {
 		"instance_id": c.instanceId,
 	})
 	url := fmt.Sprintf("http://%s:%d/api/v1/client/exit", c.server.Host, c.server.Port)
-	_, err := util.HttpPostJson(url, data)
+	_, err := c.postServer(url, data)
 	if err != nil {
 		log.Error(err)
 		return err
 	}
 	return nil
 }
+
+//request the config server with the access key
+func (c *Client) postServer(url string, data []byte) (*http.Response, error) {
+	return util.HttpPostJsonWithHeader(url, data, map[string]string{
+		accessKeyHeader: c.accessKey,
+	})
+}
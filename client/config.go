@@ -35,6 +35,15 @@ func (c *Client) GetConfig(key string, defaultVal string) (string, bool) {
 	return item.Value, ok
 }
 
+//the raw document of the yaml, json, toml or xml namespace, its configs are flattened to dotted keys
+func (c *Client) GetContent(namespace string) (string, bool) {
+	content, ok := c.documents.Load(namespace)
+	if !ok {
+		return "", ok
+	}
+	return content.(string), ok
+}
+
 //if key == "", listen all config change
 func (c *Client) ListenConfig(key string, callback ListenCallback) {
 	c.listens.AddCallback(key, callback)
@@ -61,11 +70,15 @@ func (c *Client) initConfig() error {
 			})
 		}
 	} else {
+		for name, content := range res.Documents {
+			c.documents.Store(name, content)
+		}
 		config := map[string]string{}
 		for _, item := range res.List {
 			c.config.Store(item.Key, &Item{
-				Key:   item.Key,
-				Value: item.Value,
+				Key:        item.Key,
+				Value:      item.Value,
+				namespaces: item.namespaces,
 			})
 			config[item.Key] = item.Value
 		}
@@ -80,12 +93,18 @@ func (c *Client) initConfig() error {
 }
 
 type ConfigListResp struct {
-	List []Item `json:"list"`
+	List      []Item            `json:"list"`
+	Documents map[string]string `json:"documents"`
 }
 
 func (c *Client) fetchConfigList() (*ConfigListResp, error) {
 	type configListItem struct {
-		Items []Item `json:"items"`
+		Namespace struct {
+			Name   string `json:"name"`
+			Format string `json:"format"`
+		} `json:"namespace"`
+		Items   []Item `json:"items"`
+		Content string `json:"content"`
 	}
 	type configListByAppResp struct {
 		List []configListItem `json:"list"`
@@ -96,15 +115,18 @@ func (c *Client) fetchConfigList() (*ConfigListResp, error) {
 	}
 	var fullResp httpResp
 	var listResp = &ConfigListResp{
-		List: []Item{},
+		List:      []Item{},
+		Documents: map[string]string{},
 	}
 
 	data, _ := json.Marshal(map[string]string{
 		"app":         c.App,
+		"cluster":     c.Cluster,
+		"env":         string(c.Env),
 		"instance_id": c.instanceId,
 	})
 	url := fmt.Sprintf("http://%s:%d/api/v1/client/config/list", c.server.Host, c.server.Port)
-	res, err := util.HttpPostJson(url, data)
+	res, err := c.postServer(url, data)
 	if err != nil {
 		log.Error(err)
 		return listResp, err
@@ -116,21 +138,43 @@ func (c *Client) fetchConfigList() (*ConfigListResp, error) {
 	}
 	if fullResp.Code != 200 {
 		log.Error(fullResp.Message)
-		return listResp, err
+		return listResp, errors.New(fullResp.Message)
 	}
 
+	//the key in several namespaces takes the value of the last one
+	index := map[string]int{}
 	for _, item := range fullResp.Data.List {
 		for _, v := range item.Items {
-			listResp.List = append(listResp.List, v)
+			i, ok := index[v.Key]
+			if !ok {
+				index[v.Key] = len(listResp.List)
+				v.namespaces = []string{item.Namespace.Name}
+				listResp.List = append(listResp.List, v)
+				continue
+			}
+			listResp.List[i].Value = v.Value
+			listResp.List[i].namespaces = listResp.List[i].providedBy(item.Namespace.Name)
+		}
+		if item.Namespace.Format != "" && item.Namespace.Format != "properties" {
+			listResp.Documents[item.Namespace.Name] = item.Content
 		}
 	}
 
 	return listResp, nil
 }
 
+type ConfigChange struct {
+	NamespaceId string                `json:"namespace_id"`
+	Namespace   string                `json:"namespace"`
+	ReleaseId   string                `json:"release_id"`
+	Configs     map[com.OpType][]Item `json:"configs"`
+	Content     string                `json:"content"`
+}
+
 type WatchConfigResp struct {
 	InstanceId string                   `json:"instance_id"`
 	EventType  com.ConfigWatchEventType `json:"event_type"`
+	Changes    []ConfigChange           `json:"changes"`
 }
 
 func (c *Client) fetchConfigEvent() (*WatchConfigResp, error) {
@@ -149,7 +193,7 @@ func (c *Client) fetchConfigEvent() (*WatchConfigResp, error) {
 		"env":     c.Env,
 	})
 	url := fmt.Sprintf("http://%s:%d/api/v1/client/config/watch", c.server.Host, c.server.Port)
-	res, err := util.HttpPostJson(url, req)
+	res, err := c.postServer(url, req)
 	if err != nil {
 		log.Error(err)
 		return resp, err
@@ -202,7 +246,10 @@ func (c *Client) watchConfig() {
 		}
 		c.watchConfigInterval = 0
 
-		if cf.EventType == com.CwRefreshAll {
+		switch cf.EventType {
+		case com.CwConfigChange:
+			c.applyConfigChange(cf.Changes)
+		case com.CwRefreshAll:
 			if err := c.refreshConfig(); err != nil {
 				continue
 			}
@@ -210,6 +257,103 @@ func (c *Client) watchConfig() {
 	}
 }
 
+//apply the released changes only, no need to fetch all configs again,
+//unless the changed key is also provided by another namespace
+func (c *Client) applyConfigChange(changes []ConfigChange) {
+	if c.mergeConfigChange(changes) {
+		if err := c.refreshConfig(); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+//merge the changes into the configs, true if all configs need to be fetched again. the value of the key in
+//several namespaces depends on the order of them, which is only known by fetching all of them
+func (c *Client) mergeConfigChange(changes []ConfigChange) bool {
+	var isChange = false
+	var refresh = false
+	for _, change := range changes {
+		log.Infof("apply config change of namespace[%s], release: %s", change.Namespace, change.ReleaseId)
+		if change.Content != "" {
+			c.documents.Store(change.Namespace, change.Content)
+		}
+		for _, item := range change.Configs[com.OpCreate] {
+			old, _ := c.config.Load(item.Key)
+			if len(old.others(change.Namespace)) > 0 {
+				refresh = true
+				continue
+			}
+			isChange = true
+			c.config.Store(item.Key, &Item{
+				Key:        item.Key,
+				Value:      item.Value,
+				namespaces: old.providedBy(change.Namespace),
+			})
+			c.listens.Call(item.Key, &CallbackParam{
+				Key:    item.Key,
+				NewVal: item.Value,
+				OldVal: item.Value,
+				OpType: com.OpCreate,
+			}, true)
+		}
+		for _, item := range change.Configs[com.OpUpdate] {
+			oldVal := item.Value
+			old, ok := c.config.Load(item.Key)
+			if len(old.others(change.Namespace)) > 0 {
+				refresh = true
+				continue
+			}
+			isChange = true
+			if ok {
+				oldVal = old.Value
+			}
+			c.config.Store(item.Key, &Item{
+				Key:        item.Key,
+				Value:      item.Value,
+				namespaces: old.providedBy(change.Namespace),
+			})
+			c.listens.Call(item.Key, &CallbackParam{
+				Key:    item.Key,
+				NewVal: item.Value,
+				OldVal: oldVal,
+				OpType: com.OpUpdate,
+			}, true)
+		}
+		for _, item := range change.Configs[com.OpDelete] {
+			old, ok := c.config.Load(item.Key)
+			if !ok {
+				//the secret is deleted from the client without the access to it, which may never have held it
+				continue
+			}
+			if others := old.others(change.Namespace); len(others) > 0 {
+				refresh = true
+				c.config.Store(item.Key, &Item{
+					Key:        item.Key,
+					Value:      old.Value,
+					namespaces: others,
+				})
+				continue
+			}
+			isChange = true
+			c.config.Delete(item.Key)
+			c.listens.Call(item.Key, &CallbackParam{
+				Key:    item.Key,
+				NewVal: item.Value,
+				OldVal: item.Value,
+				OpType: com.OpDelete,
+			}, true)
+		}
+	}
+	if isChange {
+		c.rebind()
+		all, _ := c.GetAllConfig()
+		if err := c.cache.Store(all.List); err != nil {
+			log.Error(err)
+		}
+	}
+	return refresh
+}
+
 func (c *Client) refreshConfig() error {
 	res, err := c.fetchConfigList()
 	if err != nil {
@@ -222,12 +366,17 @@ func (c *Client) refreshConfig() error {
 		return err
 	}
 
+	for name, content := range res.Documents {
+		c.documents.Store(name, content)
+	}
+
 	var isChange = false
 	newMap := map[string]string{}
 	for _, item := range res.List {
 		c.config.Store(item.Key, &Item{
-			Key:   item.Key,
-			Value: item.Value,
+			Key:        item.Key,
+			Value:      item.Value,
+			namespaces: item.namespaces,
 		})
 		newMap[item.Key] = item.Value
 	}
@@ -262,6 +411,7 @@ func (c *Client) refreshConfig() error {
 		}
 	}
 	if isChange {
+		c.rebind()
 		if err := c.cache.Store(newMap); err != nil {
 			log.Error(err)
 		}
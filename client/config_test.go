@@ -0,0 +1,56 @@
+package client
+
+import (
+	"github.com/hackbeex/configcenter/util/com"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMergeConfigChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := newTestClient(nil)
+	c.cache = newTestCache(dir, "")
+	c.config.Store("port", &Item{Key: "port", Value: "8080", namespaces: []string{"application"}})
+	c.config.Store("host", &Item{Key: "host", Value: "db", namespaces: []string{"application", "db"}})
+	var called []string
+	c.listens.AddCallback("port", func(p *CallbackParam) {
+		called = append(called, p.Key)
+	})
+	c.listens.AddCallback("host", func(p *CallbackParam) {
+		called = append(called, p.Key)
+	})
+
+	//the key only in the changed namespace is applied
+	refresh := c.mergeConfigChange([]ConfigChange{{
+		Namespace: "application",
+		Configs:   map[com.OpType][]Item{com.OpUpdate: {{Key: "port", Value: "9090"}}},
+	}})
+	if port, _ := c.config.Load("port"); refresh || port.Value != "9090" {
+		t.Errorf("update of the single namespace: got %v %v", port.Value, refresh)
+	}
+
+	//the key also in another namespace waits for the refresh, the value of the other may take precedence
+	refresh = c.mergeConfigChange([]ConfigChange{{
+		Namespace: "application",
+		Configs:   map[com.OpType][]Item{com.OpUpdate: {{Key: "host", Value: "localhost"}}},
+	}})
+	if host, _ := c.config.Load("host"); !refresh || host.Value != "db" {
+		t.Errorf("update of the key in several namespaces: got %v %v", host.Value, refresh)
+	}
+	refresh = c.mergeConfigChange([]ConfigChange{{
+		Namespace: "cache",
+		Configs:   map[com.OpType][]Item{com.OpCreate: {{Key: "port", Value: "6379"}}},
+	}})
+	if port, _ := c.config.Load("port"); !refresh || port.Value != "9090" {
+		t.Errorf("create of the key in another namespace: got %v %v", port.Value, refresh)
+	}
+	if len(called) != 1 || called[0] != "port" {
+		t.Errorf("callbacks: got %v", called)
+	}
+}
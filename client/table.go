@@ -7,6 +7,31 @@ import (
 type Item struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
+
+	namespaces []string //the namespaces providing the key, unknown if loaded from the cache
+}
+
+//the namespaces providing the key with the namespace added
+func (i *Item) providedBy(namespace string) []string {
+	var namespaces []string
+	if i != nil {
+		namespaces = i.others(namespace)
+	}
+	return append(namespaces, namespace)
+}
+
+//the namespaces other than the one providing the key
+func (i *Item) others(namespace string) []string {
+	var namespaces []string
+	if i == nil {
+		return namespaces
+	}
+	for _, name := range i.namespaces {
+		if name != namespace {
+			namespaces = append(namespaces, name)
+		}
+	}
+	return namespaces
 }
 
 type ConfigTable struct {
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestItemNamespaces(t *testing.T) {
+	var missing *Item
+	if got := missing.providedBy("application"); !reflect.DeepEqual(got, []string{"application"}) {
+		t.Errorf("providedBy of missing item: got %v", got)
+	}
+	if got := missing.others("application"); len(got) != 0 {
+		t.Errorf("others of missing item: got %v", got)
+	}
+
+	item := &Item{Key: "port", Value: "8080", namespaces: []string{"application", "db"}}
+	if got := item.providedBy("db"); !reflect.DeepEqual(got, []string{"application", "db"}) {
+		t.Errorf("providedBy again: got %v", got)
+	}
+	if got := item.providedBy("redis"); !reflect.DeepEqual(got, []string{"application", "db", "redis"}) {
+		t.Errorf("providedBy new namespace: got %v", got)
+	}
+	if got := item.others("db"); !reflect.DeepEqual(got, []string{"application"}) {
+		t.Errorf("others: got %v", got)
+	}
+
+	//loaded from the cache, the namespaces are unknown
+	cached := &Item{Key: "port", Value: "8080"}
+	if got := cached.others("db"); len(got) != 0 {
+		t.Errorf("others of cached item: got %v", got)
+	}
+}
@@ -0,0 +1,312 @@
+package client
+
+import (
+	"encoding/json"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/pkg/errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//the bool result is false if the key not exists or the value can not be parsed, then defaultVal returns
+func (c *Client) GetInt(key string, defaultVal int) (int, bool) {
+	val, ok := c.GetConfig(key, "")
+	if !ok {
+		return defaultVal, false
+	}
+	num, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil {
+		log.Warnf("config[%s] is not int: %s", key, err)
+		return defaultVal, false
+	}
+	return num, true
+}
+
+func (c *Client) GetBool(key string, defaultVal bool) (bool, bool) {
+	val, ok := c.GetConfig(key, "")
+	if !ok {
+		return defaultVal, false
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(val))
+	if err != nil {
+		log.Warnf("config[%s] is not bool: %s", key, err)
+		return defaultVal, false
+	}
+	return b, true
+}
+
+func (c *Client) GetFloat(key string, defaultVal float64) (float64, bool) {
+	val, ok := c.GetConfig(key, "")
+	if !ok {
+		return defaultVal, false
+	}
+	num, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+	if err != nil {
+		log.Warnf("config[%s] is not float: %s", key, err)
+		return defaultVal, false
+	}
+	return num, true
+}
+
+func (c *Client) GetDuration(key string, defaultVal time.Duration) (time.Duration, bool) {
+	val, ok := c.GetConfig(key, "")
+	if !ok {
+		return defaultVal, false
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(val))
+	if err != nil {
+		log.Warnf("config[%s] is not duration: %s", key, err)
+		return defaultVal, false
+	}
+	return d, true
+}
+
+//the value can be a json array or comma separated
+func (c *Client) GetStringSlice(key string, defaultVal []string) ([]string, bool) {
+	val, ok := c.GetConfig(key, "")
+	if !ok {
+		return defaultVal, false
+	}
+	list, err := parseStringSlice(val)
+	if err != nil {
+		log.Warnf("config[%s] is not string slice: %s", key, err)
+		return defaultVal, false
+	}
+	return list, true
+}
+
+func (c *Client) GetJSON(key string, v interface{}) error {
+	val, ok := c.GetConfig(key, "")
+	if !ok {
+		return errors.Errorf("config[%s] not exists", key)
+	}
+	if err := json.Unmarshal([]byte(val), v); err != nil {
+		return errors.Wrapf(err, "config[%s] is not json", key)
+	}
+	return nil
+}
+
+//Binding keeps the struct bound to the configs up to date. a fresh struct is populated when the configs change
+//and swapped in, so the struct got by Load is never written after, and the fields of deleted keys go back
+//to the default or zero value.
+type Binding struct {
+	client   *Client
+	typ      reflect.Type
+	update   sync.Mutex //the refreshes run one by one, so an older struct never replaces a newer one
+	lock     sync.RWMutex
+	value    interface{}
+	target   *atomic.Value //the value of the caller swapped with the fresh struct too
+	onChange []func(v interface{})
+}
+
+//the current bound struct, a pointer to the struct type bound, do not modify it
+func (b *Binding) Load() interface{} {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.value
+}
+
+//call fn with the new bound struct after each change
+func (b *Binding) OnChange(fn func(v interface{})) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.onChange = append(b.onChange, fn)
+}
+
+func (b *Binding) refresh() error {
+	b.update.Lock()
+	defer b.update.Unlock()
+
+	v := reflect.New(b.typ).Interface()
+	if err := b.client.bind(v); err != nil {
+		return err
+	}
+	b.lock.Lock()
+	b.value = v
+	callbacks := b.onChange
+	b.lock.Unlock()
+	if b.target != nil {
+		b.target.Store(v)
+	}
+
+	for _, fn := range callbacks {
+		fn(v)
+	}
+	return nil
+}
+
+//populate the struct by tag `config:"key"`, and `default:"value"` is used if the key not exists.
+//nested struct field with config tag uses the tag as the key prefix, e.g. `config:"db"` for db.host.
+//
+//NOTE: a pointer to struct is populated only ONCE and never changes after, read the up to date values by
+//the returned binding. to have the values kept up to date by your own, pass an *atomic.Value which holds
+//a pointer to the struct, e.g.
+//	var conf atomic.Value
+//	conf.Store(&Conf{})
+//	c.Bind(&conf)
+//	port := conf.Load().(*Conf).Port
+//a fresh struct is populated and stored in it on every change.
+func (c *Client) Bind(v interface{}) (*Binding, error) {
+	b := &Binding{
+		client: c,
+	}
+	if av, ok := v.(*atomic.Value); ok {
+		cur := av.Load()
+		rt := reflect.TypeOf(cur)
+		if cur == nil || rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Struct {
+			return nil, errors.New("bind atomic value must hold a pointer to struct")
+		}
+		b.typ = rt.Elem()
+		b.target = av
+	} else {
+		if err := c.bind(v); err != nil {
+			return nil, err
+		}
+		b.typ = reflect.TypeOf(v).Elem()
+	}
+	if err := b.refresh(); err != nil {
+		return nil, err
+	}
+	c.bindLock.Lock()
+	c.binds = append(c.binds, b)
+	c.bindLock.Unlock()
+	return b, nil
+}
+
+func (c *Client) rebind() {
+	c.bindLock.Lock()
+	binds := c.binds
+	c.bindLock.Unlock()
+	for _, b := range binds {
+		if err := b.refresh(); err != nil {
+			log.Error("rebind config fail: ", err)
+		}
+	}
+}
+
+func (c *Client) bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("bind target must be a pointer to struct")
+	}
+	return c.bindStruct(rv.Elem(), "")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (c *Client) bindStruct(rv reflect.Value, prefix string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		key, ok := field.Tag.Lookup("config")
+		if !ok || key == "-" || field.PkgPath != "" {
+			continue
+		}
+		key = prefix + key
+
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Struct && field.Type != durationType {
+			if err := c.bindStruct(fv, key+"."); err != nil {
+				return err
+			}
+			continue
+		}
+
+		val, ok := c.GetConfig(key, "")
+		if !ok {
+			val, ok = field.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+		}
+		if err := setField(fv, val); err != nil {
+			return errors.Wrapf(err, "bind config[%s] to field %s", key, field.Name)
+		}
+	}
+	return nil
+}
+
+func setField(fv reflect.Value, val string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(strings.TrimSpace(val))
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(val))
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		num, err := strconv.ParseInt(strings.TrimSpace(val), 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(num)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		num, err := strconv.ParseUint(strings.TrimSpace(val), 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(num)
+	case reflect.Float32, reflect.Float64:
+		num, err := strconv.ParseFloat(strings.TrimSpace(val), fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(num)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.String {
+			list, err := parseStringSlice(val)
+			if err != nil {
+				return err
+			}
+			fv.Set(reflect.ValueOf(list).Convert(fv.Type()))
+			return nil
+		}
+		return setJsonField(fv, val)
+	default:
+		return setJsonField(fv, val)
+	}
+	return nil
+}
+
+func setJsonField(fv reflect.Value, val string) error {
+	ptr := reflect.New(fv.Type())
+	if err := json.Unmarshal([]byte(val), ptr.Interface()); err != nil {
+		return err
+	}
+	fv.Set(ptr.Elem())
+	return nil
+}
+
+func parseStringSlice(val string) ([]string, error) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return []string{}, nil
+	}
+	if strings.HasPrefix(val, "[") {
+		var list []string
+		if err := json.Unmarshal([]byte(val), &list); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+	list := strings.Split(val, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+	return list, nil
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(configs map[string]string) *Client {
+	c := &Client{
+		config:  NewConfigTable(),
+		listens: NewListenTable(),
+	}
+	for k, v := range configs {
+		c.config.Store(k, &Item{Key: k, Value: v})
+	}
+	return c
+}
+
+func TestTypedGetter(t *testing.T) {
+	c := newTestClient(map[string]string{
+		"port":    "8080",
+		"debug":   "true",
+		"ratio":   "0.5",
+		"timeout": "3s",
+		"hosts":   "a, b,c",
+		"bad":     "x",
+	})
+	if v, ok := c.GetInt("port", 0); !ok || v != 8080 {
+		t.Errorf("GetInt: %v %v", v, ok)
+	}
+	if v, ok := c.GetBool("debug", false); !ok || !v {
+		t.Errorf("GetBool: %v %v", v, ok)
+	}
+	if v, ok := c.GetFloat("ratio", 0); !ok || v != 0.5 {
+		t.Errorf("GetFloat: %v %v", v, ok)
+	}
+	if v, ok := c.GetDuration("timeout", 0); !ok || v != 3*time.Second {
+		t.Errorf("GetDuration: %v %v", v, ok)
+	}
+	if v, ok := c.GetStringSlice("hosts", nil); !ok || len(v) != 3 || v[1] != "b" {
+		t.Errorf("GetStringSlice: %v %v", v, ok)
+	}
+	if v, ok := c.GetInt("bad", 7); ok || v != 7 {
+		t.Errorf("GetInt of bad value: %v %v", v, ok)
+	}
+	if v, ok := c.GetInt("missing", 9); ok || v != 9 {
+		t.Errorf("GetInt of missing key: %v %v", v, ok)
+	}
+}
+
+func TestBind(t *testing.T) {
+	type DB struct {
+		Host string `config:"host" default:"localhost"`
+		Port int    `config:"port" default:"3306"`
+	}
+	type Conf struct {
+		Name    string            `config:"name"`
+		Timeout time.Duration     `config:"timeout"`
+		Tags    []string          `config:"tags"`
+		Labels  map[string]string `config:"labels"`
+		DB      DB                `config:"db"`
+	}
+
+	c := newTestClient(map[string]string{
+		"name":    "demo",
+		"timeout": "1m",
+		"tags":    `["x","y"]`,
+		"labels":  `{"k":"v"}`,
+		"db.host": "10.0.0.1",
+	})
+	var conf Conf
+	b, err := c.Bind(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Name != "demo" || conf.Timeout != time.Minute || len(conf.Tags) != 2 || conf.Labels["k"] != "v" {
+		t.Errorf("bind: %+v", conf)
+	}
+	if conf.DB.Host != "10.0.0.1" || conf.DB.Port != 3306 {
+		t.Errorf("bind nested: %+v", conf.DB)
+	}
+
+	var changed *Conf
+	b.OnChange(func(v interface{}) {
+		changed = v.(*Conf)
+	})
+	c.config.Store("db.port", &Item{Key: "db.port", Value: "3307"})
+	c.config.Delete("db.host")
+	c.config.Delete("tags")
+	c.rebind()
+	cur := b.Load().(*Conf)
+	if cur.DB.Port != 3307 || changed != cur {
+		t.Errorf("rebind: %+v", cur.DB)
+	}
+	if cur.DB.Host != "localhost" || cur.Tags != nil {
+		t.Errorf("rebind of deleted keys: %+v", cur)
+	}
+	if conf.DB.Port != 3306 {
+		t.Errorf("the struct passed to bind should not change: %+v", conf.DB)
+	}
+
+	if _, err := c.Bind(conf); err == nil {
+		t.Error("bind non pointer should fail")
+	}
+}
+
+//run with -race, the bound struct is read while the configs change
+func TestBindConcurrent(t *testing.T) {
+	type Conf struct {
+		Port int `config:"port"`
+	}
+	c := newTestClient(map[string]string{"port": "1"})
+	b, err := c.Bind(&Conf{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		for i := 2; i < 100; i++ {
+			c.config.Store("port", &Item{Key: "port", Value: strconv.Itoa(i)})
+			c.rebind()
+		}
+		close(done)
+	}()
+	last := 0
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		port := b.Load().(*Conf).Port
+		if port < last {
+			t.Fatalf("port goes back from %d to %d", last, port)
+		}
+		last = port
+	}
+	if port := b.Load().(*Conf).Port; port != 99 {
+		t.Errorf("port after all changes: %d", port)
+	}
+}
+
+func TestBindAtomic(t *testing.T) {
+	type Conf struct {
+		Port int `config:"port" default:"80"`
+	}
+	c := newTestClient(map[string]string{"port": "8080"})
+	var conf atomic.Value
+	conf.Store(&Conf{})
+	b, err := c.Bind(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port := conf.Load().(*Conf).Port; port != 8080 {
+		t.Errorf("bind: %d", port)
+	}
+
+	c.config.Store("port", &Item{Key: "port", Value: "9090"})
+	c.rebind()
+	cur := conf.Load().(*Conf)
+	if cur.Port != 9090 || b.Load() != cur {
+		t.Errorf("rebind: %d", cur.Port)
+	}
+
+	var empty atomic.Value
+	if _, err := c.Bind(&empty); err == nil {
+		t.Error("bind empty atomic value should fail")
+	}
+}
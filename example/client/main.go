@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/hackbeex/configcenter/client"
 	"github.com/hackbeex/configcenter/util/log"
+	"os"
 )
 
 func main() {
@@ -12,6 +13,7 @@ func main() {
 		ClientCluster: "default",
 		ClientApp:     "test_app",
 		ClientEnv:     "develop",
+		AccessKey:     os.Getenv("CC_ACCESS_KEY"),
 		DiscoverHost:  "127.0.0.1",
 		DiscoverPort:  9310,
 	})
@@ -5,6 +5,7 @@ import (
 	"gopkg.in/yaml.v2"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -46,6 +47,21 @@ type LocalConf struct {
 			Addr     string `yaml:"Addr"`
 			DBName   string `yaml:"DBName"`
 		} `yaml:"Mysql"`
+
+		Auth struct {
+			Secret      string `yaml:"Secret"`
+			TokenExpire int    `yaml:"TokenExpire"`
+		} `yaml:"Auth"`
+
+		Kms struct {
+			Provider      string `yaml:"Provider"`
+			MasterKeyFile string `yaml:"MasterKeyFile"`
+		} `yaml:"Kms"`
+
+		Peer struct {
+			Token   string `yaml:"Token"`
+			Timeout int    `yaml:"Timeout"`
+		} `yaml:"Peer"`
 	} `yaml:"Server"`
 }
 
@@ -59,6 +75,7 @@ func ReadConfig(path ...string) *LocalConf {
 	var config LocalConf
 	var isProductionEnv bool
 	var configFilePath string
+	var isDefaultPath bool
 
 	// env IS_PRODUCTION
 	if os.Getenv("IS_PRODUCTION") == "1" {
@@ -74,6 +91,7 @@ func ReadConfig(path ...string) *LocalConf {
 		configFilePath = os.Getenv("CONFIG_PATH")
 		if configFilePath == "" {
 			configFilePath = defaultLogPath
+			isDefaultPath = true
 		}
 	} else {
 		log.Fatal("only one path could be passed in")
@@ -91,6 +109,9 @@ func ReadConfig(path ...string) *LocalConf {
 			log.Fatal(fmt.Sprintf("lack of config file path, %s", configFilePath))
 		}
 	}
+	if isDefaultPath {
+		configFilePath = findConfigFile(configFilePath)
+	}
 	f, err = os.OpenFile(configFilePath, os.O_RDONLY, 0666)
 	defer func(fd *os.File) {
 		if err := f.Close(); err != nil {
@@ -118,6 +139,30 @@ func ReadConfig(path ...string) *LocalConf {
 	return &config
 }
 
+//the default config file is looked up in the parents of the working dir if not exists,
+//so the packages in any depth can be run or tested without CONFIG_PATH
+func findConfigFile(path string) string {
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	name := filepath.Base(path)
+	for {
+		found := filepath.Join(dir, name)
+		if _, err := os.Stat(found); err == nil {
+			return found
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		dir = parent
+	}
+}
+
 func init() {
 	Conf = ReadConfig()
 }
@@ -0,0 +1,522 @@
+/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+/*!40101 SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS */;
+/*!40101 SET @OLD_COLLATION_CONNECTION=@@COLLATION_CONNECTION */;
+/*!40101 SET NAMES utf8 */;
+/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;
+/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;
+/*!40111 SET @OLD_SQL_NOTES=@@SQL_NOTES, SQL_NOTES=0 */;
+
+# Create Database
+# ------------------------------------------------------------
+CREATE DATABASE IF NOT EXISTS cc_config DEFAULT CHARACTER SET = utf8mb4;
+
+Use cc_config;
+
+# Dump of table app
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS app;
+
+CREATE TABLE app (
+  id CHAR(36) NOT NULL COMMENT '',
+  name VARCHAR(64) NOT NULL COMMENT 'uniqueness name in cluster',
+  comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_name (name),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='';
+
+
+
+# Dump of table namespace
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS namespace;
+
+CREATE TABLE namespace (
+  id CHAR(36) NOT NULL COMMENT '',
+  name VARCHAR(64) NOT NULL COMMENT 'unique in the cluster',
+  app_id CHAR(36) NOT NULL  COMMENT '',
+  cluster_id CHAR(36) NOT NULL COMMENT '',
+  format VARCHAR(16) NOT NULL DEFAULT 'properties' COMMENT 'properties, yaml, json, toml or xml, document format is released as a whole content item',
+  is_public TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'public namespace can be associated by other apps',
+  public_namespace_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the associated public namespace',
+  need_approval TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'release must be approved by another user',
+  lock_by CHAR(36) NOT NULL DEFAULT '' COMMENT 'the user editing the unreleased items, released on publish or discard',
+  lock_time INT NOT NULL DEFAULT 0 COMMENT '',
+  version INT NOT NULL DEFAULT 0 COMMENT 'increased by every write of the items and the release',
+  comment VARCHAR(64) NOT NULL DEFAULT '' COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  delete_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the id when deleted, keep the name unique among the undeleted',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  UNIQUE KEY uk_cluster_name (cluster_id,name,delete_id),
+  KEY idx_name (name),
+  KEY idx_app_id (app_id),
+  KEY idx_public_namespace_id (public_namespace_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='';
+
+
+
+# Dump of table record
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS record;
+
+CREATE TABLE record (
+  id INT(10) unsigned NOT NULL AUTO_INCREMENT COMMENT '',
+  table_name VARCHAR(32) NOT NULL COMMENT 'db table name',
+  table_id VARCHAR(36) NOT NULL COMMENT '',
+  op_type VARCHAR(32) NOT NULL COMMENT 'operation type',
+  comment VARCHAR(255) DEFAULT '' COMMENT '',
+  app_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the app which the row belongs to',
+  client_ip VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'of the portal request',
+  user_agent VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'of the portal request',
+  request_id VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'of the portal request',
+  before_data LONGTEXT NULL COMMENT 'json of the row before the operation',
+  after_data LONGTEXT NULL COMMENT 'json of the row after the operation',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_table (table_name,table_id),
+  KEY idx_app_time (app_id,create_time),
+  KEY idx_create_by (create_by),
+  KEY idx_create_time (create_time),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='db operation record';
+
+
+
+# Dump of table cluster
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS cluster;
+
+CREATE TABLE cluster (
+  id CHAR(36) NOT NULL COMMENT '',
+  name VARCHAR(64) NOT NULL COMMENT 'unique in the app',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  delete_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the id when deleted, keep the name unique among the undeleted',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  UNIQUE KEY uk_app_name (app_id,name,delete_id),
+  KEY idx_name (name),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='';
+
+
+
+# Dump of table commit
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS commit;
+
+CREATE TABLE commit (
+  id CHAR(36) NOT NULL COMMENT '',
+  namespace_id CHAR(36) NOT NULL COMMENT '',
+  change_sets LONGTEXT NOT NULL COMMENT '',
+  message VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'what the change is for',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_namespace_id (namespace_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='commit history';
+
+
+
+# Dump of table instance
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS instance;
+
+CREATE TABLE instance (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  cluster_id CHAR(36) NOT NULL COMMENT '',
+  host VARCHAR(64) NOT NULL COMMENT '',
+  port INT NOT NULL COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_group_host_port (host,port),
+  KEY idx_group_app_cluster (app_id,cluster_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='app instance';
+
+
+
+# Dump of table instance_release
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS instance_release;
+
+CREATE TABLE instance_release (
+  id CHAR(36) NOT NULL COMMENT '',
+  instance_id CHAR(36) NOT NULL COMMENT '',
+  namespace_id CHAR(36) NOT NULL COMMENT '',
+  release_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the release which the instance runs',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_instance_id (instance_id),
+  KEY idx_namespace_release (namespace_id,release_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='instance release record';
+
+
+
+# Dump of table item
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS item;
+
+CREATE TABLE item (
+  id CHAR(36) NOT NULL COMMENT '',
+  namespace_id CHAR(36) NOT NULL COMMENT '',
+  `key` VARCHAR(128) NOT NULL COMMENT 'config key',
+  value LONGTEXT NOT NULL COMMENT 'config value',
+  value_type VARCHAR(16) NOT NULL DEFAULT 'string' COMMENT 'string,int,float,bool,duration,json,yaml',
+  value_rule TEXT NULL COMMENT 'json of value constraints: pattern,min,max,schema',
+  comment VARCHAR(500) DEFAULT '' COMMENT '',
+  order_num INT(10) UNSIGNED DEFAULT 0 COMMENT '',
+  is_secret TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'the value is envelope encrypted as enc:v1:<manager>:<data key>:<ciphertext>',
+  version INT NOT NULL DEFAULT 1 COMMENT 'increased by every update, the write based on a stale read is refused',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_namespace_id (namespace_id),
+  KEY idx_key (`key`),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='config item';
+
+
+
+# Dump of table release
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS `release`;
+
+CREATE TABLE `release` (
+  id CHAR(36) NOT NULL COMMENT '',
+  name VARCHAR(64) NOT NULL COMMENT 'release name',
+  comment VARCHAR(255) DEFAULT '' COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  cluster_id CHAR(36) NOT NULL COMMENT '',
+  namespace_id CHAR(36) NOT NULL COMMENT '',
+  config LONGTEXT NOT NULL COMMENT '',
+  is_disabled TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_namespace_id (namespace_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='';
+
+
+# Dump of table release_history
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS release_history;
+
+CREATE TABLE release_history (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  cluster_id CHAR(36) NOT NULL COMMENT '',
+  namespace_id CHAR(36) NOT NULL COMMENT '',
+  release_id CHAR(36) NOT NULL COMMENT '',
+  pre_release_id CHAR(36) NOT NULL COMMENT '',
+  op_type VARCHAR(16) NOT NULL DEFAULT 'normal' COMMENT 'normal,rollback,gray',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_namespace_id (namespace_id),
+  KEY idx_release_id (release_id),
+  KEY idx_pre_release_id (pre_release_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='';
+
+
+# Dump of table gray_release
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS gray_release;
+
+CREATE TABLE gray_release (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  cluster_id CHAR(36) NOT NULL COMMENT '',
+  namespace_id CHAR(36) NOT NULL COMMENT '',
+  release_id CHAR(36) NOT NULL COMMENT 'the gray release',
+  rules TEXT NOT NULL COMMENT 'json of instance ids and hosts to use the gray release',
+  status VARCHAR(16) NOT NULL DEFAULT 'active' COMMENT 'active,promoted,abandoned',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_namespace_id (namespace_id),
+  KEY idx_release_id (release_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='gray release of namespace';
+
+
+# Dump of table release_request
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS release_request;
+
+CREATE TABLE release_request (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  cluster_id CHAR(36) NOT NULL COMMENT '',
+  namespace_id CHAR(36) NOT NULL COMMENT '',
+  name VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'the name of the release',
+  comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'the comment of the release',
+  config LONGTEXT NOT NULL COMMENT 'json of the configs to release',
+  status VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT 'pending,approved,rejected,canceled',
+  version INT NOT NULL DEFAULT 0 COMMENT 'the version of the namespace when requested',
+  release_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the release when approved',
+  review_by CHAR(36) NOT NULL DEFAULT '' COMMENT 'the user approved or rejected',
+  review_comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT '',
+  review_time INT NOT NULL DEFAULT 0 COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT 'the user requested',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_namespace_id (namespace_id),
+  KEY idx_status (status),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='release waiting for approval';
+
+
+# Dump of table release_schedule
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS release_schedule;
+
+CREATE TABLE release_schedule (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  cluster_id CHAR(36) NOT NULL COMMENT '',
+  namespace_id CHAR(36) NOT NULL COMMENT '',
+  name VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'the name of the release',
+  comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'the comment of the release',
+  config LONGTEXT NOT NULL COMMENT 'json of the configs to release',
+  version INT NOT NULL DEFAULT 0 COMMENT 'the version of the namespace when scheduled',
+  publish_time INT NOT NULL COMMENT 'the time to release the items',
+  rollback_time INT NOT NULL DEFAULT 0 COMMENT 'the time to rollback to the previous release, never if 0',
+  status VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT 'pending,publishing,published,rolling_back,rolled_back,canceled,failed',
+  release_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the release published by the schedule',
+  pre_release_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the release before the schedule, where to rollback',
+  message VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'the reason of the failure',
+  lease_owner VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'the server running the schedule',
+  lease_expire INT NOT NULL DEFAULT 0 COMMENT 'another server can take over the schedule after the time',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT 'the user scheduled, who the release is published by',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_namespace_id (namespace_id),
+  KEY idx_status_publish_time (status,publish_time),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='release published at the scheduled time';
+
+
+# Dump of table webhook
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS webhook;
+
+CREATE TABLE webhook (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  url VARCHAR(512) NOT NULL COMMENT 'where the events are posted to',
+  secret VARCHAR(1024) NOT NULL COMMENT 'the sealed secret to sign the payloads',
+  events TEXT NOT NULL COMMENT 'the json array of the subscribed events',
+  is_enabled TINYINT(1) NOT NULL DEFAULT 1 COMMENT '',
+  comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_app_id (app_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='the url notified of the events of the app';
+
+
+# Dump of table webhook_delivery
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS webhook_delivery;
+
+CREATE TABLE webhook_delivery (
+  id CHAR(36) NOT NULL COMMENT '',
+  webhook_id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  event VARCHAR(32) NOT NULL COMMENT 'item.create,item.update,item.delete,release,rollback,sync,gray.release,gray.promote,gray.abandon,ping',
+  payload LONGTEXT NOT NULL COMMENT 'the json body posted',
+  status VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT 'pending,success,failed',
+  attempts INT NOT NULL DEFAULT 0 COMMENT 'the times posted',
+  next_time INT NOT NULL DEFAULT 0 COMMENT 'the time of the next attempt if pending',
+  response_code INT NOT NULL DEFAULT 0 COMMENT 'the http status of the last attempt',
+  message VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'the reason of the last failed attempt',
+  lease_owner VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'the server sending the delivery',
+  lease_expire INT NOT NULL DEFAULT 0 COMMENT 'another server can take over the delivery after the time',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_webhook_id (webhook_id,create_time),
+  KEY idx_status_next_time (status,next_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='the event posted to the webhook';
+
+
+# Dump of table user
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS user;
+
+CREATE TABLE user (
+  id CHAR(36) NOT NULL COMMENT '',
+  username VARCHAR(64) NOT NULL COMMENT 'uniqueness name in provider',
+  password VARCHAR(72) NOT NULL DEFAULT '' COMMENT 'bcrypt hash of local user',
+  name VARCHAR(64) NOT NULL DEFAULT '' COMMENT '',
+  email VARCHAR(128) NOT NULL DEFAULT '' COMMENT '',
+  provider VARCHAR(32) NOT NULL DEFAULT 'local' COMMENT 'auth provider of the user',
+  is_admin TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  UNIQUE KEY uk_username (username,provider),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='portal user';
+
+
+# Dump of table access_key
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS access_key;
+
+CREATE TABLE access_key (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  key_hash CHAR(64) NOT NULL COMMENT 'sha256 of the key',
+  key_prefix CHAR(8) NOT NULL COMMENT 'to recognize the key',
+  comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT '',
+  allow_secret TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'the clients can read the decrypted secrets',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_app_id (app_id),
+  KEY idx_key_hash (key_hash),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='access key of app clients';
+
+
+# Dump of table permission
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS permission;
+
+CREATE TABLE permission (
+  id CHAR(36) NOT NULL COMMENT '',
+  user_id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  cluster_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'all clusters of the app if empty',
+  namespace_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'all namespaces of the cluster if empty',
+  role VARCHAR(16) NOT NULL COMMENT 'owner,editor,releaser,viewer',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_user_app (user_id,app_id),
+  KEY idx_app_id (app_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='role of user in app';
+
+
+# Dump of table setting
+# ------------------------------------------------------------
+
+DROP TABLE IF EXISTS setting;
+
+CREATE TABLE setting (
+  id CHAR(36) NOT NULL COMMENT '',
+  `key` varchar(64) NOT NULL COMMENT '',
+  value varchar(2048) NOT NULL COMMENT '',
+  comment varchar(1024) DEFAULT '' COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY `IX_Key` (`key`),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='server setting';
+
+# Config
+# ------------------------------------------------------------
+INSERT INTO setting (id, `key`, value, comment)
+VALUES
+    (uuid(), 'store.etcd.url',  'http://localhost:2379', 'etcd server url'),
+    (uuid(), 'item.key.length.limit',  '128', 'item key 最大长度限制'),
+    (uuid(), 'item.value.length.limit', '20000', 'item value最大长度限制');
+
+# Admin user without a password, set it by `server -set-password admin` before the first login
+INSERT INTO user (id, username, password, name, provider, is_admin, create_by, create_time)
+VALUES
+    ('00000000-0000-0000-0000-000000000001', 'admin', '', 'admin', 'local', 1, '00000000-0000-0000-0000-000000000001', UNIX_TIMESTAMP());
+
+/*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;
+/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
+/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;
+/*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;
+/*!40101 SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS */;
+/*!40101 SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION */;
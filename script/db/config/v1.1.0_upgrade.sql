@@ -0,0 +1,312 @@
+/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+/*!40101 SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS */;
+/*!40101 SET @OLD_COLLATION_CONNECTION=@@COLLATION_CONNECTION */;
+/*!40101 SET NAMES utf8 */;
+/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;
+/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;
+/*!40111 SET @OLD_SQL_NOTES=@@SQL_NOTES, SQL_NOTES=0 */;
+
+# Upgrade the database of v1.0.0 to v1.1.0, the new database is created by v1.1.0_init.sql
+# ------------------------------------------------------------
+
+Use cc_config;
+
+# Alter table namespace
+# the undeleted names must be unique in the cluster before the upgrade
+# ------------------------------------------------------------
+
+ALTER TABLE namespace
+  MODIFY name VARCHAR(64) NOT NULL COMMENT 'unique in the cluster',
+  ADD format VARCHAR(16) NOT NULL DEFAULT 'properties' COMMENT 'properties, yaml, json, toml or xml, document format is released as a whole content item' AFTER cluster_id,
+  MODIFY is_public TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'public namespace can be associated by other apps',
+  ADD public_namespace_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the associated public namespace' AFTER is_public,
+  ADD need_approval TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'release must be approved by another user' AFTER public_namespace_id,
+  ADD lock_by CHAR(36) NOT NULL DEFAULT '' COMMENT 'the user editing the unreleased items, released on publish or discard' AFTER need_approval,
+  ADD lock_time INT NOT NULL DEFAULT 0 COMMENT '' AFTER lock_by,
+  ADD version INT NOT NULL DEFAULT 0 COMMENT 'increased by every write of the items and the release' AFTER lock_time,
+  ADD delete_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the id when deleted, keep the name unique among the undeleted' AFTER is_delete;
+
+UPDATE namespace SET delete_id=id WHERE is_delete=1;
+
+ALTER TABLE namespace
+  ADD UNIQUE KEY uk_cluster_name (cluster_id,name,delete_id),
+  DROP KEY idx_cluster_id,
+  ADD KEY idx_public_namespace_id (public_namespace_id);
+
+
+# Alter table record
+# ------------------------------------------------------------
+
+ALTER TABLE record
+  ADD app_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the app which the row belongs to' AFTER comment,
+  ADD client_ip VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'of the portal request' AFTER app_id,
+  ADD user_agent VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'of the portal request' AFTER client_ip,
+  ADD request_id VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'of the portal request' AFTER user_agent,
+  ADD before_data LONGTEXT NULL COMMENT 'json of the row before the operation' AFTER request_id,
+  ADD after_data LONGTEXT NULL COMMENT 'json of the row after the operation' AFTER before_data,
+  ADD KEY idx_table (table_name,table_id),
+  ADD KEY idx_app_time (app_id,create_time),
+  ADD KEY idx_create_by (create_by),
+  ADD KEY idx_create_time (create_time);
+
+
+# Alter table cluster
+# the undeleted names must be unique in the app before the upgrade
+# ------------------------------------------------------------
+
+ALTER TABLE cluster
+  MODIFY name VARCHAR(64) NOT NULL COMMENT 'unique in the app',
+  ADD delete_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the id when deleted, keep the name unique among the undeleted' AFTER is_delete;
+
+UPDATE cluster SET delete_id=id WHERE is_delete=1;
+
+ALTER TABLE cluster
+  ADD UNIQUE KEY uk_app_name (app_id,name,delete_id),
+  DROP KEY idx_app_id;
+
+
+# Alter table commit
+# ------------------------------------------------------------
+
+ALTER TABLE commit
+  ADD message VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'what the change is for' AFTER change_sets;
+
+
+# Alter table instance_release
+# the released namespaces of the running instances are recorded again when they fetch the configs
+# ------------------------------------------------------------
+
+UPDATE instance_release SET is_delete=1 WHERE is_delete=0;
+
+ALTER TABLE instance_release
+  DROP KEY idx_release_history_id,
+  DROP COLUMN release_history_id,
+  ADD namespace_id CHAR(36) NOT NULL COMMENT '' AFTER instance_id,
+  ADD release_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the release which the instance runs' AFTER namespace_id,
+  ADD KEY idx_namespace_release (namespace_id,release_id);
+
+
+# Alter table item
+# ------------------------------------------------------------
+
+ALTER TABLE item
+  ADD value_type VARCHAR(16) NOT NULL DEFAULT 'string' COMMENT 'string,int,float,bool,duration,json,yaml' AFTER value,
+  ADD value_rule TEXT NULL COMMENT 'json of value constraints: pattern,min,max,schema' AFTER value_type,
+  ADD is_secret TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'the value is envelope encrypted as enc:v1:<manager>:<data key>:<ciphertext>' AFTER order_num,
+  ADD version INT NOT NULL DEFAULT 1 COMMENT 'increased by every update, the write based on a stale read is refused' AFTER is_secret;
+
+
+# Alter table release_history
+# ------------------------------------------------------------
+
+ALTER TABLE release_history
+  MODIFY op_type VARCHAR(16) NOT NULL DEFAULT 'normal' COMMENT 'normal,rollback,gray';
+
+
+# Create table gray_release
+# ------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS gray_release (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  cluster_id CHAR(36) NOT NULL COMMENT '',
+  namespace_id CHAR(36) NOT NULL COMMENT '',
+  release_id CHAR(36) NOT NULL COMMENT 'the gray release',
+  rules TEXT NOT NULL COMMENT 'json of instance ids and hosts to use the gray release',
+  status VARCHAR(16) NOT NULL DEFAULT 'active' COMMENT 'active,promoted,abandoned',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_namespace_id (namespace_id),
+  KEY idx_release_id (release_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='gray release of namespace';
+
+
+# Create table release_request
+# ------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS release_request (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  cluster_id CHAR(36) NOT NULL COMMENT '',
+  namespace_id CHAR(36) NOT NULL COMMENT '',
+  name VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'the name of the release',
+  comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'the comment of the release',
+  config LONGTEXT NOT NULL COMMENT 'json of the configs to release',
+  status VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT 'pending,approved,rejected,canceled',
+  version INT NOT NULL DEFAULT 0 COMMENT 'the version of the namespace when requested',
+  release_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the release when approved',
+  review_by CHAR(36) NOT NULL DEFAULT '' COMMENT 'the user approved or rejected',
+  review_comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT '',
+  review_time INT NOT NULL DEFAULT 0 COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT 'the user requested',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_namespace_id (namespace_id),
+  KEY idx_status (status),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='release waiting for approval';
+
+
+# Create table release_schedule
+# ------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS release_schedule (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  cluster_id CHAR(36) NOT NULL COMMENT '',
+  namespace_id CHAR(36) NOT NULL COMMENT '',
+  name VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'the name of the release',
+  comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'the comment of the release',
+  config LONGTEXT NOT NULL COMMENT 'json of the configs to release',
+  version INT NOT NULL DEFAULT 0 COMMENT 'the version of the namespace when scheduled',
+  publish_time INT NOT NULL COMMENT 'the time to release the items',
+  rollback_time INT NOT NULL DEFAULT 0 COMMENT 'the time to rollback to the previous release, never if 0',
+  status VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT 'pending,publishing,published,rolling_back,rolled_back,canceled,failed',
+  release_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the release published by the schedule',
+  pre_release_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'the release before the schedule, where to rollback',
+  message VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'the reason of the failure',
+  lease_owner VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'the server running the schedule',
+  lease_expire INT NOT NULL DEFAULT 0 COMMENT 'another server can take over the schedule after the time',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT 'the user scheduled, who the release is published by',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_namespace_id (namespace_id),
+  KEY idx_status_publish_time (status,publish_time),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='release published at the scheduled time';
+
+
+# Create table webhook
+# ------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS webhook (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  url VARCHAR(512) NOT NULL COMMENT 'where the events are posted to',
+  secret VARCHAR(1024) NOT NULL COMMENT 'the sealed secret to sign the payloads',
+  events TEXT NOT NULL COMMENT 'the json array of the subscribed events',
+  is_enabled TINYINT(1) NOT NULL DEFAULT 1 COMMENT '',
+  comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_app_id (app_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='the url notified of the events of the app';
+
+
+# Create table webhook_delivery
+# ------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+  id CHAR(36) NOT NULL COMMENT '',
+  webhook_id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  event VARCHAR(32) NOT NULL COMMENT 'item.create,item.update,item.delete,release,rollback,sync,gray.release,gray.promote,gray.abandon,ping',
+  payload LONGTEXT NOT NULL COMMENT 'the json body posted',
+  status VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT 'pending,success,failed',
+  attempts INT NOT NULL DEFAULT 0 COMMENT 'the times posted',
+  next_time INT NOT NULL DEFAULT 0 COMMENT 'the time of the next attempt if pending',
+  response_code INT NOT NULL DEFAULT 0 COMMENT 'the http status of the last attempt',
+  message VARCHAR(1024) NOT NULL DEFAULT '' COMMENT 'the reason of the last failed attempt',
+  lease_owner VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'the server sending the delivery',
+  lease_expire INT NOT NULL DEFAULT 0 COMMENT 'another server can take over the delivery after the time',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_webhook_id (webhook_id,create_time),
+  KEY idx_status_next_time (status,next_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='the event posted to the webhook';
+
+
+# Create table user
+# ------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS user (
+  id CHAR(36) NOT NULL COMMENT '',
+  username VARCHAR(64) NOT NULL COMMENT 'uniqueness name in provider',
+  password VARCHAR(72) NOT NULL DEFAULT '' COMMENT 'bcrypt hash of local user',
+  name VARCHAR(64) NOT NULL DEFAULT '' COMMENT '',
+  email VARCHAR(128) NOT NULL DEFAULT '' COMMENT '',
+  provider VARCHAR(32) NOT NULL DEFAULT 'local' COMMENT 'auth provider of the user',
+  is_admin TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  UNIQUE KEY uk_username (username,provider),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='portal user';
+
+
+# Create table access_key
+# ------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS access_key (
+  id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  key_hash CHAR(64) NOT NULL COMMENT 'sha256 of the key',
+  key_prefix CHAR(8) NOT NULL COMMENT 'to recognize the key',
+  comment VARCHAR(255) NOT NULL DEFAULT '' COMMENT '',
+  allow_secret TINYINT(1) NOT NULL DEFAULT 0 COMMENT 'the clients can read the decrypted secrets',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_app_id (app_id),
+  KEY idx_key_hash (key_hash),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='access key of app clients';
+
+
+# Create table permission
+# ------------------------------------------------------------
+
+CREATE TABLE IF NOT EXISTS permission (
+  id CHAR(36) NOT NULL COMMENT '',
+  user_id CHAR(36) NOT NULL COMMENT '',
+  app_id CHAR(36) NOT NULL COMMENT '',
+  cluster_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'all clusters of the app if empty',
+  namespace_id CHAR(36) NOT NULL DEFAULT '' COMMENT 'all namespaces of the cluster if empty',
+  role VARCHAR(16) NOT NULL COMMENT 'owner,editor,releaser,viewer',
+  is_delete TINYINT(1) NOT NULL DEFAULT 0 COMMENT '',
+  create_by CHAR(36) NOT NULL COMMENT '',
+  create_time INT NOT NULL COMMENT '',
+  update_by CHAR(36) DEFAULT '' COMMENT '',
+  update_time INT NULL COMMENT '',
+  PRIMARY KEY (id),
+  KEY idx_user_app (user_id,app_id),
+  KEY idx_app_id (app_id),
+  KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='role of user in app';
+
+# Admin user without a password, set it by `server -set-password admin` before the first login
+INSERT IGNORE INTO user (id, username, password, name, provider, is_admin, create_by, create_time)
+VALUES
+    ('00000000-0000-0000-0000-000000000001', 'admin', '', 'admin', 'local', 1, '00000000-0000-0000-0000-000000000001', UNIX_TIMESTAMP());
+
+/*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;
+/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
+/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;
+/*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;
+/*!40101 SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS */;
+/*!40101 SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION */;
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"github.com/pkg/errors"
+	"sync"
+)
+
+const LocalProvider = "local"
+
+//the user identity verified by the provider
+type Identity struct {
+	Username string
+	Name     string
+	Email    string
+}
+
+//Provider verifies the username and password, e.g. local database, ldap
+type Provider interface {
+	Authenticate(username, password string) (*Identity, error)
+}
+
+var providers sync.Map
+
+func RegisterProvider(name string, p Provider) {
+	providers.Store(name, p)
+}
+
+func GetProvider(name string) (Provider, error) {
+	p, ok := providers.Load(name)
+	if !ok {
+		return nil, errors.Errorf("auth provider[%s] not exists", name)
+	}
+	return p.(Provider), nil
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hackbeex/configcenter/local"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const defaultTokenExpire = 24 * 3600
+
+//sign the login token of the user, return the token and its expire time
+func SignToken(userId string) (string, int64, error) {
+	conf := local.Conf.Server.Auth
+	if conf.Secret == "" {
+		return "", 0, errors.New("auth secret is not configured")
+	}
+	expire := conf.TokenExpire
+	if expire <= 0 {
+		expire = defaultTokenExpire
+	}
+
+	now := time.Now()
+	expireAt := now.Add(time.Duration(expire) * time.Second).Unix()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+		Subject:   userId,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expireAt,
+	})
+	signed, err := token.SignedString([]byte(conf.Secret))
+	if err != nil {
+		return "", 0, errors.Wrap(err, "sign token")
+	}
+	return signed, expireAt, nil
+}
+
+//parse the login token, return the user id
+func ParseToken(token string) (string, error) {
+	conf := local.Conf.Server.Auth
+	if conf.Secret == "" {
+		return "", errors.New("auth secret is not configured")
+	}
+
+	claims := &jwt.StandardClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return []byte(conf.Secret), nil
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "invalid token")
+	}
+	if claims.Subject == "" {
+		return "", errors.New("invalid token")
+	}
+	return claims.Subject, nil
+}
@@ -10,6 +10,15 @@ type ChangeConfig struct {
 	Value string `json:"value"`
 }
 
+//config changes of one namespace caused by a release
+type NamespaceChange struct {
+	NamespaceId string                        `json:"namespace_id"`
+	Namespace   string                        `json:"namespace"`
+	ReleaseId   string                        `json:"release_id"`
+	Configs     map[com.OpType][]ChangeConfig `json:"configs"`
+	Content     string                        `json:"content,omitempty"` //the raw document of the document namespace
+}
+
 type Instance struct {
 	Id      string
 	AppId   string
@@ -20,8 +29,31 @@ type Instance struct {
 	Life    int
 
 	ChChange chan bool
-	//ChReady bool
-	//ChData  chan map[com.OpType][]ChangeConfig
+
+	mu      sync.Mutex
+	changes []NamespaceChange
+}
+
+//queue the change and wake up the watching request
+func (i *Instance) PushChange(change NamespaceChange) {
+	i.mu.Lock()
+	i.changes = append(i.changes, change)
+	i.mu.Unlock()
+
+	//the wake-up is already pending if the buffer is full
+	select {
+	case i.ChChange <- true:
+	default:
+	}
+}
+
+//take out all queued changes in release order
+func (i *Instance) PopChanges() []NamespaceChange {
+	i.mu.Lock()
+	changes := i.changes
+	i.changes = nil
+	i.mu.Unlock()
+	return changes
 }
 
 type InstanceTable struct {
@@ -55,3 +87,32 @@ func (t *InstanceTable) Range(f func(instanceId string, val *Instance) bool) {
 		return f(k.(string), v.(*Instance))
 	})
 }
+
+//push the change to the online instances accepted by match, return the notified count
+func (t *InstanceTable) NotifyChange(change NamespaceChange, match func(ins *Instance) bool) int {
+	count := 0
+	t.Range(func(instanceId string, val *Instance) bool {
+		//not online instance will refresh all configs when it comes back
+		if val.Status == com.OnlineStatus && match(val) {
+			val.PushChange(change)
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+//match the instances of the app which run in one of the clusters
+func ConsumerOf(appId string, clusterIds ...string) func(ins *Instance) bool {
+	return func(ins *Instance) bool {
+		if ins.AppId != appId {
+			return false
+		}
+		for _, id := range clusterIds {
+			if ins.Cluster == id {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"github.com/hackbeex/configcenter/util/com"
+	"testing"
+	"time"
+)
+
+func newTestInstance(id, appId, cluster string) *Instance {
+	return &Instance{
+		Id:       id,
+		AppId:    appId,
+		Cluster:  cluster,
+		Status:   com.OnlineStatus,
+		Life:     60,
+		ChChange: make(chan bool, 1),
+	}
+}
+
+func TestNotifyChange(t *testing.T) {
+	table := NewInstanceTable()
+	released := newTestInstance("ins1", "app1", "cluster1")
+	otherCluster := newTestInstance("ins2", "app1", "cluster2")
+	otherApp := newTestInstance("ins3", "app2", "cluster1")
+	table.Store(released.Id, released)
+	table.Store(otherCluster.Id, otherCluster)
+	table.Store(otherApp.Id, otherApp)
+
+	//the long-poll of the unrelated app
+	woken := make(chan bool, 1)
+	go func() {
+		select {
+		case <-otherApp.ChChange:
+			woken <- true
+		case <-time.After(time.Millisecond * 200):
+			woken <- false
+		}
+	}()
+
+	change := NamespaceChange{
+		NamespaceId: "ns1",
+		Namespace:   "application",
+		ReleaseId:   "release1",
+		Configs: map[com.OpType][]ChangeConfig{
+			com.OpUpdate: {{Key: "timeout", Value: "3s"}},
+		},
+	}
+	if count := table.NotifyChange(change, ConsumerOf("app1", "cluster1")); count != 1 {
+		t.Fatalf("notified %d instances, want 1", count)
+	}
+
+	if <-woken {
+		t.Fatal("the instance of unrelated app was woken")
+	}
+	if len(otherCluster.ChChange) != 0 || len(otherCluster.PopChanges()) != 0 {
+		t.Fatal("the instance of unrelated cluster was notified")
+	}
+	if len(released.ChChange) != 1 {
+		t.Fatal("the instance of released cluster was not woken")
+	}
+	changes := released.PopChanges()
+	if len(changes) != 1 || changes[0].ReleaseId != "release1" {
+		t.Fatalf("unexpected changes: %v", changes)
+	}
+}
+
+func TestPushChangeConcurrently(t *testing.T) {
+	ins := newTestInstance("ins1", "app1", "cluster1")
+	done := make(chan bool)
+	for n := 0; n < 10; n++ {
+		go func() {
+			ins.PushChange(NamespaceChange{NamespaceId: "ns1"})
+			done <- true
+		}()
+	}
+	for n := 0; n < 10; n++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the push is blocked by the pending wake-up")
+		}
+	}
+	if len(ins.ChChange) != 1 || len(ins.PopChanges()) != 10 {
+		t.Fatal("the changes should be queued with one wake-up")
+	}
+}
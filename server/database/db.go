@@ -6,12 +6,22 @@ import (
 	"github.com/hackbeex/configcenter/util/log"
 	"github.com/jinzhu/gorm"
 	"os"
+	"sync"
 	"time"
 )
 
-var dbConn *gorm.DB
+var (
+	dbConn *gorm.DB
+	dbOnce sync.Once
+)
+
+//connect to the db only once, the server calls it on start to fail fast, otherwise it connects on the first use,
+//so the packages using the db can be tested without it
+func Init() {
+	dbOnce.Do(connect)
+}
 
-func init() {
+func connect() {
 	var err error
 	var dbDebugMode = true
 	if os.Getenv("DB_DEBUG") == "0" {
@@ -47,5 +57,6 @@ func dbConfig() *mysql.Config {
 }
 
 func Conn() *gorm.DB {
+	Init()
 	return dbConn.New()
 }
@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
 	"strings"
 )
@@ -80,3 +81,16 @@ func Update(db *gorm.DB, table string, data map[string]interface{}, where string
 	}
 	return db.Exec(sql, params...)
 }
+
+var likeReplacer = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+//escape the wildcards of the LIKE pattern
+func EscapeLike(s string) string {
+	return likeReplacer.Replace(s)
+}
+
+//the error is caused by a duplicate entry of an unique key
+func IsDuplicate(err error) bool {
+	e, ok := err.(*mysql.MySQLError)
+	return ok && e.Number == 1062
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/middleware"
+	"github.com/hackbeex/configcenter/server/model"
+	"github.com/hackbeex/configcenter/util/response"
+)
+
+func CreateAccessKey(c *gin.Context) {
+	var req model.CreateAccessKeyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	accessKey := model.AccessKeyModel{}
+	res, err := accessKey.Create(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func GetAccessKeyList(c *gin.Context) {
+	var req model.AccessKeyListReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	accessKey := model.AccessKeyModel{}
+	res, err := accessKey.List(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func DeleteAccessKey(c *gin.Context) {
+	var req model.DeleteAccessKeyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	accessKey := model.AccessKeyModel{}
+	err := accessKey.Delete(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
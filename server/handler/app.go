@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/middleware"
 	"github.com/hackbeex/configcenter/server/model"
 	"github.com/hackbeex/configcenter/util/response"
 )
@@ -12,6 +13,7 @@ func GetAppList(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
 
 	app := model.AppModel{}
 	res, err := app.List(&req)
@@ -29,6 +31,7 @@ func GetAppDetail(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
 
 	app := model.AppModel{}
 	res, err := app.Detail(&req)
@@ -46,6 +49,8 @@ func CreateApp(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
 
 	app := model.AppModel{}
 	res, err := app.Create(&req)
@@ -56,3 +61,41 @@ func CreateApp(c *gin.Context) {
 
 	response.Data(c, res)
 }
+
+func UpdateApp(c *gin.Context) {
+	var req model.UpdateAppReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	app := model.AppModel{}
+	err := app.Update(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
+
+func DeleteApp(c *gin.Context) {
+	var req model.DeleteAppReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	app := model.AppModel{}
+	err := app.Delete(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/middleware"
+	"github.com/hackbeex/configcenter/server/model"
+	"github.com/hackbeex/configcenter/util/response"
+)
+
+func GetAuditList(c *gin.Context) {
+	var req model.AuditListReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	audit := model.AuditModel{}
+	res, err := audit.List(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func ExportAudit(c *gin.Context) {
+	var req model.AuditExportReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	audit := model.AuditModel{}
+	res, err := audit.Export(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/middleware"
 	"github.com/hackbeex/configcenter/server/model"
 	"github.com/hackbeex/configcenter/util/response"
 )
@@ -12,6 +13,8 @@ func WatchConfig(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.AccessAppId = middleware.AccessAppId(c)
+	req.AllowSecret = middleware.AllowSecret(c)
 
 	config := model.ConfigModel{}
 	res, err := config.Watch(&req)
@@ -29,6 +32,8 @@ func GetClientConfigList(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.AccessAppId = middleware.AccessAppId(c)
+	req.AllowSecret = middleware.AllowSecret(c)
 
 	config := model.ConfigModel{}
 	res, err := config.ListByApp(&req)
@@ -46,6 +51,7 @@ func ExitClient(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.AccessAppId = middleware.AccessAppId(c)
 
 	instance := model.InstanceModel{}
 	err := instance.ExitInstance(&req)
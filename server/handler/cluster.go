@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/middleware"
 	"github.com/hackbeex/configcenter/server/model"
 	"github.com/hackbeex/configcenter/util/response"
 )
@@ -12,6 +13,8 @@ func CreateCluster(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
 
 	cluster := model.ClusterModel{}
 	res, err := cluster.Create(&req)
@@ -22,3 +25,59 @@ func CreateCluster(c *gin.Context) {
 
 	response.Data(c, res)
 }
+
+func GetClusterList(c *gin.Context) {
+	var req model.ClusterListReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	cluster := model.ClusterModel{}
+	res, err := cluster.List(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func UpdateCluster(c *gin.Context) {
+	var req model.UpdateClusterReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	cluster := model.ClusterModel{}
+	err := cluster.Update(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
+
+func DeleteCluster(c *gin.Context) {
+	var req model.DeleteClusterReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	cluster := model.ClusterModel{}
+	err := cluster.Delete(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
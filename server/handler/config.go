@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/middleware"
 	"github.com/hackbeex/configcenter/server/model"
 	"github.com/hackbeex/configcenter/util/response"
 )
@@ -12,6 +13,7 @@ func GetConfigDetail(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
 
 	config := model.ConfigModel{}
 	res, err := config.Detail(&req)
@@ -29,6 +31,7 @@ func GetConfigList(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
 
 	config := model.ConfigModel{}
 	res, err := config.List(&req)
@@ -46,6 +49,8 @@ func CreateConfig(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
 
 	config := model.ConfigModel{}
 	res, err := config.Create(&req)
@@ -63,6 +68,8 @@ func UpdateConfig(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
 
 	config := model.ConfigModel{}
 	err := config.Update(&req)
@@ -80,6 +87,8 @@ func DeleteConfig(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
 
 	config := model.ConfigModel{}
 	err := config.Delete(&req)
@@ -97,6 +106,7 @@ func GetConfigHistory(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
 
 	config := model.ConfigModel{}
 	res, err := config.GetHistory(&req)
@@ -108,21 +118,77 @@ func GetConfigHistory(c *gin.Context) {
 	response.Data(c, res)
 }
 
+func SearchConfig(c *gin.Context) {
+	var req model.SearchConfigReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	config := model.ConfigModel{}
+	res, err := config.Search(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func GetConfigKeyHistory(c *gin.Context) {
+	var req model.KeyHistoryReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	config := model.ConfigModel{}
+	res, err := config.GetKeyHistory(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func BlameConfig(c *gin.Context) {
+	var req model.ConfigBlameReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	config := model.ConfigModel{}
+	res, err := config.Blame(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
 func ReleaseConfig(c *gin.Context) {
 	var req model.ReleaseConfigReq
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
 
 	config := model.ConfigModel{}
-	err := config.Release(&req)
+	res, err := config.Release(&req)
 	if err != nil {
 		response.Error(c, err)
 		return
 	}
 
-	response.OK(c)
+	response.Data(c, res)
 }
 
 func GetConfigReleaseHistory(c *gin.Context) {
@@ -131,6 +197,7 @@ func GetConfigReleaseHistory(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
 
 	config := model.ConfigModel{}
 	res, err := config.GetReleaseHistory(&req)
@@ -148,6 +215,8 @@ func RollbackConfig(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
 
 	config := model.ConfigModel{}
 	err := config.Rollback(&req)
@@ -165,6 +234,8 @@ func SyncConfig(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
 
 	config := model.ConfigModel{}
 	err := config.Sync(&req)
@@ -175,3 +246,151 @@ func SyncConfig(c *gin.Context) {
 
 	response.OK(c)
 }
+
+func GetConfigContent(c *gin.Context) {
+	var req model.ConfigContentReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	config := model.ConfigModel{}
+	res, err := config.Content(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func SaveConfigContent(c *gin.Context) {
+	var req model.SaveConfigContentReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	config := model.ConfigModel{}
+	err := config.SaveContent(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
+
+func ImportConfig(c *gin.Context) {
+	var req model.ImportConfigReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	config := model.ConfigModel{}
+	res, err := config.Import(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func ExportConfig(c *gin.Context) {
+	var req model.ExportConfigReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	config := model.ConfigModel{}
+	res, err := config.Export(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func CompareConfig(c *gin.Context) {
+	var req model.CompareConfigReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	config := model.ConfigModel{}
+	res, err := config.Compare(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func PromoteConfig(c *gin.Context) {
+	var req model.PromoteConfigReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	config := model.ConfigModel{}
+	res, err := config.Promote(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func DiscardConfig(c *gin.Context) {
+	var req model.DiscardConfigReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	config := model.ConfigModel{}
+	err := config.Discard(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
+
+func BatchConfig(c *gin.Context) {
+	var req model.BatchConfigReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	config := model.ConfigModel{}
+	res, err := config.Batch(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
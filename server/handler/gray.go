@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/middleware"
+	"github.com/hackbeex/configcenter/server/model"
+	"github.com/hackbeex/configcenter/util/response"
+)
+
+func GrayReleaseConfig(c *gin.Context) {
+	var req model.GrayReleaseReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	gray := model.GrayModel{}
+	res, err := gray.Release(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func PromoteGrayRelease(c *gin.Context) {
+	var req model.GrayOperateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	gray := model.GrayModel{}
+	err := gray.Promote(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
+
+func AbandonGrayRelease(c *gin.Context) {
+	var req model.GrayOperateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	gray := model.GrayModel{}
+	err := gray.Abandon(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
+
+func GetGrayReleaseDetail(c *gin.Context) {
+	var req model.GrayDetailReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	gray := model.GrayModel{}
+	res, err := gray.Detail(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
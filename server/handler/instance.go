@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/middleware"
 	"github.com/hackbeex/configcenter/server/model"
 	"github.com/hackbeex/configcenter/util/response"
 )
@@ -12,6 +13,7 @@ func GetInstanceList(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
 
 	instance := model.InstanceModel{}
 	res, err := instance.List(&req)
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/middleware"
 	"github.com/hackbeex/configcenter/server/model"
 	"github.com/hackbeex/configcenter/util/response"
 )
@@ -12,6 +13,8 @@ func CreateNamespace(c *gin.Context) {
 		response.Error(c, err)
 		return
 	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
 
 	namespace := model.NamespaceModel{}
 	res, err := namespace.Create(&req)
@@ -22,3 +25,114 @@ func CreateNamespace(c *gin.Context) {
 
 	response.Data(c, res)
 }
+
+func AssociateNamespace(c *gin.Context) {
+	var req model.AssociateNamespaceReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	namespace := model.NamespaceModel{}
+	res, err := namespace.Associate(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func GetPublicNamespaceList(c *gin.Context) {
+	var req model.PublicNamespaceListReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	namespace := model.NamespaceModel{}
+	res, err := namespace.ListPublic(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func SetNamespaceApproval(c *gin.Context) {
+	var req model.SetNamespaceApprovalReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	namespace := model.NamespaceModel{}
+	err := namespace.SetApproval(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
+
+func GetNamespaceList(c *gin.Context) {
+	var req model.NamespaceListReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	namespace := model.NamespaceModel{}
+	res, err := namespace.List(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func UpdateNamespace(c *gin.Context) {
+	var req model.UpdateNamespaceReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	namespace := model.NamespaceModel{}
+	err := namespace.Update(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
+
+func DeleteNamespace(c *gin.Context) {
+	var req model.DeleteNamespaceReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	namespace := model.NamespaceModel{}
+	err := namespace.Delete(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
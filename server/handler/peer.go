@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/middleware"
+	"github.com/hackbeex/configcenter/server/model"
+	"github.com/hackbeex/configcenter/util/response"
+)
+
+func GetPeerConfigSnapshot(c *gin.Context) {
+	var req model.PeerConfigReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	config := model.ConfigModel{}
+	res, err := config.PeerSnapshot(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func PeerPromoteConfig(c *gin.Context) {
+	var req model.PeerPromoteReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	config := model.ConfigModel{}
+	res, err := config.PeerPromote(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/middleware"
+	"github.com/hackbeex/configcenter/server/model"
+	"github.com/hackbeex/configcenter/util/response"
+)
+
+func GrantRole(c *gin.Context) {
+	var req model.GrantRoleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	permission := model.PermissionModel{}
+	res, err := permission.Grant(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
+
+func RevokeRole(c *gin.Context) {
+	var req model.RevokeRoleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+	req.Request = middleware.Request(c)
+
+	permission := model.PermissionModel{}
+	err := permission.Revoke(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.OK(c)
+}
+
+func GetPermissionList(c *gin.Context) {
+	var req model.PermissionListReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, err)
+		return
+	}
+	req.UserId = middleware.UserId(c)
+
+	permission := model.PermissionModel{}
+	res, err := permission.List(&req)
+	if err != nil {
+		response.Error(c, err)
+		return
+	}
+
+	response.Data(c, res)
+}
@@ -0,0 +1,122 @@
+package kms
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/hackbeex/configcenter/local"
+	"github.com/hackbeex/configcenter/util"
+	"github.com/pkg/errors"
+	"strings"
+	"sync"
+)
+
+//KeyManager protects the data keys by the master key it holds, the values are encrypted by the data keys
+//which are stored along with the values, so that the master key never leaves the key manager
+type KeyManager interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+//the sealed value is like enc:v1:<manager>:<encrypted data key>:<encrypted value>
+const sealedPrefix = "enc:v1:"
+
+const dataKeySize = 32
+
+var (
+	managers = map[string]KeyManager{}
+	lock     sync.RWMutex
+)
+
+func Register(name string, manager KeyManager) {
+	lock.Lock()
+	defer lock.Unlock()
+	managers[name] = manager
+}
+
+func Get(name string) (KeyManager, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+	manager, ok := managers[name]
+	if !ok {
+		return nil, errors.Errorf("unknown key manager %s", name)
+	}
+	return manager, nil
+}
+
+//the key manager to encrypt new values
+func current() (string, KeyManager, error) {
+	name := local.Conf.Server.Kms.Provider
+	if name == "" {
+		name = LocalManager
+	}
+	manager, err := Get(name)
+	return name, manager, err
+}
+
+//whether the key manager to encrypt new values is configured, the local one needs its master key file
+func Configured() bool {
+	name := local.Conf.Server.Kms.Provider
+	if name != "" && name != LocalManager {
+		return true
+	}
+	return local.Conf.Server.Kms.MasterKeyFile != ""
+}
+
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+//envelope encrypt the value by a new data key
+func Seal(plaintext string) (string, error) {
+	name, manager, err := current()
+	if err != nil {
+		return "", err
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	encryptedKey, err := manager.Encrypt(dataKey)
+	if err != nil {
+		return "", errors.Wrap(err, "encrypt data key")
+	}
+	ciphertext, err := util.AesGcmEncrypt(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + name + ":" +
+		base64.RawURLEncoding.EncodeToString(encryptedKey) + ":" +
+		base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+//decrypt the sealed value by the key manager which sealed it
+func Open(sealed string) (string, error) {
+	if !IsSealed(sealed) {
+		return "", errors.New("the value is not sealed")
+	}
+	parts := strings.Split(strings.TrimPrefix(sealed, sealedPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed sealed value")
+	}
+	manager, err := Get(parts[0])
+	if err != nil {
+		return "", err
+	}
+	encryptedKey, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.Wrap(err, "malformed data key")
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.Wrap(err, "malformed ciphertext")
+	}
+	dataKey, err := manager.Decrypt(encryptedKey)
+	if err != nil {
+		return "", errors.Wrap(err, "decrypt data key")
+	}
+	plaintext, err := util.AesGcmDecrypt(dataKey, ciphertext)
+	if err != nil {
+		return "", errors.Wrap(err, "decrypt value")
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,166 @@
+package kms
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/hackbeex/configcenter/local"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	f, err := ioutil.TempFile("", "master_key")
+	if err != nil {
+		panic(err)
+	}
+	key := make([]byte, dataKeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	if _, err := f.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		panic(err)
+	}
+	f.Close()
+	local.Conf.Server.Kms.Provider = ""
+	local.Conf.Server.Kms.MasterKeyFile = f.Name()
+
+	code := m.Run()
+	os.Remove(f.Name())
+	os.Exit(code)
+}
+
+func TestSealFormat(t *testing.T) {
+	sealed, err := Seal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) {
+		t.Fatalf("not sealed: %s", sealed)
+	}
+	parts := strings.Split(strings.TrimPrefix(sealed, "enc:v1:"), ":")
+	if len(parts) != 3 || parts[0] != LocalManager {
+		t.Fatalf("sealed value should be enc:v1:<manager>:<key>:<ciphertext>, got %s", sealed)
+	}
+	for _, part := range parts[1:] {
+		if _, err := base64.RawURLEncoding.DecodeString(part); err != nil {
+			t.Errorf("part %s: %v", part, err)
+		}
+	}
+	if strings.Contains(sealed, "secret") {
+		t.Error("the plaintext is leaked")
+	}
+
+	again, err := Seal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == sealed {
+		t.Error("the same value should be sealed by different data keys")
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	for _, plaintext := range []string{"", "secret", "中文 with : colons", strings.Repeat("x", 4096)} {
+		sealed, err := Seal(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Open(sealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != plaintext {
+			t.Errorf("open: got %q, want %q", got, plaintext)
+		}
+	}
+}
+
+func TestLocalManager(t *testing.T) {
+	manager, err := Get(LocalManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	encrypted, err := manager.Encrypt(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := manager.Decrypt(encrypted)
+	if err != nil || string(decrypted) != string(dataKey) {
+		t.Errorf("decrypt: %q %v", decrypted, err)
+	}
+
+	//the data key protected by another master key can not be opened
+	other := &localManager{masterKey: make([]byte, dataKeySize)}
+	other.once.Do(func() {})
+	if _, err := other.Decrypt(encrypted); err == nil {
+		t.Error("decrypt by another master key should fail")
+	}
+}
+
+func TestOpenTampered(t *testing.T) {
+	sealed, err := Seal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(strings.TrimPrefix(sealed, sealedPrefix), ":")
+	flip := func(s string) string {
+		data, _ := base64.RawURLEncoding.DecodeString(s)
+		data[len(data)-1] ^= 1
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	tests := []struct {
+		name   string
+		sealed string
+	}{
+		{"not sealed", "secret"},
+		{"missing part", sealedPrefix + parts[0] + ":" + parts[1]},
+		{"extra part", sealed + ":x"},
+		{"unknown manager", sealedPrefix + "vault:" + parts[1] + ":" + parts[2]},
+		{"bad key encoding", sealedPrefix + parts[0] + ":!:" + parts[2]},
+		{"bad ciphertext encoding", sealedPrefix + parts[0] + ":" + parts[1] + ":!"},
+		{"tampered key", sealedPrefix + parts[0] + ":" + flip(parts[1]) + ":" + parts[2]},
+		{"tampered ciphertext", sealedPrefix + parts[0] + ":" + parts[1] + ":" + flip(parts[2])},
+		{"swapped ciphertext", sealedPrefix + parts[0] + ":" + parts[1] + ":" + strings.Split(mustSeal(t, "other"), ":")[4]},
+	}
+	for _, tt := range tests {
+		if got, err := Open(tt.sealed); err == nil {
+			t.Errorf("%s: should be refused, got %q", tt.name, got)
+		}
+	}
+}
+
+func mustSeal(t *testing.T, plaintext string) string {
+	sealed, err := Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sealed
+}
+
+func TestConfigured(t *testing.T) {
+	defer func(provider, file string) {
+		local.Conf.Server.Kms.Provider = provider
+		local.Conf.Server.Kms.MasterKeyFile = file
+	}(local.Conf.Server.Kms.Provider, local.Conf.Server.Kms.MasterKeyFile)
+
+	tests := []struct {
+		provider, file string
+		want           bool
+	}{
+		{"", "master.key", true},
+		{LocalManager, "", false},
+		{"", "", false},
+		{"vault", "", true},
+	}
+	for _, tt := range tests {
+		local.Conf.Server.Kms.Provider = tt.provider
+		local.Conf.Server.Kms.MasterKeyFile = tt.file
+		if got := Configured(); got != tt.want {
+			t.Errorf("provider %q file %q: got %v, want %v", tt.provider, tt.file, got, tt.want)
+		}
+	}
+}
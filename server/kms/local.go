@@ -0,0 +1,62 @@
+package kms
+
+import (
+	"encoding/hex"
+	"github.com/hackbeex/configcenter/local"
+	"github.com/hackbeex/configcenter/util"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+const LocalManager = "local"
+
+func init() {
+	Register(LocalManager, &localManager{})
+}
+
+//protect the data keys by the master key read from the local file
+type localManager struct {
+	once      sync.Once
+	masterKey []byte
+	err       error
+}
+
+func (m *localManager) load() ([]byte, error) {
+	m.once.Do(func() {
+		path := local.Conf.Server.Kms.MasterKeyFile
+		if path == "" {
+			m.err = errors.New("master key file of local key manager is not configured")
+			return
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			m.err = errors.Wrap(err, "read master key file")
+			return
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != dataKeySize {
+			m.err = errors.New("master key must be 32 bytes in hex")
+			return
+		}
+		m.masterKey = key
+	})
+	return m.masterKey, m.err
+}
+
+func (m *localManager) Encrypt(plaintext []byte) ([]byte, error) {
+	key, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	return util.AesGcmEncrypt(key, plaintext)
+}
+
+func (m *localManager) Decrypt(ciphertext []byte) ([]byte, error) {
+	key, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	return util.AesGcmDecrypt(key, ciphertext)
+}
@@ -1,22 +1,37 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/hackbeex/configcenter/local"
 	"github.com/hackbeex/configcenter/server/core"
+	"github.com/hackbeex/configcenter/server/database"
 	"github.com/hackbeex/configcenter/server/handler"
+	"github.com/hackbeex/configcenter/server/middleware"
+	"github.com/hackbeex/configcenter/server/model"
 	"github.com/hackbeex/configcenter/util"
 	"github.com/hackbeex/configcenter/util/com"
 	"github.com/hackbeex/configcenter/util/log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
 
+var setPassword = flag.String("set-password", "", "read the new password of the local user from stdin, set it and exit")
+
 func main() {
+	flag.Parse()
+	database.Init()
+	if *setPassword != "" {
+		runSetPassword(*setPassword)
+		return
+	}
+
 	registerServer()
 
 	go exitServer()
@@ -25,9 +40,29 @@ func main() {
 
 	go checkInstances()
 
+	go runSchedules()
+	go runWebhooks()
+
 	runServer()
 }
 
+func runSetPassword(username string) {
+	fmt.Printf("new password of %s: ", username)
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		log.Fatal(err)
+	}
+	userMdl := model.UserModel{}
+	err = userMdl.SetPassword(&model.SetPasswordReq{
+		Username: username,
+		Password: strings.TrimRight(password, "\r\n"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("the password is set")
+}
+
 func registerServer() {
 	conf := local.Conf.Server
 	if conf.Env == "" {
@@ -94,6 +129,15 @@ func reportHeartbeat() {
 	}
 }
 
+func runWebhooks() {
+	webhook := model.WebhookModel{}
+	for {
+		webhook.DispatchDue(core.GetServer().Id)
+
+		time.Sleep(time.Second * 5)
+	}
+}
+
 func checkInstances() {
 	server := core.GetServer()
 	instances := server.Instances
@@ -114,33 +158,99 @@ func checkInstances() {
 	}
 }
 
+func runSchedules() {
+	schedule := model.ReleaseScheduleModel{}
+	for {
+		schedule.RunDue(core.GetServer().Id)
+
+		time.Sleep(time.Second * 10)
+	}
+}
+
 func runServer() {
 	r := gin.Default()
 
-	//TODO： portal auth, client token
+	r.POST("/api/v1/user/login", handler.Login)
 
 	//instance api
-	r.POST("/api/v1/client/config/list", handler.GetClientConfigList)
-	r.POST("/api/v1/client/config/watch", handler.WatchConfig)
-	r.POST("/api/v1/client/exit", handler.ExitClient)
+	client := r.Group("", middleware.ClientAuth())
+	client.POST("/api/v1/client/config/list", handler.GetClientConfigList)
+	client.POST("/api/v1/client/config/watch", handler.WatchConfig)
+	client.POST("/api/v1/client/exit", handler.ExitClient)
+
+	//api of the servers of other envs
+	peer := r.Group("", middleware.PeerAuth())
+	peer.POST("/api/v1/peer/config/snapshot", handler.GetPeerConfigSnapshot)
+	peer.POST("/api/v1/peer/config/promote", handler.PeerPromoteConfig)
 
 	//portal api
-	r.POST("/api/v1/app/list", handler.GetAppList)
-	r.POST("/api/v1/app/detail", handler.GetAppDetail)
-	r.POST("/api/v1/app/create", handler.CreateApp)
-	r.POST("/api/v1/cluster/create", handler.CreateCluster)
-	r.POST("/api/v1/namespace/create", handler.CreateNamespace)
-	r.POST("/api/v1/config/detail", handler.GetConfigDetail)
-	r.POST("/api/v1/config/list", handler.GetConfigList)
-	r.POST("/api/v1/config/create", handler.CreateConfig)
-	r.POST("/api/v1/config/update", handler.UpdateConfig)
-	r.POST("/api/v1/config/delete", handler.DeleteConfig)
-	r.POST("/api/v1/config/history", handler.GetConfigHistory)
-	r.POST("/api/v1/config/release", handler.ReleaseConfig)
-	r.POST("/api/v1/config/release/history", handler.GetConfigReleaseHistory)
-	r.POST("/api/v1/config/rollback", handler.RollbackConfig)
-	r.POST("/api/v1/config/sync", handler.SyncConfig)
-	r.POST("/api/v1/instance/list", handler.GetInstanceList)
+	portal := r.Group("", middleware.PortalAuth())
+	portal.POST("/api/v1/user/info", handler.GetUserInfo)
+	portal.POST("/api/v1/user/create", handler.CreateUser)
+	portal.POST("/api/v1/user/password", handler.ChangePassword)
+	portal.POST("/api/v1/app/list", handler.GetAppList)
+	portal.POST("/api/v1/app/detail", handler.GetAppDetail)
+	portal.POST("/api/v1/app/create", handler.CreateApp)
+	portal.POST("/api/v1/app/update", handler.UpdateApp)
+	portal.POST("/api/v1/app/delete", handler.DeleteApp)
+	portal.POST("/api/v1/app/access_key/create", handler.CreateAccessKey)
+	portal.POST("/api/v1/app/access_key/list", handler.GetAccessKeyList)
+	portal.POST("/api/v1/app/access_key/delete", handler.DeleteAccessKey)
+	portal.POST("/api/v1/app/permission/grant", handler.GrantRole)
+	portal.POST("/api/v1/app/permission/revoke", handler.RevokeRole)
+	portal.POST("/api/v1/app/permission/list", handler.GetPermissionList)
+	portal.POST("/api/v1/cluster/list", handler.GetClusterList)
+	portal.POST("/api/v1/cluster/create", handler.CreateCluster)
+	portal.POST("/api/v1/cluster/update", handler.UpdateCluster)
+	portal.POST("/api/v1/cluster/delete", handler.DeleteCluster)
+	portal.POST("/api/v1/namespace/list", handler.GetNamespaceList)
+	portal.POST("/api/v1/namespace/create", handler.CreateNamespace)
+	portal.POST("/api/v1/namespace/update", handler.UpdateNamespace)
+	portal.POST("/api/v1/namespace/delete", handler.DeleteNamespace)
+	portal.POST("/api/v1/namespace/associate", handler.AssociateNamespace)
+	portal.POST("/api/v1/namespace/public/list", handler.GetPublicNamespaceList)
+	portal.POST("/api/v1/namespace/approval", handler.SetNamespaceApproval)
+	portal.POST("/api/v1/config/detail", handler.GetConfigDetail)
+	portal.POST("/api/v1/config/list", handler.GetConfigList)
+	portal.POST("/api/v1/config/search", handler.SearchConfig)
+	portal.POST("/api/v1/config/create", handler.CreateConfig)
+	portal.POST("/api/v1/config/update", handler.UpdateConfig)
+	portal.POST("/api/v1/config/delete", handler.DeleteConfig)
+	portal.POST("/api/v1/config/discard", handler.DiscardConfig)
+	portal.POST("/api/v1/config/batch", handler.BatchConfig)
+	portal.POST("/api/v1/config/history", handler.GetConfigHistory)
+	portal.POST("/api/v1/config/history/key", handler.GetConfigKeyHistory)
+	portal.POST("/api/v1/config/blame", handler.BlameConfig)
+	portal.POST("/api/v1/config/release", handler.ReleaseConfig)
+	portal.POST("/api/v1/config/release/history", handler.GetConfigReleaseHistory)
+	portal.POST("/api/v1/config/release/request/list", handler.GetReleaseRequestList)
+	portal.POST("/api/v1/config/release/request/approve", handler.ApproveReleaseRequest)
+	portal.POST("/api/v1/config/release/request/reject", handler.RejectReleaseRequest)
+	portal.POST("/api/v1/config/release/request/cancel", handler.CancelReleaseRequest)
+	portal.POST("/api/v1/config/release/schedule/create", handler.CreateReleaseSchedule)
+	portal.POST("/api/v1/config/release/schedule/cancel", handler.CancelReleaseSchedule)
+	portal.POST("/api/v1/config/release/schedule/list", handler.GetReleaseScheduleList)
+	portal.POST("/api/v1/config/rollback", handler.RollbackConfig)
+	portal.POST("/api/v1/config/sync", handler.SyncConfig)
+	portal.POST("/api/v1/config/content", handler.GetConfigContent)
+	portal.POST("/api/v1/config/content/save", handler.SaveConfigContent)
+	portal.POST("/api/v1/config/import", handler.ImportConfig)
+	portal.POST("/api/v1/config/export", handler.ExportConfig)
+	portal.POST("/api/v1/config/compare", handler.CompareConfig)
+	portal.POST("/api/v1/config/promote", handler.PromoteConfig)
+	portal.POST("/api/v1/config/gray/release", handler.GrayReleaseConfig)
+	portal.POST("/api/v1/config/gray/promote", handler.PromoteGrayRelease)
+	portal.POST("/api/v1/config/gray/abandon", handler.AbandonGrayRelease)
+	portal.POST("/api/v1/config/gray/detail", handler.GetGrayReleaseDetail)
+	portal.POST("/api/v1/instance/list", handler.GetInstanceList)
+	portal.POST("/api/v1/app/webhook/create", handler.CreateWebhook)
+	portal.POST("/api/v1/app/webhook/update", handler.UpdateWebhook)
+	portal.POST("/api/v1/app/webhook/delete", handler.DeleteWebhook)
+	portal.POST("/api/v1/app/webhook/list", handler.GetWebhookList)
+	portal.POST("/api/v1/app/webhook/test", handler.TestWebhook)
+	portal.POST("/api/v1/app/webhook/delivery/list", handler.GetWebhookDeliveryList)
+	portal.POST("/api/v1/audit/list", handler.GetAuditList)
+	portal.POST("/api/v1/audit/export", handler.ExportAudit)
 
 	conf := local.Conf.Server
 	addr := fmt.Sprintf("%s:%d", conf.ListenHost, conf.ListenPort)
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hackbeex/configcenter/server/auth"
+	"github.com/hackbeex/configcenter/server/model"
+	"github.com/hackbeex/configcenter/server/peer"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/hackbeex/configcenter/util/response"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"net/http"
+	"strings"
+)
+
+const (
+	AccessKeyHeader = "X-Access-Key"
+	RequestIdHeader = "X-Request-Id"
+
+	userIdKey      = "user_id"
+	accessAppIdKey = "access_app_id"
+	allowSecretKey = "allow_secret"
+	requestIdKey   = "request_id"
+)
+
+//authenticate the portal request by the login token in Authorization header
+func PortalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer"))
+		if token == "" {
+			response.Abort(c, http.StatusUnauthorized, errors.New("need login"))
+			return
+		}
+		userId, err := auth.ParseToken(token)
+		if err != nil {
+			log.Warn(err)
+			response.Abort(c, http.StatusUnauthorized, err)
+			return
+		}
+		user := model.UserModel{}
+		if _, err := user.Info(userId); err != nil {
+			response.Abort(c, http.StatusUnauthorized, err)
+			return
+		}
+
+		c.Set(userIdKey, userId)
+		c.Next()
+	}
+}
+
+//authenticate the client request by the access key of the app
+func ClientAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accessKey := model.AccessKeyModel{}
+		info, err := accessKey.Check(c.GetHeader(AccessKeyHeader))
+		if err != nil {
+			log.Warnf("client[%s] auth fail: %s", c.ClientIP(), err)
+			response.Abort(c, http.StatusUnauthorized, err)
+			return
+		}
+
+		c.Set(accessAppIdKey, info.AppId)
+		c.Set(allowSecretKey, info.AllowSecret == 1)
+		c.Next()
+	}
+}
+
+//authenticate the request from the server of another env by the shared peer token
+func PeerAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := peer.CheckToken(c.GetHeader(peer.TokenHeader)); err != nil {
+			log.Warnf("peer[%s] auth fail: %s", c.ClientIP(), err)
+			response.Abort(c, http.StatusUnauthorized, err)
+			return
+		}
+		//the acting user logged in this env, the peer api checks the permission of the user
+		if token := c.GetHeader(peer.UserTokenHeader); token != "" {
+			userId, err := auth.ParseToken(token)
+			if err != nil {
+				log.Warnf("peer[%s] user auth fail: %s", c.ClientIP(), err)
+				response.Abort(c, http.StatusUnauthorized, err)
+				return
+			}
+			user := model.UserModel{}
+			if _, err := user.Info(userId); err != nil {
+				response.Abort(c, http.StatusUnauthorized, err)
+				return
+			}
+			c.Set(userIdKey, userId)
+		}
+		c.Next()
+	}
+}
+
+//the login user of the portal request, or the acting user of the peer request
+func UserId(c *gin.Context) string {
+	return c.GetString(userIdKey)
+}
+
+//the request info recorded with the operations, the request id is generated if not given and returned in the header
+func Request(c *gin.Context) model.RequestInfo {
+	requestId := c.GetString(requestIdKey)
+	if requestId == "" {
+		requestId = c.GetHeader(RequestIdHeader)
+		if requestId == "" || len(requestId) > 64 {
+			requestId = uuid.NewV4().String()
+		}
+		c.Set(requestIdKey, requestId)
+		c.Header(RequestIdHeader, requestId)
+	}
+
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	return model.RequestInfo{
+		RequestId: requestId,
+		ClientIp:  c.ClientIP(),
+		UserAgent: userAgent,
+	}
+}
+
+//the app which the access key of the client request belongs to
+func AccessAppId(c *gin.Context) string {
+	return c.GetString(accessAppIdKey)
+}
+
+//whether the access key of the client request can read the secrets
+func AllowSecret(c *gin.Context) bool {
+	return c.GetBool(allowSecretKey)
+}
@@ -0,0 +1,241 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+//AccessKeyModel manages the keys which the clients of the app use to call the client api
+type AccessKeyModel struct {
+}
+
+//only the hash of the key is stored, the key is shown once when created
+func hashAccessKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type CreateAccessKeyReq struct {
+	AppId       string      `json:"app_id"`
+	Comment     string      `json:"comment"`
+	AllowSecret bool        `json:"allow_secret"` //the clients can read the decrypted secrets
+	UserId      string      `json:"-"`
+	Request     RequestInfo `json:"-"`
+}
+
+func (c *CreateAccessKeyReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.AppId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type CreateAccessKeyResp struct {
+	Id  string `json:"id"`
+	Key string `json:"key"`
+}
+
+func (a *AccessKeyModel) Create(req *CreateAccessKeyReq) (*CreateAccessKeyResp, error) {
+	resp := &CreateAccessKeyResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	var app struct {
+		Id string
+	}
+	db := database.Conn()
+	db = db.Table("app").Select("id").Where("id=? AND is_delete=0", req.AppId).Scan(&app)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if app.Id == "" {
+		return resp, errors.New("the app not exists")
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, req.AppId, "", "", ActionManage); err != nil {
+		return resp, err
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		log.Error(err)
+		return resp, err
+	}
+	key := hex.EncodeToString(buf)
+	allowSecret := 0
+	if req.AllowSecret {
+		allowSecret = 1
+	}
+
+	now := time.Now().Unix()
+	id := uuid.NewV1().String()
+	tx := database.Conn().Begin()
+	tx = database.Insert(tx, "access_key", map[string]interface{}{
+		"id":           id,
+		"app_id":       req.AppId,
+		"key_hash":     hashAccessKey(key),
+		"key_prefix":   key[:8],
+		"comment":      req.Comment,
+		"allow_secret": allowSecret,
+		"create_by":    req.UserId,
+		"create_time":  now,
+		"update_by":    req.UserId,
+		"update_time":  now,
+	})
+	tx = RecordTable(tx, "access_key", "", req.UserId, req.Request, com.OpCreate, nil, id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return resp, errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	resp.Id = id
+	resp.Key = key
+	return resp, nil
+}
+
+type AccessKeyListReq struct {
+	AppId  string `json:"app_id"`
+	UserId string `json:"-"`
+}
+
+func (c *AccessKeyListReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.AppId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type AccessKeyItem struct {
+	Id          string `json:"id"`
+	KeyPrefix   string `json:"key_prefix"`
+	Comment     string `json:"comment"`
+	AllowSecret int    `json:"allow_secret"`
+	CreateBy    string `json:"create_by"`
+	CreateTime  int    `json:"create_time"`
+}
+
+type AccessKeyListResp struct {
+	List []AccessKeyItem `json:"list"`
+}
+
+func (a *AccessKeyModel) List(req *AccessKeyListReq) (*AccessKeyListResp, error) {
+	resp := &AccessKeyListResp{
+		List: []AccessKeyItem{},
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, req.AppId, "", "", ActionView); err != nil {
+		return resp, err
+	}
+
+	db := database.Conn()
+	db = db.Table("access_key").Select("id,key_prefix,comment,allow_secret,create_by,create_time").
+		Where("app_id=? AND is_delete=0", req.AppId).Order("create_time DESC").Find(&resp.List)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	return resp, nil
+}
+
+type DeleteAccessKeyReq struct {
+	Id      string      `json:"id"`
+	UserId  string      `json:"-"`
+	Request RequestInfo `json:"-"`
+}
+
+func (c *DeleteAccessKeyReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Id, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+func (a *AccessKeyModel) Delete(req *DeleteAccessKeyReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	var accessKey struct {
+		Id    string
+		AppId string
+	}
+	db := database.Conn()
+	db = db.Table("access_key").Select("id,app_id").Where("id=? AND is_delete=0", req.Id).Scan(&accessKey)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	if accessKey.Id == "" {
+		return errors.New("the access key not exists")
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, accessKey.AppId, "", "", ActionManage); err != nil {
+		return err
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "access_key", req.Id)
+	tx = database.Update(tx, "access_key", map[string]interface{}{
+		"is_delete":   1,
+		"update_by":   req.UserId,
+		"update_time": time.Now().Unix(),
+	}, "id=?", req.Id)
+	tx = RecordTable(tx, "access_key", "", req.UserId, req.Request, com.OpDelete, before, req.Id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	return nil
+}
+
+type AccessKeyInfo struct {
+	AppId       string
+	AllowSecret int
+}
+
+//the app which the access key belongs to and whether it can read the secrets
+func (a *AccessKeyModel) Check(key string) (*AccessKeyInfo, error) {
+	accessKey := &AccessKeyInfo{}
+	if key == "" {
+		return accessKey, errors.New("need access key")
+	}
+
+	db := database.Conn()
+	db = db.Table("access_key").Select("app_id,allow_secret").Where("key_hash=? AND is_delete=0", hashAccessKey(key)).Scan(accessKey)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return accessKey, errors.Wrap(db.Error, "db error")
+	}
+	if accessKey.AppId == "" {
+		return accessKey, errors.New("invalid access key")
+	}
+	return accessKey, nil
+}
@@ -15,9 +15,10 @@ type AppModel struct {
 }
 
 type CreateAppReq struct {
-	Name    string `json:"name"`
-	Comment string `json:"comment"`
-	UserId  string `json:"user_id"`
+	Name    string      `json:"name"`
+	Comment string      `json:"comment"`
+	UserId  string      `json:"-"`
+	Request RequestInfo `json:"-"`
 }
 
 func (c *CreateAppReq) Validate() error {
@@ -29,7 +30,9 @@ func (c *CreateAppReq) Validate() error {
 }
 
 type CreateAppResp struct {
-	Id string `json:"id"`
+	Id          string `json:"id"`
+	ClusterId   string `json:"cluster_id"`   //the default cluster
+	NamespaceId string `json:"namespace_id"` //the default namespace of the default cluster
 }
 
 func (a *AppModel) Create(req *CreateAppReq) (*CreateAppResp, error) {
@@ -65,7 +68,37 @@ func (a *AppModel) Create(req *CreateAppReq) (*CreateAppResp, error) {
 		"update_by":   req.UserId,
 		"update_time": now,
 	})
-	tx = RecordTable(tx, "app", "", req.UserId, com.OpCreate, id)
+	//every app has the default cluster and namespace
+	clusterId := uuid.NewV1().String()
+	tx = database.Insert(tx, "cluster", map[string]interface{}{
+		"id":          clusterId,
+		"app_id":      id,
+		"name":        DefaultClusterName,
+		"create_by":   req.UserId,
+		"create_time": now,
+		"update_by":   req.UserId,
+		"update_time": now,
+	})
+	namespaceId := uuid.NewV1().String()
+	tx = database.Insert(tx, "namespace", map[string]interface{}{
+		"id":          namespaceId,
+		"app_id":      id,
+		"cluster_id":  clusterId,
+		"name":        DefaultNamespaceName,
+		"format":      FormatProperties,
+		"create_by":   req.UserId,
+		"create_time": now,
+		"update_by":   req.UserId,
+		"update_time": now,
+	})
+	//the creator owns the app
+	permMdl := PermissionModel{}
+	permissionId := uuid.NewV1().String()
+	tx = permMdl.insert(tx, permissionId, req.UserId, id, "", "", RoleOwner, req.UserId)
+	tx = RecordTable(tx, "app", "", req.UserId, req.Request, com.OpCreate, nil, id)
+	tx = RecordTable(tx, "cluster", "", req.UserId, req.Request, com.OpCreate, nil, clusterId)
+	tx = RecordTable(tx, "namespace", "", req.UserId, req.Request, com.OpCreate, nil, namespaceId)
+	tx = RecordTable(tx, "permission", "", req.UserId, req.Request, com.OpCreate, nil, permissionId)
 	if tx.Error != nil {
 		tx.Rollback()
 		log.Error(tx.Error)
@@ -75,18 +108,24 @@ func (a *AppModel) Create(req *CreateAppReq) (*CreateAppResp, error) {
 	}
 
 	resp.Id = id
+	resp.ClusterId = clusterId
+	resp.NamespaceId = namespaceId
 	return resp, nil
 }
 
 type AppListReq struct {
-	Limit  int `json:"limit"`
-	Offset int `json:"offset"`
+	Keyword string `json:"keyword"` //match the name or comment
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+	UserId  string `json:"-"`
 }
 
 func (c *AppListReq) Validate() error {
 	return validation.ValidateStruct(c,
+		validation.Field(&c.Keyword, validation.Length(1, 64)),
 		validation.Field(&c.Limit, validation.Max(100)),
 		validation.Field(&c.Offset, validation.Min(0)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
 	)
 }
 
@@ -120,9 +159,28 @@ func (a *AppModel) List(req *AppListReq) (*AppListResp, error) {
 		req.Limit = 20
 	}
 
+	userMdl := UserModel{}
+	user, err := userMdl.Info(req.UserId)
+	if err != nil {
+		return resp, err
+	}
+
+	//the apps which the user has any permission in
+	where := "is_delete=0"
+	var params []interface{}
+	if user.IsAdmin != 1 {
+		where += " AND EXISTS (SELECT 1 FROM permission p WHERE p.user_id=? AND p.app_id=app.id AND p.is_delete=0)"
+		params = append(params, req.UserId)
+	}
+	if req.Keyword != "" {
+		keyword := "%" + database.EscapeLike(req.Keyword) + "%"
+		where += " AND (name LIKE ? OR comment LIKE ?)"
+		params = append(params, keyword, keyword)
+	}
+
 	db := database.Conn()
 	db = db.Table("app").Select("id,name,comment,create_by,create_time,update_by,update_time").
-		Where("is_delete=0").Offset(req.Offset).Limit(req.Limit).Find(&resp.List)
+		Where(where, params...).Order("name").Offset(req.Offset).Limit(req.Limit).Find(&resp.List)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
 		return resp, errors.Wrap(db.Error, "db error")
@@ -135,7 +193,7 @@ func (a *AppModel) List(req *AppListReq) (*AppListResp, error) {
 	}
 
 	db = database.Conn()
-	db = db.Table("app").Where("is_delete=0").Count(&resp.Total)
+	db = db.Table("app").Where(where, params...).Count(&resp.Total)
 	if db.Error != nil {
 		log.Error(db.Error)
 		return resp, errors.Wrap(db.Error, "db error")
@@ -145,19 +203,24 @@ func (a *AppModel) List(req *AppListReq) (*AppListResp, error) {
 }
 
 type AppDetailReq struct {
-	AppId string `json:"app_id"`
+	AppId  string `json:"app_id"`
+	UserId string `json:"-"`
 }
 
 func (c *AppDetailReq) Validate() error {
 	return validation.ValidateStruct(c,
 		validation.Field(&c.AppId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
 	)
 }
 
 type NamespaceItem struct {
-	Id      string `json:"id"`
-	Name    string `json:"name"`
-	Comment string `json:"comment"`
+	Id                string          `json:"id"`
+	Name              string          `json:"name"`
+	Comment           string          `json:"comment"`
+	Format            NamespaceFormat `json:"format"`
+	IsPublic          int             `json:"is_public"`
+	PublicNamespaceId string          `json:"public_namespace_id"`
 }
 
 type AppDetailResp struct {
@@ -172,6 +235,10 @@ func (a *AppModel) Detail(req *AppDetailReq) (*AppDetailResp, error) {
 		log.Warn(err)
 		return resp, err
 	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, req.AppId, "", "", ActionView); err != nil {
+		return resp, err
+	}
 
 	db := database.Conn()
 	db = db.Table("app").Select("id,name,comment,create_by,create_time,update_by,update_time").
@@ -182,7 +249,7 @@ func (a *AppModel) Detail(req *AppDetailReq) (*AppDetailResp, error) {
 	}
 
 	db = database.Conn()
-	db = db.Table("namespace").Select("id,name,comment").Where("app_id=? AND is_delete=0", req.AppId).Find(&resp.Namespaces)
+	db = db.Table("namespace").Select("id,name,comment,format,is_public,public_namespace_id").Where("app_id=? AND is_delete=0", req.AppId).Find(&resp.Namespaces)
 	if db.Error != nil {
 		log.Error(db.Error)
 		return resp, errors.Wrap(db.Error, "db error")
@@ -190,3 +257,178 @@ func (a *AppModel) Detail(req *AppDetailReq) (*AppDetailResp, error) {
 
 	return resp, nil
 }
+
+type UpdateAppReq struct {
+	AppId   string      `json:"app_id"`
+	Name    string      `json:"name"`
+	Comment string      `json:"comment"`
+	UserId  string      `json:"-"`
+	Request RequestInfo `json:"-"`
+}
+
+func (c *UpdateAppReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.AppId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Name, validation.Required, validation.Length(1, 64)),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//rename the app or change its comment, the clients must use the new name after renamed
+func (a *AppModel) Update(req *UpdateAppReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	app, err := a.getInfo(req.AppId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, req.AppId, "", "", ActionManage); err != nil {
+		return err
+	}
+
+	if req.Name != app.Name {
+		var existApp struct {
+			Id string
+		}
+		db := database.Conn()
+		db = db.Table("app").Select("id").Where("name=? AND is_delete=0", req.Name).Scan(&existApp)
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			log.Error(db.Error)
+			return errors.Wrap(db.Error, "db error")
+		}
+		if existApp.Id != "" {
+			return errors.New("the app name exists")
+		}
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "app", req.AppId)
+	tx = database.Update(tx, "app", map[string]interface{}{
+		"name":        req.Name,
+		"comment":     req.Comment,
+		"update_by":   req.UserId,
+		"update_time": time.Now().Unix(),
+	}, "id=?", req.AppId)
+	tx = RecordTable(tx, "app", "", req.UserId, req.Request, com.OpUpdate, before, req.AppId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	return nil
+}
+
+type DeleteAppReq struct {
+	AppId   string      `json:"app_id"`
+	UserId  string      `json:"-"`
+	Request RequestInfo `json:"-"`
+}
+
+func (c *DeleteAppReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.AppId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//delete the app with its clusters, namespaces, instances, access keys and permissions,
+//the instances of the app can not fetch configs any more
+func (a *AppModel) Delete(req *DeleteAppReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	if _, err := a.getInfo(req.AppId); err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, req.AppId, "", "", ActionManage); err != nil {
+		return err
+	}
+
+	var namespaces []struct {
+		Id string
+	}
+	db := database.Conn()
+	db = db.Table("namespace").Select("id").Where("app_id=? AND is_delete=0", req.AppId).Find(&namespaces)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	namespaceIds := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		namespaceIds = append(namespaceIds, ns.Id)
+	}
+	nsMdl := NamespaceModel{}
+	if len(namespaceIds) > 0 {
+		if err := nsMdl.checkAssociated(namespaceIds, req.AppId); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().Unix()
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "app", req.AppId)
+	tx = nsMdl.deleteCascade(tx, namespaceIds, req.UserId, req.Request)
+	tx = database.Update(tx, "cluster", map[string]interface{}{
+		"is_delete":   1,
+		"delete_id":   gorm.Expr("id"),
+		"update_by":   req.UserId,
+		"update_time": now,
+	}, "app_id=? AND is_delete=0", req.AppId)
+	for _, table := range []string{"access_key", "permission", "webhook"} {
+		tx = database.Update(tx, table, map[string]interface{}{
+			"is_delete":   1,
+			"update_by":   req.UserId,
+			"update_time": now,
+		}, "app_id=? AND is_delete=0", req.AppId)
+	}
+	tx = database.Update(tx, "instance", map[string]interface{}{
+		"is_delete":   1,
+		"update_time": now,
+	}, "app_id=? AND is_delete=0", req.AppId)
+	tx = database.Update(tx, "app", map[string]interface{}{
+		"is_delete":   1,
+		"update_by":   req.UserId,
+		"update_time": now,
+	}, "id=?", req.AppId)
+	tx = RecordTable(tx, "app", "", req.UserId, req.Request, com.OpDelete, before, req.AppId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	log.Infof("user[%s] delete app[%s] with %d namespaces", req.UserId, req.AppId, len(namespaceIds))
+	return nil
+}
+
+type appInfo struct {
+	Id   string
+	Name string
+}
+
+func (a *AppModel) getInfo(appId string) (*appInfo, error) {
+	app := &appInfo{}
+	db := database.Conn()
+	db = db.Table("app").Select("id,name").Where("id=? AND is_delete=0", appId).Scan(app)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return app, errors.Wrap(db.Error, "db error")
+	}
+	if app.Id == "" {
+		return app, errors.New("the app not exists")
+	}
+	return app, nil
+}
@@ -0,0 +1,359 @@
+package model
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"strconv"
+	"time"
+)
+
+//the records exported at most once
+const auditExportMax = 10000
+
+type AuditModel struct {
+}
+
+type AuditFilter struct {
+	AppId     string     `json:"app_id"`     //all apps if empty, admin only
+	Operator  string     `json:"operator"`   //the user id who made the operations
+	TableName string     `json:"table_name"` //app, cluster, namespace, item, release, permission...
+	OpType    com.OpType `json:"op_type"`
+	StartTime int        `json:"start_time"`
+	EndTime   int        `json:"end_time"`
+}
+
+func (c AuditFilter) Validate() error {
+	err := validation.ValidateStruct(&c,
+		validation.Field(&c.AppId, validation.Length(36, 36)),
+		validation.Field(&c.Operator, validation.Length(36, 36)),
+		validation.Field(&c.TableName, validation.Length(1, 32)),
+		validation.Field(&c.OpType, validation.In(com.OpCreate, com.OpUpdate, com.OpDelete)),
+		validation.Field(&c.StartTime, validation.Min(0)),
+		validation.Field(&c.EndTime, validation.Min(0)),
+	)
+	if err != nil {
+		return err
+	}
+	if c.EndTime != 0 && c.EndTime < c.StartTime {
+		return errors.New("end_time is before start_time")
+	}
+	return nil
+}
+
+type AuditListReq struct {
+	AuditFilter
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	UserId string `json:"-"`
+}
+
+func (c *AuditListReq) Validate() error {
+	if err := c.AuditFilter.Validate(); err != nil {
+		return err
+	}
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Limit, validation.Max(100)),
+		validation.Field(&c.Offset, validation.Min(0)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type AuditItem struct {
+	Id           int                    `json:"id"`
+	AppId        string                 `json:"app_id"`
+	TableName    string                 `json:"table_name"`
+	TableId      string                 `json:"table_id"`
+	OpType       com.OpType             `json:"op_type"`
+	Comment      string                 `json:"comment"`
+	CreateBy     string                 `json:"create_by"`
+	CreateByName string                 `json:"create_by_name"`
+	ClientIp     string                 `json:"client_ip"`
+	UserAgent    string                 `json:"user_agent"`
+	RequestId    string                 `json:"request_id"`
+	Before       map[string]interface{} `json:"before"` //null if the row is not recorded before
+	After        map[string]interface{} `json:"after"`
+	CreateTime   int                    `json:"create_time"`
+}
+
+type AuditListResp struct {
+	Offset int         `json:"offset"`
+	Total  int         `json:"total"`
+	List   []AuditItem `json:"list"`
+}
+
+//the records of the operations from the newest, the secrets are masked
+func (a *AuditModel) List(req *AuditListReq) (*AuditListResp, error) {
+	resp := &AuditListResp{
+		List:   []AuditItem{},
+		Offset: -1,
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	if err := a.checkFilter(&req.AuditFilter, req.UserId); err != nil {
+		return resp, err
+	}
+
+	where, params := a.where(&req.AuditFilter)
+	list, err := a.query(where, params, req.Offset, req.Limit)
+	if err != nil {
+		return resp, err
+	}
+	resp.List = list
+
+	if len(resp.List) < req.Limit {
+		resp.Offset = -1
+	} else {
+		resp.Offset = req.Offset + len(resp.List)
+	}
+
+	db := database.Conn()
+	db = db.Table("record t1").Where(where, params...).Count(&resp.Total)
+	if db.Error != nil {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	return resp, nil
+}
+
+type AuditExportFormat string
+
+const (
+	AuditExportCsv  AuditExportFormat = "csv"
+	AuditExportJson AuditExportFormat = "json"
+)
+
+type AuditExportReq struct {
+	AuditFilter
+	Format AuditExportFormat `json:"format"` //csv by default
+	UserId string            `json:"-"`
+}
+
+func (c *AuditExportReq) Validate() error {
+	if err := c.AuditFilter.Validate(); err != nil {
+		return err
+	}
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Format, validation.In(AuditExportCsv, AuditExportJson)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type AuditExportResp struct {
+	Format  AuditExportFormat `json:"format"`
+	Count   int               `json:"count"`
+	Content string            `json:"content"`
+}
+
+//export the records matching the filter from the newest, narrow the filter if there are too many
+func (a *AuditModel) Export(req *AuditExportReq) (*AuditExportResp, error) {
+	resp := &AuditExportResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	if req.Format == "" {
+		req.Format = AuditExportCsv
+	}
+	resp.Format = req.Format
+	if err := a.checkFilter(&req.AuditFilter, req.UserId); err != nil {
+		return resp, err
+	}
+
+	where, params := a.where(&req.AuditFilter)
+	var total int
+	db := database.Conn()
+	db = db.Table("record t1").Where(where, params...).Count(&total)
+	if db.Error != nil {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if total > auditExportMax {
+		return resp, errors.Errorf("%d records to export, narrow the filter to %d at most", total, auditExportMax)
+	}
+
+	list, err := a.query(where, params, 0, auditExportMax)
+	if err != nil {
+		return resp, err
+	}
+	resp.Count = len(list)
+
+	content, err := encodeAudit(list, req.Format)
+	if err != nil {
+		log.Error(err)
+		return resp, err
+	}
+	resp.Content = content
+	return resp, nil
+}
+
+//encode the records to the content of the export format
+func encodeAudit(list []AuditItem, format AuditExportFormat) (string, error) {
+	if format == AuditExportJson {
+		content, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	}
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"id", "time", "app_id", "table_name", "table_id", "op_type", "comment",
+		"operator", "operator_name", "client_ip", "user_agent", "request_id", "before", "after"})
+	for _, item := range list {
+		before, _ := json.Marshal(item.Before)
+		after, _ := json.Marshal(item.After)
+		_ = w.Write([]string{
+			strconv.Itoa(item.Id),
+			time.Unix(int64(item.CreateTime), 0).Format(time.RFC3339),
+			item.AppId,
+			item.TableName,
+			item.TableId,
+			string(item.OpType),
+			item.Comment,
+			item.CreateBy,
+			item.CreateByName,
+			item.ClientIp,
+			item.UserAgent,
+			item.RequestId,
+			string(before),
+			string(after),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+//the records of all apps can only be read by admin, otherwise the user must manage the app
+func (a *AuditModel) checkFilter(filter *AuditFilter, userId string) error {
+	if filter.AppId == "" {
+		userMdl := UserModel{}
+		user, err := userMdl.Info(userId)
+		if err != nil {
+			return err
+		}
+		if user.IsAdmin != 1 {
+			return errors.New("app_id is required unless admin")
+		}
+		return nil
+	}
+	permMdl := PermissionModel{}
+	return permMdl.check(userId, filter.AppId, "", "", ActionManage)
+}
+
+func (a *AuditModel) where(filter *AuditFilter) (string, []interface{}) {
+	where := "t1.is_delete=0"
+	var params []interface{}
+	if filter.AppId != "" {
+		where += " AND t1.app_id=?"
+		params = append(params, filter.AppId)
+	}
+	if filter.Operator != "" {
+		where += " AND t1.create_by=?"
+		params = append(params, filter.Operator)
+	}
+	if filter.TableName != "" {
+		where += " AND t1.table_name=?"
+		params = append(params, filter.TableName)
+	}
+	if filter.OpType != "" {
+		where += " AND t1.op_type=?"
+		params = append(params, filter.OpType)
+	}
+	if filter.StartTime != 0 {
+		where += " AND t1.create_time>=?"
+		params = append(params, filter.StartTime)
+	}
+	if filter.EndTime != 0 {
+		where += " AND t1.create_time<=?"
+		params = append(params, filter.EndTime)
+	}
+	return where, params
+}
+
+func (a *AuditModel) query(where string, params []interface{}, offset, limit int) ([]AuditItem, error) {
+	var records []struct {
+		Id           int
+		AppId        string
+		TableName    string
+		TableId      string
+		OpType       com.OpType
+		Comment      string
+		CreateBy     string
+		CreateByName sql.NullString
+		ClientIp     string
+		UserAgent    string
+		RequestId    string
+		BeforeData   sql.NullString
+		AfterData    sql.NullString
+		CreateTime   int
+	}
+	db := database.Conn()
+	db = db.Table("record t1").
+		Select("t1.id,t1.app_id,t1.table_name,t1.table_id,t1.op_type,t1.comment,t1.create_by,t2.username create_by_name,"+
+			"t1.client_ip,t1.user_agent,t1.request_id,t1.before_data,t1.after_data,t1.create_time").
+		Joins("LEFT JOIN user t2 ON t1.create_by=t2.id").
+		Where(where, params...).Order("t1.id DESC").Offset(offset).Limit(limit).Find(&records)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+
+	list := make([]AuditItem, 0, len(records))
+	for _, r := range records {
+		list = append(list, AuditItem{
+			Id:           r.Id,
+			AppId:        r.AppId,
+			TableName:    r.TableName,
+			TableId:      r.TableId,
+			OpType:       r.OpType,
+			Comment:      r.Comment,
+			CreateBy:     r.CreateBy,
+			CreateByName: r.CreateByName.String,
+			ClientIp:     r.ClientIp,
+			UserAgent:    r.UserAgent,
+			RequestId:    r.RequestId,
+			Before:       maskRecordRow(r.BeforeData.String),
+			After:        maskRecordRow(r.AfterData.String),
+			CreateTime:   r.CreateTime,
+		})
+	}
+	return list, nil
+}
+
+//the recorded row with the secrets masked, nil if not recorded
+func maskRecordRow(data string) map[string]interface{} {
+	if data == "" {
+		return nil
+	}
+	row := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(data), &row); err != nil {
+		log.Warn(err)
+		return nil
+	}
+	for k, v := range row {
+		if s, ok := v.(string); ok {
+			row[k] = maskValue(s)
+		}
+	}
+	return row
+}
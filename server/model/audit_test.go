@@ -0,0 +1,116 @@
+package model
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/hackbeex/configcenter/util/com"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAuditWhere(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		where  string
+		params []interface{}
+	}{
+		{
+			name:  "no filter",
+			where: "t1.is_delete=0",
+		},
+		{
+			name:   "app and operator",
+			filter: AuditFilter{AppId: "app", Operator: "user"},
+			where:  "t1.is_delete=0 AND t1.app_id=? AND t1.create_by=?",
+			params: []interface{}{"app", "user"},
+		},
+		{
+			name:   "table and op",
+			filter: AuditFilter{TableName: "item", OpType: com.OpDelete},
+			where:  "t1.is_delete=0 AND t1.table_name=? AND t1.op_type=?",
+			params: []interface{}{"item", com.OpDelete},
+		},
+		{
+			name:   "time range",
+			filter: AuditFilter{StartTime: 100, EndTime: 200},
+			where:  "t1.is_delete=0 AND t1.create_time>=? AND t1.create_time<=?",
+			params: []interface{}{100, 200},
+		},
+	}
+	for _, tt := range tests {
+		where, params := (&AuditModel{}).where(&tt.filter)
+		if where != tt.where || !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%s: got %s %v, want %s %v", tt.name, where, params, tt.where, tt.params)
+		}
+	}
+}
+
+func TestAuditFilterValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		ok     bool
+	}{
+		{"empty", AuditFilter{}, true},
+		{"unknown op", AuditFilter{OpType: "publish"}, false},
+		{"short app id", AuditFilter{AppId: "app"}, false},
+		{"end before start", AuditFilter{StartTime: 200, EndTime: 100}, false},
+		{"open end", AuditFilter{StartTime: 200}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.filter.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+	}
+}
+
+func TestEncodeAudit(t *testing.T) {
+	list := []AuditItem{
+		{
+			Id:         2,
+			TableName:  "item",
+			TableId:    "i1",
+			OpType:     com.OpUpdate,
+			Comment:    "a, \"quoted\" comment",
+			CreateBy:   "u1",
+			ClientIp:   "10.0.0.1",
+			RequestId:  "r1",
+			Before:     map[string]interface{}{"value": "1"},
+			After:      map[string]interface{}{"value": "2"},
+			CreateTime: 100,
+		},
+		{Id: 1, TableName: "item", TableId: "i1", OpType: com.OpCreate, After: map[string]interface{}{"value": "1"}},
+	}
+
+	content, err := encodeAudit(list, AuditExportCsv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(content)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0][0] != "id" || len(records[1]) != len(records[0]) {
+		t.Fatalf("csv: got %v", records)
+	}
+	if records[1][6] != list[0].Comment || records[1][11] != "r1" || records[1][12] != `{"value":"1"}` || records[1][13] != `{"value":"2"}` {
+		t.Errorf("csv row: got %v", records[1])
+	}
+	if records[2][12] != "null" {
+		t.Errorf("csv row without before: got %v", records[2])
+	}
+
+	content, err = encodeAudit(list, AuditExportJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []AuditItem
+	if err := json.Unmarshal([]byte(content), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].RequestId != "r1" || got[1].Before != nil {
+		t.Errorf("json: got %+v", got)
+	}
+}
@@ -0,0 +1,339 @@
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"strings"
+	"time"
+)
+
+type BatchConfigOp struct {
+	OpType    com.OpType `json:"op_type"`
+	Key       string     `json:"key"`
+	Value     string     `json:"value"`
+	ValueType ValueType  `json:"value_type"` //string by default when create, keep the old type if empty when update
+	ValueRule *ValueRule `json:"value_rule"` //keep the old rule if null when update
+	Comment   string     `json:"comment"`    //keep the old comment if empty when update
+	IsSecret  *bool      `json:"is_secret"`  //keep the old flag if null when update
+	Version   int        `json:"version"`    //the version of the item when read, not checked if 0
+}
+
+func (c BatchConfigOp) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.OpType, validation.Required, validation.In(com.OpCreate, com.OpUpdate, com.OpDelete)),
+		validation.Field(&c.Key, validation.Required, validation.Length(1, 128)),
+		validation.Field(&c.ValueType, valueTypeRule),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+	)
+}
+
+type BatchConfigReq struct {
+	NamespaceId string          `json:"namespace_id"`
+	Message     string          `json:"message"`
+	Ops         []BatchConfigOp `json:"ops"`
+	UserId      string          `json:"-"`
+	Request     RequestInfo     `json:"-"`
+}
+
+func (c *BatchConfigReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Message, validation.Length(1, 255)),
+		validation.Field(&c.Ops, validation.Required, validation.Length(1, 1000)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type BatchConfigResp struct {
+	Create int `json:"create"`
+	Update int `json:"update"`
+	Delete int `json:"delete"`
+}
+
+//apply the operations on the items of the namespace in one transaction, nothing is changed if any of them fails,
+//and the changes are recorded as one commit with the message
+func (c *ConfigModel) Batch(req *BatchConfigReq) (*BatchConfigResp, error) {
+	resp := &BatchConfigResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionEdit); err != nil {
+		return resp, err
+	}
+
+	var items []batchItem
+	db := database.Conn()
+	db = db.Table("item").Select("id,`key`,value,value_type,value_rule,comment,is_secret,is_delete,version").
+		Where("namespace_id=?", req.NamespaceId).Find(&items)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	now := time.Now().Unix()
+	writes, resp, err := c.planBatch(namespace, items, req, now)
+	if err != nil {
+		return resp, err
+	}
+
+	tx := database.Conn().Begin()
+	tx, err = nsMdl.lock(tx, namespace, req.UserId)
+	if err != nil {
+		tx.Rollback()
+		return resp, err
+	}
+	var itemOrderNum struct {
+		MaxOrderNum int
+	}
+	tx = tx.Raw("SELECT MAX(order_num) max_order_num FROM item WHERE namespace_id=? FOR UPDATE", req.NamespaceId).Scan(&itemOrderNum)
+
+	var writeIds []string
+	for _, w := range writes {
+		if w.id != "" {
+			writeIds = append(writeIds, w.id)
+		}
+	}
+	tx, before := selectRows(tx, "item", writeIds...)
+
+	ids := map[com.OpType][]string{}
+	for _, w := range writes {
+		if tx.Error != nil {
+			break
+		}
+		switch {
+		case w.opType == com.OpCreate && w.id == "":
+			itemOrderNum.MaxOrderNum++
+			w.id = uuid.NewV1().String()
+			w.data["id"] = w.id
+			w.data["namespace_id"] = req.NamespaceId
+			w.data["order_num"] = itemOrderNum.MaxOrderNum
+			w.data["create_by"] = req.UserId
+			w.data["create_time"] = now
+			tx = database.Insert(tx, "item", w.data)
+		case w.opType == com.OpCreate:
+			itemOrderNum.MaxOrderNum++
+			w.data["order_num"] = itemOrderNum.MaxOrderNum
+			w.data["version"] = gorm.Expr("version+1")
+			tx = database.Update(tx, "item", w.data, "id=? AND is_delete=1", w.id)
+			if tx.Error == nil && tx.RowsAffected == 0 {
+				tx.Rollback()
+				return resp, errors.Errorf("the config key %s is created by others, refresh and try again", w.data["key"])
+			}
+		default:
+			//the item may be changed by others after read
+			tx = database.Update(tx, "item", w.data, "id=? AND version=? AND is_delete=0", w.id, w.version)
+			if tx.Error == nil && tx.RowsAffected == 0 {
+				tx.Rollback()
+				return resp, errors.New("the configs are changed by others, refresh and try again")
+			}
+		}
+		ids[w.opType] = append(ids[w.opType], w.id)
+	}
+	for op, opIds := range ids {
+		tx = RecordTable(tx, "item", req.Message, req.UserId, req.Request, op, before, opIds...)
+	}
+	tx, commits := c.recordCommit(tx, ids, req.Message, req.UserId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return resp, errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	c.fireCommits(commits, req.Message, req.UserId)
+	return resp, nil
+}
+
+//the item of the namespace read before the batch
+type batchItem struct {
+	Id        string
+	Key       string
+	Value     string
+	ValueType ValueType
+	ValueRule ValueRule
+	Comment   string
+	IsSecret  int
+	IsDelete  int
+	Version   int
+}
+
+//the write of an operation, the id is empty if the item is to be inserted
+type batchWrite struct {
+	id      string
+	opType  com.OpType
+	data    map[string]interface{}
+	version int
+}
+
+//check all the operations on the items before any change, and plan the writes of them
+func (c *ConfigModel) planBatch(namespace *namespaceInfo, items []batchItem, req *BatchConfigReq, now int64) ([]batchWrite, *BatchConfigResp, error) {
+	resp := &BatchConfigResp{}
+	itemMap := map[string]int{}
+	for i, item := range items {
+		itemMap[item.Key] = i
+	}
+
+	var writes []batchWrite
+	opKeys := map[string]bool{}
+	for i, op := range req.Ops {
+		op.Key = strings.TrimSpace(op.Key)
+		if opKeys[op.Key] {
+			return nil, resp, errors.Errorf("ops[%d]: duplicate key %s", i, op.Key)
+		}
+		opKeys[op.Key] = true
+
+		idx, exist := itemMap[op.Key]
+		if exist && items[idx].IsDelete == 1 && op.OpType != com.OpCreate {
+			exist = false
+		}
+		switch op.OpType {
+		case com.OpCreate:
+			if exist && items[idx].IsDelete == 0 {
+				return nil, resp, errors.Errorf("ops[%d]: the config key %s exists", i, op.Key)
+			}
+			isSecret := op.IsSecret != nil && *op.IsSecret
+			createReq := CreateConfigReq{
+				NamespaceId: req.NamespaceId,
+				Key:         op.Key,
+				Value:       op.Value,
+				ValueType:   op.ValueType,
+				Comment:     op.Comment,
+				IsSecret:    isSecret,
+				UserId:      req.UserId,
+			}
+			if op.ValueRule != nil {
+				createReq.ValueRule = *op.ValueRule
+			}
+			if err := c.checkItem(namespace, &createReq); err != nil {
+				return nil, resp, errors.Wrapf(err, "ops[%d]", i)
+			}
+			value, err := sealValue(op.Value, isSecret)
+			if err != nil {
+				return nil, resp, err
+			}
+			secret := 0
+			if isSecret {
+				secret = 1
+			}
+			data := map[string]interface{}{
+				"key":         op.Key,
+				"value":       value,
+				"value_type":  createReq.ValueType,
+				"value_rule":  createReq.ValueRule,
+				"comment":     op.Comment,
+				"is_secret":   secret,
+				"is_delete":   0,
+				"update_by":   req.UserId,
+				"update_time": now,
+			}
+			id := ""
+			if exist {
+				id = items[idx].Id
+			}
+			writes = append(writes, batchWrite{id: id, opType: com.OpCreate, data: data})
+			resp.Create++
+		case com.OpUpdate, com.OpDelete:
+			if !exist {
+				return nil, resp, errors.Errorf("ops[%d]: the config key %s not exists", i, op.Key)
+			}
+			item := items[idx]
+			if op.Version != 0 && op.Version != item.Version {
+				return nil, resp, errors.Errorf("ops[%d]: the config key %s is changed by others, refresh and try again", i, op.Key)
+			}
+			if op.OpType == com.OpDelete {
+				writes = append(writes, batchWrite{id: item.Id, opType: com.OpDelete, version: item.Version, data: map[string]interface{}{
+					"is_delete":   1,
+					"version":     gorm.Expr("version+1"),
+					"update_by":   req.UserId,
+					"update_time": now,
+				}})
+				resp.Delete++
+				continue
+			}
+
+			isSecret := item.IsSecret == 1
+			if op.IsSecret != nil {
+				isSecret = *op.IsSecret
+			}
+			unmasked, err := unmaskValue(op.Value, item.Value, item.IsSecret == 1, isSecret)
+			if err != nil {
+				return nil, resp, errors.Wrapf(err, "ops[%d]", i)
+			}
+			op.Value = unmasked
+			updateReq := CreateConfigReq{
+				NamespaceId: req.NamespaceId,
+				Key:         op.Key,
+				Value:       op.Value,
+				ValueType:   item.ValueType,
+				ValueRule:   item.ValueRule,
+				Comment:     item.Comment,
+				IsSecret:    isSecret,
+				UserId:      req.UserId,
+			}
+			if op.ValueType != "" {
+				updateReq.ValueType = op.ValueType
+			}
+			if op.ValueRule != nil {
+				updateReq.ValueRule = *op.ValueRule
+			}
+			if op.Comment != "" {
+				updateReq.Comment = op.Comment
+			}
+			if err := c.checkItem(namespace, &updateReq); err != nil {
+				return nil, resp, errors.Wrapf(err, "ops[%d]", i)
+			}
+			value, err := sealValue(op.Value, isSecret)
+			if err != nil {
+				return nil, resp, err
+			}
+			secret := 0
+			if isSecret {
+				secret = 1
+			}
+			writes = append(writes, batchWrite{id: item.Id, opType: com.OpUpdate, version: item.Version, data: map[string]interface{}{
+				"value":       value,
+				"value_type":  updateReq.ValueType,
+				"value_rule":  updateReq.ValueRule,
+				"comment":     updateReq.Comment,
+				"is_secret":   secret,
+				"version":     gorm.Expr("version+1"),
+				"update_by":   req.UserId,
+				"update_time": now,
+			}})
+			resp.Update++
+		}
+	}
+
+	return writes, resp, nil
+}
+
+//check the item to write by the rules of create
+func (c *ConfigModel) checkItem(namespace *namespaceInfo, item *CreateConfigReq) error {
+	if err := item.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+	if err := validateDocumentItem(namespace.Format, item.Key, item.Value); err != nil {
+		log.Warn(err)
+		return err
+	}
+	if item.IsSecret && namespace.Format.IsDocument() {
+		return errors.Errorf("%s namespace can not have secret items", namespace.Format)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"github.com/hackbeex/configcenter/util/com"
+	"strings"
+	"testing"
+)
+
+const (
+	testNamespaceId = "00000000-0000-0000-0000-000000000001"
+	testUserId      = "00000000-0000-0000-0000-000000000002"
+)
+
+func testBatchItems() []batchItem {
+	return []batchItem{
+		{Id: "item-port", Key: "port", Value: "8080", ValueType: ValueInt, Comment: "the port", Version: 3},
+		{Id: "item-host", Key: "host", Value: "localhost", ValueType: ValueString, Version: 1},
+		{Id: "item-old", Key: "old", Value: "x", ValueType: ValueString, IsDelete: 1, Version: 2},
+		{Id: "item-token", Key: "token", Value: "enc:v1:local:a2V5:Y3Q=", ValueType: ValueString, IsSecret: 1, Version: 1},
+	}
+}
+
+func TestPlanBatch(t *testing.T) {
+	namespace := &namespaceInfo{Id: testNamespaceId, Format: FormatProperties}
+	req := &BatchConfigReq{
+		NamespaceId: testNamespaceId,
+		UserId:      testUserId,
+		Ops: []BatchConfigOp{
+			{OpType: com.OpCreate, Key: " timeout ", Value: "3s", ValueType: ValueDuration},
+			{OpType: com.OpCreate, Key: "old", Value: "y"},
+			{OpType: com.OpUpdate, Key: "port", Value: "9090", Version: 3},
+			{OpType: com.OpDelete, Key: "host"},
+		},
+	}
+	writes, resp, err := (&ConfigModel{}).planBatch(namespace, testBatchItems(), req, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Create != 2 || resp.Update != 1 || resp.Delete != 1 {
+		t.Errorf("resp: got %+v", resp)
+	}
+	if len(writes) != 4 {
+		t.Fatalf("writes: got %d, want 4", len(writes))
+	}
+
+	create := writes[0]
+	if create.opType != com.OpCreate || create.id != "" || create.data["key"] != "timeout" ||
+		create.data["value_type"] != ValueDuration || create.data["update_time"] != int64(100) {
+		t.Errorf("create new: got %+v", create)
+	}
+	recreate := writes[1]
+	if recreate.opType != com.OpCreate || recreate.id != "item-old" || recreate.data["value"] != "y" ||
+		recreate.data["is_delete"] != 0 || recreate.data["value_type"] != ValueString {
+		t.Errorf("create over the deleted item: got %+v", recreate)
+	}
+	update := writes[2]
+	if update.opType != com.OpUpdate || update.id != "item-port" || update.version != 3 ||
+		update.data["value"] != "9090" || update.data["value_type"] != ValueInt || update.data["comment"] != "the port" {
+		t.Errorf("update keeping the old type and comment: got %+v", update)
+	}
+	del := writes[3]
+	if del.opType != com.OpDelete || del.id != "item-host" || del.version != 1 || del.data["is_delete"] != 1 {
+		t.Errorf("delete: got %+v", del)
+	}
+}
+
+func TestPlanBatchConflict(t *testing.T) {
+	notSecret := false
+	tests := []struct {
+		name   string
+		format NamespaceFormat
+		ops    []BatchConfigOp
+		err    string
+	}{
+		{
+			name: "duplicate key",
+			ops: []BatchConfigOp{
+				{OpType: com.OpUpdate, Key: "port", Value: "1"},
+				{OpType: com.OpDelete, Key: " port"},
+			},
+			err: "duplicate key",
+		},
+		{
+			name: "create existing key",
+			ops:  []BatchConfigOp{{OpType: com.OpCreate, Key: "host", Value: "a"}},
+			err:  "exists",
+		},
+		{
+			name: "update missing key",
+			ops:  []BatchConfigOp{{OpType: com.OpUpdate, Key: "missing", Value: "a"}},
+			err:  "not exists",
+		},
+		{
+			name: "delete deleted key",
+			ops:  []BatchConfigOp{{OpType: com.OpDelete, Key: "old"}},
+			err:  "not exists",
+		},
+		{
+			name: "version changed",
+			ops:  []BatchConfigOp{{OpType: com.OpUpdate, Key: "port", Value: "1", Version: 2}},
+			err:  "changed by others",
+		},
+		{
+			name: "invalid value of the old type",
+			ops:  []BatchConfigOp{{OpType: com.OpUpdate, Key: "port", Value: "abc"}},
+			err:  "ops[0]",
+		},
+		{
+			name:   "document namespace",
+			format: FormatJson,
+			ops:    []BatchConfigOp{{OpType: com.OpCreate, Key: "other", Value: "{}"}},
+			err:    "only has the content item",
+		},
+		{
+			name: "clear the secret flag with the mask",
+			ops:  []BatchConfigOp{{OpType: com.OpUpdate, Key: "token", Value: SecretMask, IsSecret: &notSecret}},
+			err:  "no longer a secret",
+		},
+		{
+			name: "later op fails",
+			ops: []BatchConfigOp{
+				{OpType: com.OpCreate, Key: "new", Value: "a"},
+				{OpType: com.OpDelete, Key: "missing"},
+			},
+			err: "ops[1]",
+		},
+	}
+	for _, tt := range tests {
+		format := tt.format
+		if format == "" {
+			format = FormatProperties
+		}
+		namespace := &namespaceInfo{Id: testNamespaceId, Format: format}
+		req := &BatchConfigReq{NamespaceId: testNamespaceId, UserId: testUserId, Ops: tt.ops}
+		writes, _, err := (&ConfigModel{}).planBatch(namespace, testBatchItems(), req, 100)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+		if writes != nil {
+			t.Errorf("%s: nothing should be written, got %d writes", tt.name, len(writes))
+		}
+	}
+}
@@ -11,14 +11,17 @@ import (
 	"time"
 )
 
+const DefaultClusterName = "default"
+
 type ClusterModel struct {
 }
 
 type CreateClusterReq struct {
-	AppId   string `json:"app_id"`
-	Name    string `json:"name"`
-	Comment string `json:"comment"`
-	UserId  string `json:"user_id"`
+	AppId   string      `json:"app_id"`
+	Name    string      `json:"name"`
+	Comment string      `json:"comment"`
+	UserId  string      `json:"-"`
+	Request RequestInfo `json:"-"`
 }
 
 func (c *CreateClusterReq) Validate() error {
@@ -54,12 +57,16 @@ func (a *ClusterModel) Create(req *CreateClusterReq) (*CreateClusterResp, error)
 	if app.Id == "" {
 		return resp, errors.New("the app not exists")
 	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, req.AppId, "", "", ActionManage); err != nil {
+		return resp, err
+	}
 
 	var existCluster struct {
 		Id string
 	}
 	db = database.Conn()
-	db = db.Table("cluster").Select("id").Where("name=? AND is_delete=0", req.Name).Scan(&existCluster)
+	db = db.Table("cluster").Select("id").Where("app_id=? AND name=? AND is_delete=0", req.AppId, req.Name).Scan(&existCluster)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
 		return resp, errors.Wrap(db.Error, "db error")
@@ -81,9 +88,12 @@ func (a *ClusterModel) Create(req *CreateClusterReq) (*CreateClusterResp, error)
 		"update_by":   req.UserId,
 		"update_time": now,
 	})
-	tx = RecordTable(tx, "cluster", "", req.UserId, com.OpCreate, id)
+	tx = RecordTable(tx, "cluster", "", req.UserId, req.Request, com.OpCreate, nil, id)
 	if tx.Error != nil {
 		tx.Rollback()
+		if database.IsDuplicate(tx.Error) {
+			return resp, errors.New("the cluster name exists")
+		}
 		log.Error(tx.Error)
 		return resp, errors.Wrap(tx.Error, "db error")
 	} else {
@@ -93,3 +103,268 @@ func (a *ClusterModel) Create(req *CreateClusterReq) (*CreateClusterResp, error)
 	resp.Id = id
 	return resp, nil
 }
+
+type UpdateClusterReq struct {
+	ClusterId string      `json:"cluster_id"`
+	Name      string      `json:"name"`
+	Comment   string      `json:"comment"`
+	UserId    string      `json:"-"`
+	Request   RequestInfo `json:"-"`
+}
+
+func (c *UpdateClusterReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.ClusterId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Name, validation.Required, validation.Length(1, 64)),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//rename the cluster or change its comment, the default cluster can not be renamed,
+//the clients must use the new name after renamed
+func (a *ClusterModel) Update(req *UpdateClusterReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	cluster, err := a.getInfo(req.ClusterId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, cluster.AppId, cluster.Id, "", ActionManage); err != nil {
+		return err
+	}
+
+	if req.Name != cluster.Name {
+		if cluster.Name == DefaultClusterName || req.Name == DefaultClusterName {
+			return errors.Errorf("can not rename the cluster from or to %s", DefaultClusterName)
+		}
+		var existCluster struct {
+			Id string
+		}
+		db := database.Conn()
+		db = db.Table("cluster").Select("id").Where("app_id=? AND name=? AND is_delete=0", cluster.AppId, req.Name).Scan(&existCluster)
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			log.Error(db.Error)
+			return errors.Wrap(db.Error, "db error")
+		}
+		if existCluster.Id != "" {
+			return errors.New("the cluster name exists")
+		}
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "cluster", req.ClusterId)
+	tx = database.Update(tx, "cluster", map[string]interface{}{
+		"name":        req.Name,
+		"comment":     req.Comment,
+		"update_by":   req.UserId,
+		"update_time": time.Now().Unix(),
+	}, "id=?", req.ClusterId)
+	tx = RecordTable(tx, "cluster", "", req.UserId, req.Request, com.OpUpdate, before, req.ClusterId)
+	if tx.Error != nil {
+		tx.Rollback()
+		if database.IsDuplicate(tx.Error) {
+			return errors.New("the cluster name exists")
+		}
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	return nil
+}
+
+type DeleteClusterReq struct {
+	ClusterId string      `json:"cluster_id"`
+	UserId    string      `json:"-"`
+	Request   RequestInfo `json:"-"`
+}
+
+func (c *DeleteClusterReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.ClusterId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//delete the cluster with its namespaces and instances, the default cluster is only deleted with the app.
+//the instances of the cluster can not fetch configs any more
+func (a *ClusterModel) Delete(req *DeleteClusterReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	cluster, err := a.getInfo(req.ClusterId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, cluster.AppId, cluster.Id, "", ActionManage); err != nil {
+		return err
+	}
+	if cluster.Name == DefaultClusterName {
+		return errors.Errorf("the %s cluster can not be deleted", DefaultClusterName)
+	}
+
+	var namespaces []struct {
+		Id string
+	}
+	db := database.Conn()
+	db = db.Table("namespace").Select("id").Where("cluster_id=? AND is_delete=0", req.ClusterId).Find(&namespaces)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	namespaceIds := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		namespaceIds = append(namespaceIds, ns.Id)
+	}
+	nsMdl := NamespaceModel{}
+	if len(namespaceIds) > 0 {
+		if err := nsMdl.checkAssociated(namespaceIds, ""); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().Unix()
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "cluster", req.ClusterId)
+	tx = nsMdl.deleteCascade(tx, namespaceIds, req.UserId, req.Request)
+	tx = database.Update(tx, "cluster", map[string]interface{}{
+		"is_delete":   1,
+		"delete_id":   gorm.Expr("id"),
+		"update_by":   req.UserId,
+		"update_time": now,
+	}, "id=?", req.ClusterId)
+	tx = database.Update(tx, "instance", map[string]interface{}{
+		"is_delete":   1,
+		"update_time": now,
+	}, "cluster_id=? AND is_delete=0", req.ClusterId)
+	tx = database.Update(tx, "permission", map[string]interface{}{
+		"is_delete":   1,
+		"update_by":   req.UserId,
+		"update_time": now,
+	}, "cluster_id=? AND is_delete=0", req.ClusterId)
+	tx = RecordTable(tx, "cluster", "", req.UserId, req.Request, com.OpDelete, before, req.ClusterId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	log.Infof("user[%s] delete cluster[%s] with %d namespaces", req.UserId, req.ClusterId, len(namespaceIds))
+	return nil
+}
+
+type clusterInfo struct {
+	Id    string
+	AppId string
+	Name  string
+}
+
+func (a *ClusterModel) getInfo(clusterId string) (*clusterInfo, error) {
+	cluster := &clusterInfo{}
+	db := database.Conn()
+	db = db.Table("cluster").Select("id,app_id,name").Where("id=? AND is_delete=0", clusterId).Scan(cluster)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return cluster, errors.Wrap(db.Error, "db error")
+	}
+	if cluster.Id == "" {
+		return cluster, errors.New("the cluster not exists")
+	}
+	return cluster, nil
+}
+
+type ClusterListReq struct {
+	AppId   string `json:"app_id"`
+	Keyword string `json:"keyword"` //match the name or comment
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+	UserId  string `json:"-"`
+}
+
+func (c *ClusterListReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.AppId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Keyword, validation.Length(1, 64)),
+		validation.Field(&c.Limit, validation.Max(100)),
+		validation.Field(&c.Offset, validation.Min(0)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type ClusterItem struct {
+	Id         string `json:"id"`
+	AppId      string `json:"app_id"`
+	Name       string `json:"name"`
+	Comment    string `json:"comment"`
+	CreateBy   string `json:"create_by"`
+	CreateTime int    `json:"create_time"`
+	UpdateBy   string `json:"update_by"`
+	UpdateTime int    `json:"update_time"`
+}
+
+type ClusterListResp struct {
+	Offset int           `json:"offset"`
+	Total  int           `json:"total"`
+	List   []ClusterItem `json:"list"`
+}
+
+func (a *ClusterModel) List(req *ClusterListReq) (*ClusterListResp, error) {
+	resp := &ClusterListResp{
+		List:   []ClusterItem{},
+		Offset: -1,
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, req.AppId, "", "", ActionView); err != nil {
+		return resp, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	where := "app_id=? AND is_delete=0"
+	params := []interface{}{req.AppId}
+	if req.Keyword != "" {
+		keyword := "%" + database.EscapeLike(req.Keyword) + "%"
+		where += " AND (name LIKE ? OR comment LIKE ?)"
+		params = append(params, keyword, keyword)
+	}
+
+	db := database.Conn()
+	db = db.Table("cluster").Select("id,app_id,name,comment,create_by,create_time,update_by,update_time").
+		Where(where, params...).Order("name").Offset(req.Offset).Limit(req.Limit).Find(&resp.List)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	if len(resp.List) < req.Limit {
+		resp.Offset = -1
+	} else {
+		resp.Offset = req.Offset + len(resp.List)
+	}
+
+	db = database.Conn()
+	db = db.Table("cluster").Where(where, params...).Count(&resp.Total)
+	if db.Error != nil {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,349 @@
+package model
+
+import (
+	"encoding/json"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/core"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/server/kms"
+	"github.com/hackbeex/configcenter/server/peer"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+)
+
+type CompareType string
+
+const (
+	CompareRelease CompareType = "release" //a release of the namespace, the current release if release id is empty
+	CompareWorking CompareType = "working" //the current items of the namespace include the unreleased
+	CompareCluster CompareType = "cluster" //the same namespace in another cluster of the app
+	CompareEnv     CompareType = "env"     //the same namespace on the server of another env
+)
+
+var compareTypeRule = validation.In(CompareRelease, CompareWorking, CompareCluster, CompareEnv)
+
+type CompareTarget struct {
+	Type        CompareType `json:"type"`
+	ReleaseId   string      `json:"release_id"`   //release type only
+	ClusterName string      `json:"cluster_name"` //required by cluster type, the same cluster by default of env type
+	Env         com.EnvType `json:"env"`          //env type only
+	Working     bool        `json:"working"`      //compare the unreleased items of the cluster or env instead of the release
+	PeerToken   string      `json:"peer_token"`   //required by env type, the login token of the user on the server of the env
+}
+
+func (c CompareTarget) Validate() error {
+	err := validation.ValidateStruct(&c,
+		validation.Field(&c.Type, validation.Required, compareTypeRule),
+		validation.Field(&c.ReleaseId, validation.Length(36, 36)),
+		validation.Field(&c.ClusterName, validation.Length(1, 64)),
+		validation.Field(&c.Env, validation.In(com.EnvDev, com.EnvTest, com.EnvProd)),
+	)
+	if err != nil {
+		return err
+	}
+	if c.Type == CompareCluster && c.ClusterName == "" {
+		return errors.New("cluster_name is required to compare with another cluster")
+	}
+	if c.Type == CompareEnv && c.Env == "" {
+		return errors.New("env is required to compare with another env")
+	}
+	if c.Type == CompareEnv && c.PeerToken == "" {
+		return errors.New("peer_token is required to compare with another env")
+	}
+	return nil
+}
+
+type CompareConfigReq struct {
+	NamespaceId string        `json:"namespace_id"`
+	Base        CompareTarget `json:"base"`
+	Target      CompareTarget `json:"target"`
+	UserId      string        `json:"-"`
+}
+
+func (c *CompareConfigReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Base),
+		validation.Field(&c.Target),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type CompareConfigResp struct {
+	BaseReleaseId   string                `json:"base_release_id"`
+	TargetReleaseId string                `json:"target_release_id"`
+	Create          int                   `json:"create"`
+	Update          int                   `json:"update"`
+	Delete          int                   `json:"delete"`
+	Change          map[string]ChangeItem `json:"change"`
+}
+
+//the configs of one side to compare, documents are flattened and the secrets are replaced by their digests
+type ConfigSnapshot struct {
+	ReleaseId string            `json:"release_id"` //empty if the unreleased items
+	Config    map[string]string `json:"config"`
+	Secrets   []string          `json:"secrets"`
+}
+
+//compare the configs of the base and the target, the change is what the target differs from the base
+func (c *ConfigModel) Compare(req *CompareConfigReq) (*CompareConfigResp, error) {
+	resp := &CompareConfigResp{
+		Change: map[string]ChangeItem{},
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
+
+	base, err := c.compareSnapshot(namespace, &req.Base, req.UserId)
+	if err != nil {
+		return resp, err
+	}
+	target, err := c.compareSnapshot(namespace, &req.Target, req.UserId)
+	if err != nil {
+		return resp, err
+	}
+	resp.BaseReleaseId = base.ReleaseId
+	resp.TargetReleaseId = target.ReleaseId
+
+	baseSecrets := map[string]bool{}
+	for _, key := range base.Secrets {
+		baseSecrets[key] = true
+	}
+	targetSecrets := map[string]bool{}
+	for _, key := range target.Secrets {
+		targetSecrets[key] = true
+	}
+	for key, item := range releaseChange(base.Config, target.Config) {
+		if baseSecrets[key] && item.OldValue != "" {
+			item.OldValue = SecretMask
+		}
+		if targetSecrets[key] && item.NewValue != "" {
+			item.NewValue = SecretMask
+		}
+		switch item.Type {
+		case com.OpCreate:
+			resp.Create++
+		case com.OpUpdate:
+			resp.Update++
+		case com.OpDelete:
+			resp.Delete++
+		}
+		resp.Change[key] = item
+	}
+	return resp, nil
+}
+
+func (c *ConfigModel) compareSnapshot(namespace *namespaceInfo, target *CompareTarget, userId string) (*ConfigSnapshot, error) {
+	switch target.Type {
+	case CompareRelease:
+		return c.snapshot(namespace, target.ReleaseId, false)
+	case CompareWorking:
+		return c.snapshot(namespace, "", true)
+	case CompareCluster:
+		other, err := c.findNamespace(namespace.AppId, target.ClusterName, namespace.Name)
+		if err != nil {
+			return nil, err
+		}
+		permMdl := PermissionModel{}
+		if err := permMdl.checkNamespace(userId, other, ActionView); err != nil {
+			return nil, err
+		}
+		return c.snapshot(other, "", target.Working)
+	case CompareEnv:
+		if target.Env == core.GetServer().Env {
+			return nil, errors.New("compare with another cluster in the same env")
+		}
+		peerReq, err := c.peerConfigReq(namespace, target.ClusterName, target.Working)
+		if err != nil {
+			return nil, err
+		}
+		snapshot := &ConfigSnapshot{}
+		if err := peer.Call(target.Env, "/api/v1/peer/config/snapshot", target.PeerToken, peerReq, snapshot); err != nil {
+			return nil, err
+		}
+		if snapshot.Config == nil {
+			snapshot.Config = map[string]string{}
+		}
+		return snapshot, nil
+	default:
+		return nil, errors.Errorf("unsupported compare type %s", target.Type)
+	}
+}
+
+//the configs of the release, or the items if working
+func (c *ConfigModel) snapshot(namespace *namespaceInfo, releaseId string, working bool) (*ConfigSnapshot, error) {
+	snapshot := &ConfigSnapshot{
+		Secrets: []string{},
+	}
+	var config map[string]string
+	if working {
+		itemMap, err := c.getItemConfig(namespace.Id)
+		if err != nil {
+			return snapshot, err
+		}
+		config = itemMap
+	} else if releaseId == "" {
+		lastRelease, err := c.getLastRelease(namespace.Id)
+		if err != nil {
+			return snapshot, err
+		}
+		snapshot.ReleaseId = lastRelease.ReleaseId
+		config = lastRelease.Config
+	} else {
+		var release struct {
+			Id     string
+			Config []byte
+		}
+		db := database.Conn()
+		db = db.Table("`release`").Select("id,config").
+			Where("id=? AND namespace_id=? AND is_delete=0", releaseId, namespace.Id).Scan(&release)
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			log.Error(db.Error)
+			return snapshot, errors.Wrap(db.Error, "db error")
+		}
+		if release.Id == "" {
+			return snapshot, errors.New("the release not exists in the namespace")
+		}
+		if err := json.Unmarshal(release.Config, &config); err != nil {
+			log.Error(err)
+			return snapshot, err
+		}
+		snapshot.ReleaseId = release.Id
+	}
+	if config == nil {
+		config = map[string]string{}
+	}
+
+	flat, _, err := exposeConfig(namespace.Format, config)
+	if err != nil {
+		log.Warn(err)
+		return snapshot, err
+	}
+	snapshot.Config = make(map[string]string, len(flat))
+	for key, val := range flat {
+		if kms.IsSealed(val) {
+			plaintext, err := openValue(val)
+			if err != nil {
+				return snapshot, err
+			}
+			val = peer.Digest(plaintext)
+			snapshot.Secrets = append(snapshot.Secrets, key)
+		}
+		snapshot.Config[key] = val
+	}
+	return snapshot, nil
+}
+
+//the namespace of the same name in the cluster of the app
+func (c *ConfigModel) findNamespace(appId, clusterName, namespaceName string) (*namespaceInfo, error) {
+	var namespace struct {
+		Id string
+	}
+	db := database.Conn()
+	db = db.Table("namespace t1").Select("t1.id").
+		Joins("JOIN cluster t2 ON t2.id=t1.cluster_id AND t2.is_delete=0").
+		Where("t2.app_id=? AND t2.name=? AND t1.name=? AND t1.is_delete=0", appId, clusterName, namespaceName).
+		Scan(&namespace)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+	if namespace.Id == "" {
+		return nil, errors.Errorf("the namespace not exists in cluster %s", clusterName)
+	}
+	nsMdl := NamespaceModel{}
+	return nsMdl.getInfo(namespace.Id)
+}
+
+//the namespace is identified by names on the server of another env
+func (c *ConfigModel) peerConfigReq(namespace *namespaceInfo, clusterName string, working bool) (*PeerConfigReq, error) {
+	var names struct {
+		AppName     string
+		ClusterName string
+	}
+	db := database.Conn()
+	db = db.Table("cluster t1").Select("t1.name AS cluster_name,t2.name AS app_name").
+		Joins("JOIN app t2 ON t2.id=t1.app_id").
+		Where("t1.id=?", namespace.ClusterId).Scan(&names)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+	if clusterName == "" {
+		clusterName = names.ClusterName
+	}
+	return &PeerConfigReq{
+		AppName:       names.AppName,
+		ClusterName:   clusterName,
+		NamespaceName: namespace.Name,
+		Working:       working,
+	}, nil
+}
+
+type PeerConfigReq struct {
+	AppName       string `json:"app_name"`
+	ClusterName   string `json:"cluster_name"`
+	NamespaceName string `json:"namespace_name"`
+	Working       bool   `json:"working"`
+	UserId        string `json:"-"` //the acting user authenticated by this env
+}
+
+func (c *PeerConfigReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.AppName, validation.Required, validation.Length(1, 64)),
+		validation.Field(&c.ClusterName, validation.Required, validation.Length(1, 64)),
+		validation.Field(&c.NamespaceName, validation.Required, validation.Length(1, 64)),
+	)
+}
+
+//the configs requested by the server of another env, on behalf of the user who can view them in this env
+func (c *ConfigModel) PeerSnapshot(req *PeerConfigReq) (*ConfigSnapshot, error) {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return &ConfigSnapshot{}, err
+	}
+	if req.UserId == "" {
+		return &ConfigSnapshot{}, errors.New("need login in this env")
+	}
+
+	namespace, err := c.peerNamespace(req)
+	if err != nil {
+		return &ConfigSnapshot{}, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return &ConfigSnapshot{}, err
+	}
+	return c.snapshot(namespace, "", req.Working)
+}
+
+//the namespace identified by names in the request of another env
+func (c *ConfigModel) peerNamespace(req *PeerConfigReq) (*namespaceInfo, error) {
+	var app struct {
+		Id string
+	}
+	db := database.Conn()
+	db = db.Table("app").Select("id").Where("name=? AND is_delete=0", req.AppName).Scan(&app)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+	if app.Id == "" {
+		return nil, errors.Errorf("the app %s not exists", req.AppName)
+	}
+	return c.findNamespace(app.Id, req.ClusterName, req.NamespaceName)
+}
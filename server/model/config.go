@@ -5,11 +5,13 @@ import (
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/hackbeex/configcenter/server/core"
 	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/server/kms"
 	"github.com/hackbeex/configcenter/util/com"
 	"github.com/hackbeex/configcenter/util/log"
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
+	"sort"
 	"strings"
 	"time"
 )
@@ -19,18 +21,21 @@ type ReleaseOpType string
 const (
 	ReleaseOpNormal   ReleaseOpType = "normal"
 	ReleaseOpRollback ReleaseOpType = "rollback"
+	ReleaseOpGray     ReleaseOpType = "gray"
 )
 
 type ConfigModel struct {
 }
 
 type ConfigDetailReq struct {
-	Id string `json:"id"`
+	Id     string `json:"id"`
+	UserId string `json:"-"`
 }
 
 func (c *ConfigDetailReq) Validate() error {
 	return validation.ValidateStruct(c,
 		validation.Field(&c.Id, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
 	)
 }
 
@@ -39,17 +44,21 @@ type ConfigDetailResp struct {
 }
 
 type ConfigItem struct {
-	Id          string `json:"id"`
-	NamespaceId string `json:"namespace_id"`
-	Key         string `json:"key"`
-	Value       string `json:"value"`
-	Comment     string `json:"comment"`
-	OrderNum    int    `json:"order_num"`
-	IsDelete    int    `json:"is_delete"`
-	CreateBy    string `json:"create_by"`
-	CreateTime  int    `json:"create_time"`
-	UpdateBy    string `json:"update_by"`
-	UpdateTime  int    `json:"update_time"`
+	Id          string    `json:"id"`
+	NamespaceId string    `json:"namespace_id"`
+	Key         string    `json:"key"`
+	Value       string    `json:"value"`
+	ValueType   ValueType `json:"value_type"`
+	ValueRule   ValueRule `json:"value_rule"`
+	Comment     string    `json:"comment"`
+	OrderNum    int       `json:"order_num"`
+	IsSecret    int       `json:"is_secret"`
+	IsDelete    int       `json:"is_delete"`
+	Version     int       `json:"version"` //increased by every update
+	CreateBy    string    `json:"create_by"`
+	CreateTime  int       `json:"create_time"`
+	UpdateBy    string    `json:"update_by"`
+	UpdateTime  int       `json:"update_time"`
 }
 
 func (c *ConfigModel) Detail(req *ConfigDetailReq) (*ConfigDetailResp, error) {
@@ -61,23 +70,35 @@ func (c *ConfigModel) Detail(req *ConfigDetailReq) (*ConfigDetailResp, error) {
 	}
 
 	db := database.Conn()
-	db = db.Table("item").Select("id,namespace_id,`key`,value,comment,order_num,create_by,create_time,update_by,update_time").
+	db = db.Table("item").Select("id,namespace_id,`key`,value,value_type,value_rule,comment,order_num,is_secret,version,create_by,create_time,update_by,update_time").
 		Where("id=? AND is_delete=0", req.Id).Scan(&resp)
 	if db.Error != nil {
 		log.Error(db.Error)
 		return resp, errors.Wrap(db.Error, "db error")
 	}
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(resp.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
 
+	resp.Value = maskValue(resp.Value)
 	return resp, nil
 }
 
 type ConfigListReq struct {
 	NamespaceId string `json:"namespace_id"`
+	UserId      string `json:"-"`
 }
 
 func (c *ConfigListReq) Validate() error {
 	return validation.ValidateStruct(c,
 		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
 	)
 }
 
@@ -88,7 +109,9 @@ type ConfigItemInfo struct {
 }
 
 type ConfigListResp struct {
-	List []ConfigItemInfo `json:"list"`
+	List     []ConfigItemInfo `json:"list"`
+	LockBy   string           `json:"lock_by"` //the user editing the unreleased items
+	LockTime int              `json:"lock_time"`
 }
 
 func (c *ConfigModel) List(req *ConfigListReq) (*ConfigListResp, error) {
@@ -101,52 +124,66 @@ func (c *ConfigModel) List(req *ConfigListReq) (*ConfigListResp, error) {
 		return resp, err
 	}
 
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
+	resp.LockBy, resp.LockTime = namespace.LockBy, namespace.LockTime
+
 	//get current release config
 	release, err := c.getLastRelease(req.NamespaceId)
 	if err != nil {
 		return resp, err
 	}
 
+	var items []ConfigItemInfo
 	db := database.Conn()
-	db = db.Table("item").Select("*, 1 AS is_release").
-		Where("namespace_id=? AND update_time<? AND is_delete=0", req.NamespaceId, release.UpdateTime).
-		Find(&resp.List)
+	db = db.Table("item").Select("*").Where("namespace_id=?", req.NamespaceId).Find(&items)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
 		return resp, errors.Wrap(db.Error, "db error")
 	}
+	resp.List = releaseStatus(items, release.Config)
 
-	//get not release items
-	var unReleaseItems []ConfigItemInfo
-	db = database.Conn()
-	db = db.Table("item").Select("*, 0 AS is_release").
-		Where("namespace_id=? AND update_time > ?", req.NamespaceId, release.UpdateTime).
-		Find(&unReleaseItems)
-	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-		log.Error(db.Error)
-		return resp, errors.Wrap(db.Error, "db error")
+	for i := range resp.List {
+		resp.List[i].Value = maskValue(resp.List[i].Value)
 	}
+	return resp, nil
+}
 
-	for _, item := range unReleaseItems {
-		if _, ok := release.Config[item.Key]; ok {
-			if item.IsDelete == 1 {
-				item.Status = com.OpDelete
-			} else {
-				item.Status = com.OpUpdate
-			}
-		} else if item.IsDelete == 1 {
+//mark the items by the released configs, the released ones come first and then the unreleased changes
+func releaseStatus(items []ConfigItemInfo, config map[string]string) []ConfigItemInfo {
+	list := []ConfigItemInfo{}
+	var unReleaseItems []ConfigItemInfo
+	for _, item := range items {
+		val, released := config[item.Key]
+		switch {
+		case item.IsDelete == 0 && released && val == item.Value:
+			item.IsRelease = 1
+			list = append(list, item)
+			continue
+		case item.IsDelete == 1 && released:
+			item.Status = com.OpDelete
+		case item.IsDelete == 1:
 			continue //unreleased item do not need to show
-		} else {
+		case released:
+			item.Status = com.OpUpdate
+		default:
 			item.Status = com.OpCreate
 		}
-		resp.List = append(resp.List, item)
+		unReleaseItems = append(unReleaseItems, item)
 	}
-
-	return resp, nil
+	return append(list, unReleaseItems...)
 }
 
 type lastRelease struct {
 	Id         string
+	ReleaseId  string
 	UpdateTime int
 	Config     map[string]string
 }
@@ -155,13 +192,14 @@ func (c *ConfigModel) getLastRelease(namespaceId string) (*lastRelease, error) {
 	var resp = &lastRelease{}
 	var release struct {
 		Id         string
+		ReleaseId  string
 		UpdateTime int
 		Config     []byte
 	}
 	db := database.Conn()
-	db = db.Table("release_history t1").Select("t1.id,t2.config,t1.update_time").
+	db = db.Table("release_history t1").Select("t1.id,t1.release_id,t2.config,t1.update_time").
 		Joins("JOIN `release` t2 ON t1.release_id=t2.id AND t2.is_delete=0").
-		Where("t1.namespace_id=? AND t1.op_type=? AND t1.is_delete=0", namespaceId, ReleaseOpNormal).
+		Where("t1.namespace_id=? AND t1.op_type IN (?) AND t1.is_delete=0", namespaceId, []ReleaseOpType{ReleaseOpNormal, ReleaseOpRollback}).
 		Order("t1.update_time DESC").Limit(1).Scan(&release)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
@@ -175,18 +213,113 @@ func (c *ConfigModel) getLastRelease(namespaceId string) (*lastRelease, error) {
 		}
 	}
 	resp.Id = release.Id
+	resp.ReleaseId = release.ReleaseId
 	resp.UpdateTime = release.UpdateTime
 	return resp, nil
 }
 
+//get current configs of the items, include the unreleased
+func (c *ConfigModel) getItemConfig(namespaceId string) (map[string]string, error) {
+	var items []struct {
+		Key   string
+		Value string
+	}
+	db := database.Conn()
+	db = db.Table("item").Select("`key`,value").Where("namespace_id=? AND is_delete=0", namespaceId).Find(&items)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+
+	itemMap := map[string]string{}
+	for _, item := range items {
+		itemMap[item.Key] = item.Value
+	}
+	return itemMap, nil
+}
+
+//document namespace only has the content item which must be a valid document
+func validateDocumentItem(format NamespaceFormat, key, value string) error {
+	if !format.IsDocument() {
+		return nil
+	}
+	if key != ContentKey {
+		return errors.Errorf("%s namespace only has the %s item", format, ContentKey)
+	}
+	return validateContent(format, value)
+}
+
+//check all items by their types and rules before release
+func (c *ConfigModel) validateItems(namespaceId string, format NamespaceFormat) error {
+	return c.validateItemsIn(database.Conn(), namespaceId, format)
+}
+
+//check the items read by the db, which may be in the transaction changing them
+func (c *ConfigModel) validateItemsIn(db *gorm.DB, namespaceId string, format NamespaceFormat) error {
+	var items []struct {
+		Key       string
+		Value     string
+		ValueType ValueType
+		ValueRule ValueRule
+	}
+	db = db.Table("item").Select("`key`,value,value_type,value_rule").Where("namespace_id=? AND is_delete=0", namespaceId).Find(&items)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+
+	var msgs []string
+	for _, item := range items {
+		value, err := openValue(item.Value)
+		if err != nil {
+			return err
+		}
+		if err := validateValue(item.Key, value, item.ValueType, item.ValueRule); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+		if err := validateDocumentItem(format, item.Key, item.Value); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		err := errors.Errorf("invalid configs: %s", strings.Join(msgs, "; "))
+		log.Warn(err)
+		return err
+	}
+	return nil
+}
+
+//get created, updated and deleted configs from old to new
+func diffConfigs(old, new map[string]string) map[com.OpType][]core.ChangeConfig {
+	configs := map[com.OpType][]core.ChangeConfig{}
+	for key, val := range new {
+		if pre, ok := old[key]; !ok {
+			configs[com.OpCreate] = append(configs[com.OpCreate], core.ChangeConfig{Key: key, Value: val})
+		} else if pre != val {
+			configs[com.OpUpdate] = append(configs[com.OpUpdate], core.ChangeConfig{Key: key, Value: val})
+		}
+	}
+	for key, pre := range old {
+		if _, ok := new[key]; !ok {
+			configs[com.OpDelete] = append(configs[com.OpDelete], core.ChangeConfig{Key: key, Value: pre})
+		}
+	}
+	return configs
+}
+
 type ConfigListByAppReq struct {
-	App        string `json:"app"`
-	InstanceId string `json:"instance_id"`
+	App         string      `json:"app"`
+	Cluster     string      `json:"cluster"`
+	Env         com.EnvType `json:"env"`
+	InstanceId  string      `json:"instance_id"`
+	AccessAppId string      `json:"-"` //the app of the access key
+	AllowSecret bool        `json:"-"` //whether the access key can read the secrets
 }
 
 func (c *ConfigListByAppReq) Validate() error {
 	return validation.ValidateStruct(c,
 		validation.Field(&c.App, validation.Required, validation.Length(1, 64)),
+		validation.Field(&c.Cluster, validation.Length(1, 64)),
 	)
 }
 
@@ -196,67 +329,139 @@ type ItemSimple struct {
 }
 type ConfigListByAppItem struct {
 	Namespace NamespaceItem `json:"namespace"`
-	Items     []ItemSimple  `json:"items"`
+	Items     []ItemSimple  `json:"items"`   //the document is flattened to dotted keys
+	Content   string        `json:"content"` //the raw document of the document namespace
 }
 
 type ConfigListByAppResp struct {
 	List []ConfigListByAppItem `json:"list"`
 }
 
+//the cluster which the clients of the app run in, fallback to the default cluster if the app has no such cluster
+func (c *ConfigModel) clientCluster(appName, clusterName string) (*clientCluster, error) {
+	var clusters []struct {
+		AppId     string
+		ClusterId string
+		Name      string
+	}
+	db := database.Conn()
+	db = db.Table("cluster t1").Select("t1.id cluster_id,t1.name,t2.id app_id").
+		Joins("JOIN app t2 ON t1.app_id=t2.id AND t2.name=? AND t2.is_delete=0", appName).
+		Where("t1.name IN (?) AND t1.is_delete=0", []string{clusterName, DefaultClusterName}).Find(&clusters)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+
+	cluster := &clientCluster{}
+	for _, item := range clusters {
+		if item.Name == clusterName || cluster.ClusterId == "" {
+			cluster.AppId = item.AppId
+			cluster.ClusterId = item.ClusterId
+		}
+	}
+	if cluster.ClusterId == "" {
+		return nil, errors.New("app not exists")
+	}
+	return cluster, nil
+}
+
+type clientCluster struct {
+	AppId     string
+	ClusterId string
+}
+
+//the namespaces of the cluster, fallback to the namespaces of default cluster which have different name
 func (c *ConfigModel) ListByApp(req *ConfigListByAppReq) (*ConfigListByAppResp, error) {
 	resp := &ConfigListByAppResp{
 		List: []ConfigListByAppItem{},
 	}
 
-	var app struct {
-		Id string
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
 	}
-	db := database.Conn()
-	db = db.Table("app").Select("id").Where("name=? AND is_delete=0", req.App).Scan(&app)
-	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-		log.Error(db.Error)
-		return resp, errors.Wrap(db.Error, "db error")
+	if req.Cluster == "" {
+		req.Cluster = DefaultClusterName
+	}
+
+	server := core.GetServer()
+	if req.Env != "" && server.Env != req.Env {
+		err := errors.Errorf("server env[%s] is not match instance env[%s]", server.Env, req.Env)
+		log.Warn(err)
+		return resp, err
+	}
+
+	cluster, err := c.clientCluster(req.App, req.Cluster)
+	if err != nil {
+		return resp, err
 	}
-	if app.Id == "" {
-		return resp, errors.New("app name not exists")
+	if cluster.AppId != req.AccessAppId {
+		log.Warnf("access key of app[%s] can not access app[%s]", req.AccessAppId, req.App)
+		return resp, errors.New("the access key can not access the app")
 	}
 
+	var instance struct {
+		Id   string
+		Host string
+	}
 	if req.InstanceId != "" {
-		var instance struct {
-			Id string
-		}
 		db := database.Conn()
-		db = db.Table("instance").Select("id").Where("id=? AND app_id=? AND is_delete=0", req.InstanceId, app.Id).Scan(&instance)
+		db = db.Table("instance").Select("id,host").Where("id=? AND app_id=? AND cluster_id=? AND is_delete=0", req.InstanceId, cluster.AppId, cluster.ClusterId).Scan(&instance)
 		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 			log.Error(db.Error)
 			return resp, errors.Wrap(db.Error, "db error")
 		}
 		if instance.Id == "" {
-			log.Warnf("instance id[%s] not matches app name[%s]", req.InstanceId, req.App)
-			return resp, errors.New("instance id not matches app id")
+			log.Warnf("instance id[%s] not matches app name[%s] and cluster name[%s]", req.InstanceId, req.App, req.Cluster)
+			return resp, errors.New("instance id not matches app id or cluster id")
 		}
 	}
 
-	appMdl := AppModel{}
-	appDetail, err := appMdl.Detail(&AppDetailReq{
-		AppId: app.Id,
-	})
+	nsMdl := NamespaceModel{}
+	namespaces, err := nsMdl.listByCluster(cluster.AppId, cluster.ClusterId)
 	if err != nil {
 		return resp, err
 	}
 
-	var lastReleaseId string
-	var lastUpdateTime int
-	for _, namespace := range appDetail.Namespaces {
+	grayMdl := GrayModel{}
+	releaseIds := map[string]string{}
+	for _, namespace := range namespaces {
 		release, err := c.getLastRelease(namespace.Id)
 		if err != nil {
 			return resp, err
 		}
-		if release.UpdateTime > lastUpdateTime {
-			lastReleaseId = release.Id
+		config, releaseId := release.Config, release.ReleaseId
+
+		//the instance matched by gray rules uses the gray release
+		if instance.Id != "" {
+			gray, err := grayMdl.getActive(namespace.Id)
+			if err != nil {
+				return resp, err
+			}
+			if gray.Id != "" && gray.Rules.Match(instance.Id, instance.Host) {
+				config, releaseId = gray.Config, gray.ReleaseId
+			}
+		}
+		releaseIds[namespace.Id] = releaseId
+
+		//the items of associated namespace override the public ones
+		config, err = c.effectiveConfig(namespace.PublicNamespaceId, config)
+		if err != nil {
+			return resp, err
 		}
-		items := make([]ItemSimple, 0, len(release.Config))
-		for k, v := range release.Config {
+		config, err = revealConfig(config, req.AllowSecret)
+		if err != nil {
+			return resp, err
+		}
+		config, content, err := exposeConfig(namespace.Format, config)
+		if err != nil {
+			log.Error(err)
+			return resp, err
+		}
+
+		items := make([]ItemSimple, 0, len(config))
+		for k, v := range config {
 			items = append(items, ItemSimple{
 				Key:   k,
 				Value: v,
@@ -265,44 +470,14 @@ func (c *ConfigModel) ListByApp(req *ConfigListByAppReq) (*ConfigListByAppResp,
 		resp.List = append(resp.List, ConfigListByAppItem{
 			Namespace: namespace,
 			Items:     items,
+			Content:   content,
 		})
 	}
 
-	if req.InstanceId != "" {
-		now := time.Now().Unix()
-		var release struct {
-			Id               string `json:"id"`
-			ReleaseHistoryId string `json:"release_history_id"`
-		}
-		db := database.Conn()
-		db = db.Table("instance_release").Select("id,release_history_id").Where("instance_id=? AND is_delete=0", req.InstanceId).Scan(&release)
-		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-			log.Error(db.Error)
-			return resp, errors.Wrap(db.Error, "db error")
-		}
-		tx := database.Conn().Begin()
-		if release.Id != "" {
-			if lastReleaseId != release.ReleaseHistoryId {
-				tx = tx.Exec("UPDATE instance_release SET release_history_id=?, update_time=? WHERE id=?", lastReleaseId, now, release.Id)
-				tx = RecordTable(tx, "instance_release", "", "", com.OpUpdate, release.Id)
-			}
-		} else {
-			id := uuid.NewV1().String()
-			tx = database.Insert(tx, "instance_release", map[string]interface{}{
-				"id":                 id,
-				"instance_id":        req.InstanceId,
-				"release_history_id": lastReleaseId,
-				"create_time":        now,
-				"update_time":        now,
-			})
-			tx = RecordTable(tx, "instance_release", "", "", com.OpCreate, id)
-		}
-		if tx.Error != nil {
-			tx.Rollback()
-			log.Error(tx.Error)
-			return resp, errors.Wrap(tx.Error, "db error")
-		} else {
-			tx.Commit()
+	if instance.Id != "" {
+		insMdl := InstanceModel{}
+		if err := insMdl.recordRelease(instance.Id, releaseIds); err != nil {
+			return resp, err
 		}
 	}
 
@@ -310,20 +485,35 @@ func (c *ConfigModel) ListByApp(req *ConfigListByAppReq) (*ConfigListByAppResp,
 }
 
 type CreateConfigReq struct {
-	NamespaceId string `json:"namespace_id"`
-	Key         string `json:"key"`
-	Value       string `json:"value"`
-	Comment     string `json:"comment"`
-	UserId      string `json:"user_id"`
+	NamespaceId string      `json:"namespace_id"`
+	Key         string      `json:"key"`
+	Value       string      `json:"value"`
+	ValueType   ValueType   `json:"value_type"`
+	ValueRule   ValueRule   `json:"value_rule"`
+	Comment     string      `json:"comment"`
+	IsSecret    bool        `json:"is_secret"` //the value is encrypted and masked in portal
+	UserId      string      `json:"-"`
+	Request     RequestInfo `json:"-"`
 }
 
 func (c *CreateConfigReq) Validate() error {
-	return validation.ValidateStruct(c,
+	err := validation.ValidateStruct(c,
 		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
 		validation.Field(&c.Key, validation.Required, validation.Length(1, 128)),
+		validation.Field(&c.ValueType, valueTypeRule),
 		validation.Field(&c.Comment, validation.Length(1, 255)),
 		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
 	)
+	if err != nil {
+		return err
+	}
+	if c.ValueType == "" {
+		c.ValueType = ValueString
+	}
+	if err := c.ValueRule.validate(c.ValueType); err != nil {
+		return errors.Wrap(err, "value_rule")
+	}
+	return validateValue(c.Key, c.Value, c.ValueType, c.ValueRule)
 }
 
 type CreateConfigResp struct {
@@ -340,24 +530,36 @@ func (c *ConfigModel) Create(req *CreateConfigReq) (*CreateConfigResp, error) {
 
 	req.Key = strings.TrimSpace(req.Key)
 
-	var namespace struct {
-		Id string
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
 	}
-	db := database.Conn()
-	db = db.Table("namespace").Select("id").Where("id=? AND is_delete=0", req.NamespaceId).Scan(&namespace)
-	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-		log.Error(db.Error)
-		return resp, errors.Wrap(db.Error, "db error")
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionEdit); err != nil {
+		return resp, err
 	}
-	if namespace.Id == "" {
-		return resp, errors.New("the namespace not exists")
+	if err := validateDocumentItem(namespace.Format, req.Key, req.Value); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	if req.IsSecret && namespace.Format.IsDocument() {
+		return resp, errors.Errorf("%s namespace can not have secret items", namespace.Format)
+	}
+	value, err := sealValue(req.Value, req.IsSecret)
+	if err != nil {
+		return resp, err
+	}
+	isSecret := 0
+	if req.IsSecret {
+		isSecret = 1
 	}
 
 	var existItem struct {
 		Id       string
 		IsDelete int
 	}
-	db = database.Conn()
+	db := database.Conn()
 	db = db.Table("item").Select("id,is_delete").Where("namespace_id=? AND `key`=?", req.NamespaceId, req.Key).Scan(&existItem)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
@@ -373,32 +575,46 @@ func (c *ConfigModel) Create(req *CreateConfigReq) (*CreateConfigResp, error) {
 	}
 	now := time.Now().Unix()
 	tx := database.Conn().Begin()
+	tx, err = nsMdl.lock(tx, namespace, req.UserId)
+	if err != nil {
+		tx.Rollback()
+		return resp, err
+	}
 	var itemOrderNum struct {
 		MaxOrderNum int
 	}
 	tx = tx.Raw("SELECT MAX(order_num) max_order_num FROM item WHERE namespace_id=? FOR UPDATE", req.NamespaceId).Scan(&itemOrderNum)
 	if existItem.IsDelete == 1 {
+		var before tableRows
+		tx, before = selectRows(tx, "item", id)
 		item := map[string]interface{}{
 			"id":           id,
 			"namespace_id": req.NamespaceId,
 			"key":          req.Key,
-			"value":        req.Value,
+			"value":        value,
+			"value_type":   req.ValueType,
+			"value_rule":   req.ValueRule,
 			"comment":      req.Comment,
 			"order_num":    itemOrderNum.MaxOrderNum + 1,
+			"is_secret":    isSecret,
 			"is_delete":    0,
+			"version":      gorm.Expr("version+1"),
 			"update_by":    req.UserId,
 			"update_time":  now,
 		}
 		tx = database.Update(tx, "item", item, "id=?", id)
-		tx = RecordTable(tx, "item", "", req.UserId, com.OpUpdate, id)
+		tx = RecordTable(tx, "item", "", req.UserId, req.Request, com.OpUpdate, before, id)
 	} else {
 		item := map[string]interface{}{
 			"id":           id,
 			"namespace_id": req.NamespaceId,
 			"key":          req.Key,
-			"value":        req.Value,
+			"value":        value,
+			"value_type":   req.ValueType,
+			"value_rule":   req.ValueRule,
 			"comment":      req.Comment,
 			"order_num":    itemOrderNum.MaxOrderNum + 1,
+			"is_secret":    isSecret,
 			"is_delete":    0,
 			"create_by":    req.UserId,
 			"create_time":  now,
@@ -406,8 +622,9 @@ func (c *ConfigModel) Create(req *CreateConfigReq) (*CreateConfigResp, error) {
 			"update_time":  now,
 		}
 		tx = database.Insert(tx, "item", item)
-		tx = RecordTable(tx, "item", "", req.UserId, com.OpCreate, id)
+		tx = RecordTable(tx, "item", "", req.UserId, req.Request, com.OpCreate, nil, id)
 	}
+	tx, commits := c.recordItem(tx, com.OpCreate, req.UserId, id)
 	if tx.Error != nil {
 		tx.Rollback()
 		log.Error(tx.Error)
@@ -416,65 +633,100 @@ func (c *ConfigModel) Create(req *CreateConfigReq) (*CreateConfigResp, error) {
 		tx.Commit()
 	}
 
-	c.recordItem(com.OpCreate, req.UserId, id)
+	c.fireCommits(commits, "", req.UserId)
 	resp.Id = id
 	return resp, nil
 }
 
-func (c *ConfigModel) recordItem(opType com.OpType, userId string, itemId ...string) {
-	if len(itemId) == 0 {
-		log.Warn("no itemId to recordItem")
-		return
+func (c *ConfigModel) recordItem(tx *gorm.DB, opType com.OpType, userId string, itemId ...string) (*gorm.DB, map[string]CommitItem) {
+	return c.recordCommit(tx, map[com.OpType][]string{opType: itemId}, "", userId)
+}
+
+//record the changed items of each namespace as one commit in the transaction of the change
+func (c *ConfigModel) recordCommit(tx *gorm.DB, ids map[com.OpType][]string, message, userId string) (*gorm.DB, map[string]CommitItem) {
+	var itemIds []string
+	opTypes := map[string]com.OpType{}
+	for op, opIds := range ids {
+		for _, id := range opIds {
+			itemIds = append(itemIds, id)
+			opTypes[id] = op
+		}
+	}
+	if len(itemIds) == 0 || tx.Error != nil {
+		return tx, nil
 	}
 
+	//read in the transaction to get the changed items, by a new search as the tx may carry the conditions of the last query
 	var items []ConfigItem
-	db := database.Conn()
-	db = db.Table("item").Select("*").Where("id IN (?)", itemId).Find(&items)
-	if db.Error != nil {
-		log.Error(db.Error)
-		return
+	if db := tx.New().Table("item").Select("*").Where("id IN (?)", itemIds).Find(&items); db.Error != nil {
+		return db, nil
 	}
-	itemMap := map[string][]ConfigItem{}
+	commits := map[string]CommitItem{}
 	for _, item := range items {
-		itemMap[item.NamespaceId] = append(itemMap[item.NamespaceId], item)
+		if commits[item.NamespaceId] == nil {
+			commits[item.NamespaceId] = CommitItem{}
+		}
+		op := opTypes[item.Id]
+		commits[item.NamespaceId][op] = append(commits[item.NamespaceId][op], item)
 	}
 
 	now := time.Now().Unix()
-	tx := database.Conn().Begin()
-	for nsId, nsItems := range itemMap {
-		data, _ := json.Marshal(CommitItem{
-			opType: nsItems,
-		})
+	for nsId, sets := range commits {
+		data, _ := json.Marshal(sets)
 		tx = database.Insert(tx, "commit", map[string]interface{}{
 			"id":           uuid.NewV1().String(),
 			"namespace_id": nsId,
 			"change_sets":  data,
+			"message":      message,
 			"create_by":    userId,
 			"create_time":  now,
 			"update_by":    userId,
 			"update_time":  now,
 		})
 	}
-	if tx.Error != nil {
-		tx.Rollback()
-		log.Error(tx.Error)
-	} else {
-		tx.Commit()
+	return tx, commits
+}
+
+//fire the item events of the commits after the transaction is committed
+func (c *ConfigModel) fireCommits(commits map[string]CommitItem, message, userId string) {
+	nsMdl := NamespaceModel{}
+	hookMdl := WebhookModel{}
+	for nsId, sets := range commits {
+		namespace, err := nsMdl.getInfo(nsId)
+		if err != nil {
+			continue
+		}
+		for op, items := range sets {
+			keys := make([]string, 0, len(items))
+			for _, item := range items {
+				keys = append(keys, item.Key)
+			}
+			hookMdl.fire(namespace, itemEvents[op], "", userId, map[string]interface{}{
+				"message": message,
+				"keys":    keys,
+			})
+		}
 	}
 }
 
 type UpdateConfigReq struct {
-	Id      string `json:"id"`
-	Key     string `json:"key"`
-	Value   string `json:"value"`
-	Comment string `json:"comment"`
-	UserId  string `json:"user_id"`
+	Id        string      `json:"id"`
+	Key       string      `json:"key"`
+	Value     string      `json:"value"`
+	ValueType ValueType   `json:"value_type"` //keep the old type if empty
+	ValueRule *ValueRule  `json:"value_rule"` //keep the old rule if null
+	Comment   string      `json:"comment"`
+	IsSecret  *bool       `json:"is_secret"` //keep the old flag if null
+	Version   int         `json:"version"`   //the version of the item when read, refuse if it is changed since then, not checked if 0
+	UserId    string      `json:"-"`
+	Request   RequestInfo `json:"-"`
 }
 
 func (c *UpdateConfigReq) Validate() error {
 	return validation.ValidateStruct(c,
 		validation.Field(&c.Id, validation.Required, validation.Length(36, 36)),
 		validation.Field(&c.Key, validation.Required, validation.Length(1, 128)),
+		validation.Field(&c.ValueType, valueTypeRule),
 		validation.Field(&c.Comment, validation.Length(1, 255)),
 		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
 	)
@@ -492,11 +744,15 @@ func (c *ConfigModel) Update(req *UpdateConfigReq) error {
 		Id          string
 		Key         string
 		Value       string
+		ValueType   ValueType
+		ValueRule   ValueRule
 		Comment     string
+		IsSecret    int
 		NamespaceId string
+		Version     int
 	}
 	db := database.Conn()
-	db = db.Table("item").Select("id,`key`,value,comment,namespace_id").Where("id=? AND is_delete=0", req.Id).Scan(&oldItem)
+	db = db.Table("item").Select("id,`key`,value,value_type,value_rule,comment,is_secret,namespace_id,version").Where("id=? AND is_delete=0", req.Id).Scan(&oldItem)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
 		return errors.Wrap(db.Error, "db error")
@@ -507,22 +763,95 @@ func (c *ConfigModel) Update(req *UpdateConfigReq) error {
 	if oldItem.Key != req.Key {
 		return errors.New("the key not the same as before")
 	}
-	if oldItem.Value == req.Value && req.Comment == oldItem.Comment {
+	if req.Version != 0 && req.Version != oldItem.Version {
+		return errors.New("the item is changed by others, refresh and try again")
+	}
+
+	oldValue, err := openValue(oldItem.Value)
+	if err != nil {
+		return err
+	}
+	isSecret := oldItem.IsSecret == 1
+	if req.IsSecret != nil {
+		isSecret = *req.IsSecret
+	}
+	req.Value, err = unmaskValue(req.Value, oldItem.Value, oldItem.IsSecret == 1, isSecret)
+	if err != nil {
+		return err
+	}
+
+	valueType, valueRule := oldItem.ValueType, oldItem.ValueRule
+	if req.ValueType != "" {
+		valueType = req.ValueType
+	}
+	if req.ValueRule != nil {
+		valueRule = *req.ValueRule
+		if err := valueRule.validate(valueType); err != nil {
+			return errors.Wrap(err, "value_rule")
+		}
+	}
+	if err := validateValue(req.Key, req.Value, valueType, valueRule); err != nil {
+		log.Warn(err)
+		return err
+	}
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(oldItem.NamespaceId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionEdit); err != nil {
+		return err
+	}
+	if err := validateDocumentItem(namespace.Format, req.Key, req.Value); err != nil {
+		log.Warn(err)
+		return err
+	}
+	if isSecret && namespace.Format.IsDocument() {
+		return errors.Errorf("%s namespace can not have secret items", namespace.Format)
+	}
+	oldRule, _ := oldItem.ValueRule.Value()
+	newRule, _ := valueRule.Value()
+	if oldValue == req.Value && req.Comment == oldItem.Comment && oldItem.ValueType == valueType && oldRule == newRule &&
+		(oldItem.IsSecret == 1) == isSecret {
 		return nil
 	}
+	value, err := sealValue(req.Value, isSecret)
+	if err != nil {
+		return err
+	}
 
 	now := time.Now().Unix()
 	item := map[string]interface{}{
 		"id":          req.Id,
 		"key":         req.Key,
-		"value":       req.Value,
+		"value":       value,
+		"value_type":  valueType,
+		"value_rule":  valueRule,
 		"comment":     req.Comment,
+		"is_secret":   0,
+		"version":     gorm.Expr("version+1"),
 		"update_by":   req.UserId,
 		"update_time": now,
 	}
+	if isSecret {
+		item["is_secret"] = 1
+	}
 	tx := database.Conn().Begin()
-	tx = database.Update(tx, "item", item, "id=?", req.Id)
-	tx = RecordTable(tx, "item", "", req.UserId, com.OpUpdate, req.Id)
+	tx, before := selectRows(tx, "item", req.Id)
+	tx, err = nsMdl.lock(tx, namespace, req.UserId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	//the item may be updated by others after read
+	tx = database.Update(tx, "item", item, "id=? AND version=?", req.Id, oldItem.Version)
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("the item is changed by others, refresh and try again")
+	}
+	tx = RecordTable(tx, "item", "", req.UserId, req.Request, com.OpUpdate, before, req.Id)
+	tx, commits := c.recordItem(tx, com.OpUpdate, req.UserId, req.Id)
 	if tx.Error != nil {
 		tx.Rollback()
 		log.Error(tx.Error)
@@ -531,13 +860,14 @@ func (c *ConfigModel) Update(req *UpdateConfigReq) error {
 		tx.Commit()
 	}
 
-	c.recordItem(com.OpUpdate, req.UserId, req.Id)
+	c.fireCommits(commits, "", req.UserId)
 	return nil
 }
 
 type DeleteConfigReq struct {
-	Id     string `json:"id"`
-	UserId string `json:"user_id"`
+	Id      string      `json:"id"`
+	UserId  string      `json:"-"`
+	Request RequestInfo `json:"-"`
 }
 
 func (c *DeleteConfigReq) Validate() error {
@@ -566,17 +896,34 @@ func (c *ConfigModel) Delete(req *DeleteConfigReq) error {
 	if oldItem.Id == "" {
 		return errors.New("the item not exists")
 	}
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(oldItem.NamespaceId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionEdit); err != nil {
+		return err
+	}
 
 	now := time.Now().Unix()
 	item := map[string]interface{}{
 		"id":          req.Id,
 		"is_delete":   1,
+		"version":     gorm.Expr("version+1"),
 		"update_by":   req.UserId,
 		"update_time": now,
 	}
 	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "item", req.Id)
+	tx, err = nsMdl.lock(tx, namespace, req.UserId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 	tx = database.Update(tx, "item", item, "id=?", req.Id)
-	tx = RecordTable(tx, "item", "", req.UserId, com.OpDelete, req.Id)
+	tx = RecordTable(tx, "item", "", req.UserId, req.Request, com.OpDelete, before, req.Id)
+	tx, commits := c.recordItem(tx, com.OpDelete, req.UserId, req.Id)
 	if tx.Error != nil {
 		tx.Rollback()
 		log.Error(tx.Error)
@@ -585,7 +932,7 @@ func (c *ConfigModel) Delete(req *DeleteConfigReq) error {
 		tx.Commit()
 	}
 
-	c.recordItem(com.OpDelete, req.UserId, req.Id)
+	c.fireCommits(commits, "", req.UserId)
 	return nil
 }
 
@@ -593,6 +940,7 @@ type ConfigHistoryReq struct {
 	NamespaceId string `json:"namespace_id"`
 	Limit       int    `json:"limit"`
 	Offset      int    `json:"offset"`
+	UserId      string `json:"-"`
 }
 
 func (c *ConfigHistoryReq) Validate() error {
@@ -600,20 +948,29 @@ func (c *ConfigHistoryReq) Validate() error {
 		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
 		validation.Field(&c.Limit, validation.Max(100)),
 		validation.Field(&c.Offset, validation.Min(0)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
 	)
 }
 
 type CommitItem map[com.OpType][]ConfigItem
 
+type CommitInfo struct {
+	Id         string     `json:"id"`
+	Message    string     `json:"message"`
+	ChangeSets CommitItem `json:"change_sets"`
+	CreateBy   string     `json:"create_by"`
+	CreateTime int        `json:"create_time"`
+}
+
 type ConfigHistoryResp struct {
-	List   []CommitItem `json:"list"`
+	List   []CommitInfo `json:"list"`
 	Offset int          `json:"offset"`
 	Total  int          `json:"total"`
 }
 
 func (c *ConfigModel) GetHistory(req *ConfigHistoryReq) (*ConfigHistoryResp, error) {
 	resp := &ConfigHistoryResp{
-		List:   []CommitItem{},
+		List:   []CommitInfo{},
 		Offset: -1,
 	}
 
@@ -621,16 +978,28 @@ func (c *ConfigModel) GetHistory(req *ConfigHistoryReq) (*ConfigHistoryResp, err
 		log.Warn(err)
 		return resp, err
 	}
-	if req.Limit <= 0 {
-		req.Limit = 20
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
 	}
 
 	var commits []struct {
 		Id         string
+		Message    string
 		ChangeSets []byte
+		CreateBy   string
+		CreateTime int
 	}
 	db := database.Conn()
-	db = db.Table("commit").Select("id,change_sets").Where("namespace_id=? AND is_delete=0", req.NamespaceId).
+	db = db.Table("commit").Select("id,message,change_sets,create_by,create_time").Where("namespace_id=? AND is_delete=0", req.NamespaceId).
 		Order("update_time DESC").Limit(req.Limit).Offset(req.Offset).Find(&commits)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
@@ -643,7 +1012,18 @@ func (c *ConfigModel) GetHistory(req *ConfigHistoryReq) (*ConfigHistoryResp, err
 			log.Error(err)
 			continue
 		}
-		resp.List = append(resp.List, sets)
+		for _, items := range sets {
+			for i := range items {
+				items[i].Value = maskValue(items[i].Value)
+			}
+		}
+		resp.List = append(resp.List, CommitInfo{
+			Id:         cm.Id,
+			Message:    cm.Message,
+			ChangeSets: sets,
+			CreateBy:   cm.CreateBy,
+			CreateTime: cm.CreateTime,
+		})
 	}
 
 	if len(resp.List) < req.Limit {
@@ -663,10 +1043,16 @@ func (c *ConfigModel) GetHistory(req *ConfigHistoryReq) (*ConfigHistoryResp, err
 }
 
 type ReleaseConfigReq struct {
-	NamespaceId string `json:"namespace_id"`
-	Name        string `json:"name"`
-	Comment     string `json:"comment"`
-	UserId      string `json:"user_id"`
+	NamespaceId string      `json:"namespace_id"`
+	Name        string      `json:"name"`
+	Comment     string      `json:"comment"`
+	UserId      string      `json:"-"`
+	Request     RequestInfo `json:"-"`
+}
+
+type ReleaseConfigResp struct {
+	ReleaseId string `json:"release_id"`
+	RequestId string `json:"request_id"` //the pending release request if the namespace needs approval
 }
 
 func (c *ReleaseConfigReq) Validate() error {
@@ -678,147 +1064,195 @@ func (c *ReleaseConfigReq) Validate() error {
 	)
 }
 
-func (c *ConfigModel) Release(req *ReleaseConfigReq) error {
+func (c *ConfigModel) Release(req *ReleaseConfigReq) (*ReleaseConfigResp, error) {
+	resp := &ReleaseConfigResp{}
+
 	if err := req.Validate(); err != nil {
 		log.Warn(err)
-		return err
+		return resp, err
 	}
 
-	var namespace struct {
-		Id        string
-		AppId     string
-		ClusterId string
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
 	}
-	db := database.Conn()
-	db = db.Table("namespace").Select("id,app_id,cluster_id").Where("id=? AND is_delete=0", req.NamespaceId).Scan(&namespace)
-	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-		log.Error(db.Error)
-		return errors.Wrap(db.Error, "db error")
+	//the release of the namespace which needs approval is requested by the editor, and approved by another releaser
+	action := ActionRelease
+	if namespace.NeedApproval == 1 {
+		action = ActionEdit
 	}
-	if namespace.Id == "" {
-		return errors.New("the namespace not exists")
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, action); err != nil {
+		return resp, err
+	}
+	if err := nsMdl.checkLockOwner(namespace, req.UserId); err != nil {
+		return resp, err
+	}
+
+	grayMdl := GrayModel{}
+	gray, err := grayMdl.getActive(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	if gray.Id != "" {
+		return resp, errors.New("the namespace has an active gray release, promote or abandon it first")
 	}
 
 	//get current release config
 	lastRelease, err := c.getLastRelease(req.NamespaceId)
 	if err != nil {
-		return err
+		return resp, err
 	}
 
-	var preReleaseId string
-	var lastHistory struct {
-		ReleaseId string
-		OpType    ReleaseOpType
-	}
-	db = database.Conn()
-	db = db.Table("release_history").Select("release_id,op_type").Where("namespace_id=? AND is_delete=0", req.NamespaceId).
-		Order("update_time DESC").Limit(1).Scan(&lastHistory)
-	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-		log.Error(db.Error)
-		return errors.Wrap(db.Error, "db error")
+	//the items are unreleased if they differ from the release, the time of them may be in the same second
+	itemMap, err := c.getItemConfig(req.NamespaceId)
+	if err != nil {
+		return resp, err
 	}
-	if lastHistory.OpType == ReleaseOpNormal {
-		preReleaseId = lastHistory.ReleaseId
-	} else { //ReleaseOpRollback
-		var lastReleaseHistory struct {
-			PreReleaseId string
-		}
-		db = database.Conn()
-		db = db.Table("release_history").Select("pre_release_id").
-			Where("release_id=? AND op_type=?", lastHistory.ReleaseId, ReleaseOpNormal).
-			Order("update_time DESC").Limit(1).Scan(&lastReleaseHistory)
-		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-			log.Error(db.Error)
-			return errors.Wrap(db.Error, "db error")
-		}
-		preReleaseId = lastReleaseHistory.PreReleaseId
+	if len(diffConfigs(lastRelease.Config, itemMap)) == 0 {
+		return resp, errors.New("no new configs to release")
 	}
 
-	//get not release items
-	var unReleaseItem struct {
-		Id string
-	}
-	db = database.Conn()
-	db = db.Table("item").Select("id").
-		Where("namespace_id=? AND update_time > ?", req.NamespaceId, lastRelease.UpdateTime).
-		Limit(1).Scan(&unReleaseItem)
-	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-		log.Error(db.Error)
-		return errors.Wrap(db.Error, "db error")
+	if err := c.validateItems(req.NamespaceId, namespace.Format); err != nil {
+		return resp, err
 	}
-	if unReleaseItem.Id == "" {
-		return errors.New("no new configs to release")
+	if namespace.NeedApproval == 1 {
+		reqMdl := ReleaseRequestModel{}
+		resp.RequestId, err = reqMdl.create(namespace, itemMap, req.Name, req.Comment, req.UserId, req.Request)
+		return resp, err
 	}
 
-	var items []struct {
-		Key   string
-		Value string
-	}
-	db = database.Conn()
-	db = db.Table("item").Select("`key`,value").Where("namespace_id=? AND is_delete=0", req.NamespaceId).Find(&items)
-	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-		log.Error(db.Error)
-		return errors.Wrap(db.Error, "db error")
-	}
+	resp.ReleaseId, err = c.publish(namespace, namespace.Version, lastRelease, itemMap, req.Name, req.Comment, req.UserId, req.Request)
+	return resp, err
+}
 
-	itemMap := map[string]string{}
-	for _, item := range items {
-		itemMap[item.Key] = item.Value
-	}
+//write the release of the configs and notify the instances, the version is of the namespace when the configs are read,
+//refuse if the items are changed since then
+func (c *ConfigModel) publish(namespace *namespaceInfo, version int, lastRelease *lastRelease, itemMap map[string]string, name, comment, userId string, request RequestInfo) (string, error) {
+	nsMdl := NamespaceModel{}
 	config, _ := json.Marshal(itemMap)
 
 	now := time.Now().Unix()
 	id := uuid.NewV1().String()
 	release := map[string]interface{}{
 		"id":           id,
-		"name":         req.Name,
-		"comment":      req.Comment,
+		"name":         name,
+		"comment":      comment,
 		"app_id":       namespace.AppId,
 		"cluster_id":   namespace.ClusterId,
-		"namespace_id": req.NamespaceId,
+		"namespace_id": namespace.Id,
 		"config":       config,
-		"create_by":    req.UserId,
+		"create_by":    userId,
 		"create_time":  now,
-		"update_by":    req.UserId,
+		"update_by":    userId,
 		"update_time":  now,
 	}
-	historyId := uuid.NewV1().String()
-	releaseHistory := map[string]interface{}{
-		"id":             historyId,
-		"app_id":         namespace.AppId,
-		"namespace_id":   req.NamespaceId,
-		"cluster_id":     namespace.ClusterId,
-		"release_id":     id,
-		"pre_release_id": preReleaseId,
-		"op_type":        ReleaseOpNormal,
-		"create_by":      req.UserId,
-		"create_time":    now,
-		"update_by":      req.UserId,
-		"update_time":    now,
-	}
 
 	tx := database.Conn().Begin()
+	tx, err := nsMdl.lock(tx, namespace, nsMdl.lockOwner(namespace, userId))
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	if err := nsMdl.checkVersion(tx, namespace.Id, version); err != nil {
+		tx.Rollback()
+		return "", err
+	}
 	tx = database.Insert(tx, "`release`", release)
-	tx = database.Insert(tx, "release_history", releaseHistory)
-	tx = RecordTable(tx, "release", "", req.UserId, com.OpCreate, id)
-	tx = RecordTable(tx, "release_history", "", req.UserId, com.OpCreate, historyId)
+	tx = RecordTable(tx, "release", "", userId, request, com.OpCreate, nil, id)
+	tx = c.insertHistory(tx, namespace, id, lastRelease.ReleaseId, ReleaseOpNormal, userId, request)
+	tx = nsMdl.unlock(tx, namespace.Id)
 	if tx.Error != nil {
 		tx.Rollback()
 		log.Error(tx.Error)
-		return errors.Wrap(tx.Error, "db error")
+		return "", errors.Wrap(tx.Error, "db error")
 	} else {
 		tx.Commit()
 	}
 
-	go c.notifyChange()
+	hookMdl := WebhookModel{}
+	hookMdl.fire(namespace, EventRelease, id, userId, releaseEventData(lastRelease.ReleaseId, lastRelease.Config, itemMap, map[string]interface{}{
+		"name":    name,
+		"comment": comment,
+	}))
 
-	return nil
+	c.notifyRelease(namespace, lastRelease.Config, itemMap, id)
+	return id, nil
+}
+
+//the detail of the release event, the changed keys from the previous release without the values
+func releaseEventData(preReleaseId string, preConfig, config map[string]string, data map[string]interface{}) map[string]interface{} {
+	keys := map[com.OpType][]string{
+		com.OpCreate: {},
+		com.OpUpdate: {},
+		com.OpDelete: {},
+	}
+	for key, item := range releaseChange(preConfig, config) {
+		keys[item.Type] = append(keys[item.Type], key)
+	}
+	for _, list := range keys {
+		sort.Strings(list)
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["pre_release_id"] = preReleaseId
+	data["changes"] = keys
+	return data
+}
+
+//the history makes the release effective, the previous one is where a rollback goes back to
+func (c *ConfigModel) insertHistory(tx *gorm.DB, namespace *namespaceInfo, releaseId, preReleaseId string, opType ReleaseOpType, userId string, request RequestInfo) *gorm.DB {
+	now := time.Now().Unix()
+	historyId := uuid.NewV1().String()
+	tx = database.Insert(tx, "release_history", map[string]interface{}{
+		"id":             historyId,
+		"app_id":         namespace.AppId,
+		"namespace_id":   namespace.Id,
+		"cluster_id":     namespace.ClusterId,
+		"release_id":     releaseId,
+		"pre_release_id": preReleaseId,
+		"op_type":        opType,
+		"create_by":      userId,
+		"create_time":    now,
+		"update_by":      userId,
+		"update_time":    now,
+	})
+	return RecordTable(tx, "release_history", "", userId, request, com.OpCreate, nil, historyId)
+}
+
+//notify the instances of the namespace and the namespaces associated to it, the failure is only logged
+//as the release is done already, the instances get it by the next list
+func (c *ConfigModel) notifyRelease(namespace *namespaceInfo, oldConfig, newConfig map[string]string, releaseId string) {
+	nsMdl := NamespaceModel{}
+	clusterIds, err := nsMdl.consumerClusterIds(namespace.AppId, namespace.ClusterId, namespace.Name)
+	if err != nil {
+		return
+	}
+	oldEffective, err := c.effectiveConfig(namespace.PublicNamespaceId, oldConfig)
+	if err != nil {
+		return
+	}
+	newEffective, err := c.effectiveConfig(namespace.PublicNamespaceId, newConfig)
+	if err != nil {
+		return
+	}
+	change, err := namespaceChange(namespace, releaseId, oldEffective, newEffective)
+	if err != nil {
+		return
+	}
+	go c.notifyChange(core.ConsumerOf(namespace.AppId, clusterIds...), change)
+	if namespace.IsPublic == 1 {
+		go c.notifyAssociated(namespace.Id, oldConfig, newConfig, releaseId)
+	}
 }
 
 type ConfigReleaseHistoryReq struct {
 	NamespaceId string `json:"namespace_id"`
 	Limit       int    `json:"limit"`
 	Offset      int    `json:"offset"`
+	UserId      string `json:"-"`
 }
 
 func (c *ConfigReleaseHistoryReq) Validate() error {
@@ -826,6 +1260,7 @@ func (c *ConfigReleaseHistoryReq) Validate() error {
 		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
 		validation.Field(&c.Limit, validation.Max(100)),
 		validation.Field(&c.Offset, validation.Min(0)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
 	)
 }
 
@@ -836,6 +1271,41 @@ type ChangeItem struct {
 	OldValue string
 }
 
+//the changed items of the release to the previous one
+func releaseChange(preConfig, config map[string]string) map[string]ChangeItem {
+	change := map[string]ChangeItem{}
+	for key, val := range config {
+		if pre, ok := preConfig[key]; ok {
+			if pre != val {
+				change[key] = ChangeItem{
+					Key:      key,
+					Type:     com.OpUpdate,
+					NewValue: val,
+					OldValue: pre,
+				}
+			}
+		} else {
+			change[key] = ChangeItem{
+				Key:      key,
+				Type:     com.OpCreate,
+				NewValue: val,
+				OldValue: "",
+			}
+		}
+	}
+	for key, pre := range preConfig {
+		if _, ok := config[key]; !ok {
+			change[key] = ChangeItem{
+				Key:      key,
+				Type:     com.OpDelete,
+				NewValue: "",
+				OldValue: pre,
+			}
+		}
+	}
+	return change
+}
+
 type ReleaseItem struct {
 	Id           string                `json:"id"`
 	ReleaseId    string                `json:"release_id"`
@@ -867,6 +1337,15 @@ func (c *ConfigModel) GetReleaseHistory(req *ConfigReleaseHistoryReq) (*ConfigRe
 		log.Warn(err)
 		return resp, err
 	}
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
 	if req.Limit <= 0 {
 		req.Limit = 20
 	}
@@ -913,35 +1392,7 @@ func (c *ConfigModel) GetReleaseHistory(req *ConfigReleaseHistoryReq) (*ConfigRe
 				log.Error(err)
 				continue
 			}
-			for key, val := range config {
-				if pre, ok := preConfig[key]; ok {
-					if pre != val {
-						change[key] = ChangeItem{
-							Key:      key,
-							Type:     com.OpUpdate,
-							NewValue: val,
-							OldValue: pre,
-						}
-					}
-				} else {
-					change[key] = ChangeItem{
-						Key:      key,
-						Type:     com.OpCreate,
-						NewValue: val,
-						OldValue: "",
-					}
-				}
-			}
-			for key, pre := range preConfig {
-				if _, ok := config[key]; !ok {
-					change[key] = ChangeItem{
-						Key:      key,
-						Type:     com.OpDelete,
-						NewValue: "",
-						OldValue: pre,
-					}
-				}
-			}
+			change = maskChange(releaseChange(preConfig, config))
 		}
 		resp.List = append(resp.List, ReleaseItem{
 			Id:           history.Id,
@@ -954,7 +1405,7 @@ func (c *ConfigModel) GetReleaseHistory(req *ConfigReleaseHistoryReq) (*ConfigRe
 			UpdateTime:   history.UpdateTime,
 			Name:         history.Name,
 			Comment:      history.Comment,
-			Config:       config,
+			Config:       maskConfig(config),
 			Change:       change,
 		})
 	}
@@ -978,142 +1429,306 @@ func (c *ConfigModel) GetReleaseHistory(req *ConfigReleaseHistoryReq) (*ConfigRe
 }
 
 type RollbackConfigReq struct {
-	NamespaceId string `json:"namespace_id"`
-	UserId      string `json:"user_id"`
+	NamespaceId string      `json:"namespace_id"`
+	ReleaseId   string      `json:"release_id"` //the release listed in release history, the previous release of the current one if empty
+	Publish     bool        `json:"publish"`    //publish the release immediately, otherwise only the items are restored
+	UserId      string      `json:"-"`
+	Request     RequestInfo `json:"-"`
 }
 
 func (c *RollbackConfigReq) Validate() error {
 	return validation.ValidateStruct(c,
 		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.ReleaseId, validation.Length(36, 36)),
 		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
 	)
 }
 
-//rollback is not a type of release, after rollback need to release manually.
-//you can rollback multiple times at a time.
+//restore the items to exactly the configs of the release.
+//if publish, the release becomes effective again by a rollback history, which can be rolled back too.
 func (c *ConfigModel) Rollback(req *RollbackConfigReq) error {
 	if err := req.Validate(); err != nil {
 		log.Warn(err)
 		return err
 	}
 
-	var namespace struct {
-		Id        string
-		AppId     string
-		ClusterId string
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return err
 	}
-	db := database.Conn()
-	db = db.Table("namespace").Select("id,app_id,cluster_id").Where("id=? AND is_delete=0", req.NamespaceId).Scan(&namespace)
-	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-		log.Error(db.Error)
-		return errors.Wrap(db.Error, "db error")
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionRelease); err != nil {
+		return err
 	}
-	if namespace.Id == "" {
-		return errors.New("the namespace not exists")
+	if req.Publish {
+		if namespace.NeedApproval == 1 {
+			return errors.New("the namespace needs release approval, rollback without publish and request a release")
+		}
+		grayMdl := GrayModel{}
+		gray, err := grayMdl.getActive(req.NamespaceId)
+		if err != nil {
+			return err
+		}
+		if gray.Id != "" {
+			return errors.New("the namespace has an active gray release, promote or abandon it first")
+		}
+		//publishing discards the unreleased changes
+		if err := nsMdl.checkLockOwner(namespace, req.UserId); err != nil {
+			return err
+		}
 	}
 
-	//get last valid history which can rollback
-	var lastHistory struct {
-		ReleaseId    string
-		PreReleaseId string
-	}
-	db = database.Conn()
-	db = db.Table("release_history t1").Select("t2.release_id,t2.pre_release_id").
-		Joins("JOIN release_history t2 ON t1.release_id=t2.release_id AND t2.op_type=? AND t2.is_delete=0", ReleaseOpNormal).
-		Where("t1.namespace_id=? AND t1.is_delete=0", req.NamespaceId).
-		Order("t1.update_time DESC").Limit(1).Scan(&lastHistory)
-	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-		log.Error(db.Error)
-		return errors.Wrap(db.Error, "db error")
+	lastRelease, err := c.getLastRelease(req.NamespaceId)
+	if err != nil {
+		return err
 	}
-	if lastHistory.PreReleaseId == "" {
-		return errors.New("the config is the first version, can not rollback anymore")
+	if req.ReleaseId == "" {
+		//the previous release of the current one
+		var lastHistory struct {
+			PreReleaseId string
+		}
+		db := database.Conn()
+		db = db.Table("release_history").Select("pre_release_id").Where("id=?", lastRelease.Id).Scan(&lastHistory)
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			log.Error(db.Error)
+			return errors.Wrap(db.Error, "db error")
+		}
+		if lastHistory.PreReleaseId == "" {
+			return errors.New("the config is the first version, can not rollback anymore")
+		}
+		req.ReleaseId = lastHistory.PreReleaseId
 	}
 
-	//get history config to rollback
+	//the release must be in the history of the namespace
 	var backRelease struct {
+		Id     string
 		Config []byte
 	}
-	db = database.Conn()
-	db = db.Table("release").Select("config").Where("id=?", lastHistory.PreReleaseId).Scan(&backRelease)
-	if db.Error != nil {
+	db := database.Conn()
+	db = db.Table("`release` t1").Select("t1.id,t1.config").
+		Joins("JOIN release_history t2 ON t2.release_id=t1.id AND t2.namespace_id=t1.namespace_id AND t2.is_delete=0").
+		Where("t1.id=? AND t1.namespace_id=? AND t2.op_type IN (?) AND t1.is_delete=0",
+			req.ReleaseId, req.NamespaceId, []ReleaseOpType{ReleaseOpNormal, ReleaseOpRollback}).
+		Limit(1).Scan(&backRelease)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
 		return errors.Wrap(db.Error, "db error")
 	}
-	var config map[string]string
+	if backRelease.Id == "" {
+		return errors.New("the release not exists in the history of the namespace")
+	}
+	config := map[string]string{}
 	if err := json.Unmarshal(backRelease.Config, &config); err != nil {
 		log.Error(err)
 		return err
 	}
+	if req.Publish && req.ReleaseId == lastRelease.ReleaseId {
+		return errors.New("the release is the current one")
+	}
+
+	//the restored items are unreleased changes of the user if not publish,
+	//otherwise the lock checked above must not be taken by others since then
+	lockBy := req.UserId
+	if req.Publish {
+		lockBy = nsMdl.lockOwner(namespace, req.UserId)
+	}
+	tx := database.Conn().Begin()
+	tx, err = nsMdl.lock(tx, namespace, lockBy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx, ids := c.restoreItems(tx, req.NamespaceId, config, "rollback", req.UserId, req.Request)
+	if req.Publish && tx.Error == nil {
+		//the restored values are published, they must match the current types and rules of the items
+		if err := c.validateItemsIn(tx.New(), req.NamespaceId, namespace.Format); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if req.Publish {
+		tx = c.insertHistory(tx, namespace, req.ReleaseId, lastRelease.ReleaseId, ReleaseOpRollback, req.UserId, req.Request)
+		tx = nsMdl.unlock(tx, req.NamespaceId)
+	}
+	tx, commits := c.recordCommit(tx, ids, "rollback", req.UserId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	c.fireCommits(commits, "rollback", req.UserId)
+
+	if req.Publish {
+		hookMdl := WebhookModel{}
+		hookMdl.fire(namespace, EventRollback, req.ReleaseId, req.UserId, releaseEventData(lastRelease.ReleaseId, lastRelease.Config, config, nil))
+		c.notifyRelease(namespace, lastRelease.Config, config, req.ReleaseId)
+	}
+	return nil
+}
 
+//restore the items to exactly the configs, the deleted items are recreated and the others are deleted
+func (c *ConfigModel) restoreItems(tx *gorm.DB, namespaceId string, config map[string]string, comment, userId string, request RequestInfo) (*gorm.DB, map[com.OpType][]string) {
 	var items []struct {
-		Id    string
-		Key   string
-		Value string
+		Id       string
+		Key      string
+		Value    string
+		IsSecret int
+		IsDelete int
 	}
-	db = database.Conn()
-	db = db.Table("item").Select("id,`key`,value").Where("namespace_id=?", req.NamespaceId).Find(&items)
-	if db.Error != nil {
+	db := database.Conn()
+	db = db.Table("item").Select("id,`key`,value,is_secret,is_delete").Where("namespace_id=?", namespaceId).Find(&items)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
-		return errors.Wrap(db.Error, "db error")
+		tx.AddError(errors.Wrap(db.Error, "db error"))
+		return tx, nil
+	}
+
+	//the sealed secrets are restored as they are
+	isSecret := func(value string) int {
+		if kms.IsSealed(value) {
+			return 1
+		}
+		return 0
 	}
 
 	//mark changed config items
 	now := time.Now().Unix()
 	var updateItems []map[string]interface{}
-	var updateItemIds []string
+	var insertItems []map[string]interface{}
+	ids := map[com.OpType][]string{}
+	existKeys := map[string]bool{}
 	for _, item := range items {
-		if val, ok := config[item.Key]; ok {
-			if val != item.Value {
+		val, ok := config[item.Key]
+		existKeys[item.Key] = true
+		if !ok {
+			if item.IsDelete == 0 {
 				updateItems = append(updateItems, map[string]interface{}{
 					"id":          item.Id,
-					"value":       val,
-					"is_delete":   0,
+					"is_delete":   1,
+					"version":     gorm.Expr("version+1"),
 					"update_time": now,
-					"update_by":   req.UserId,
+					"update_by":   userId,
 				})
-				updateItemIds = append(updateItemIds, item.Id)
+				ids[com.OpDelete] = append(ids[com.OpDelete], item.Id)
 			}
+			continue
+		}
+		if item.IsDelete == 0 && val == item.Value {
+			continue
+		}
+		updateItems = append(updateItems, map[string]interface{}{
+			"id":          item.Id,
+			"value":       val,
+			"is_secret":   isSecret(val),
+			"is_delete":   0,
+			"version":     gorm.Expr("version+1"),
+			"update_time": now,
+			"update_by":   userId,
+		})
+		if item.IsDelete == 1 {
+			ids[com.OpCreate] = append(ids[com.OpCreate], item.Id)
 		} else {
-			updateItems = append(updateItems, map[string]interface{}{
-				"id":          item.Id,
-				"value":       item.Value,
-				"is_delete":   1,
-				"update_time": now,
-				"update_by":   req.UserId,
-			})
-			updateItemIds = append(updateItemIds, item.Id)
+			ids[com.OpUpdate] = append(ids[com.OpUpdate], item.Id)
 		}
 	}
 
-	release := map[string]interface{}{
-		"is_disabled": 1,
-		"update_by":   req.UserId,
-		"update_time": now,
+	var itemOrderNum struct {
+		MaxOrderNum int
 	}
-	historyId := uuid.NewV1().String()
-	releaseHistory := map[string]interface{}{
-		"id":             historyId,
-		"app_id":         namespace.AppId,
-		"cluster_id":     namespace.ClusterId,
-		"namespace_id":   req.NamespaceId,
-		"release_id":     lastHistory.PreReleaseId,
-		"pre_release_id": lastHistory.ReleaseId,
-		"op_type":        ReleaseOpRollback,
-		"create_by":      req.UserId,
-		"create_time":    now,
-		"update_by":      req.UserId,
-		"update_time":    now,
+	tx = tx.Raw("SELECT MAX(order_num) max_order_num FROM item WHERE namespace_id=? FOR UPDATE", namespaceId).Scan(&itemOrderNum)
+	for key, val := range config {
+		if existKeys[key] {
+			continue
+		}
+		itemOrderNum.MaxOrderNum++
+		id := uuid.NewV1().String()
+		insertItems = append(insertItems, map[string]interface{}{
+			"id":           id,
+			"namespace_id": namespaceId,
+			"key":          key,
+			"value":        val,
+			"value_type":   ValueString,
+			"value_rule":   ValueRule{},
+			"comment":      "",
+			"order_num":    itemOrderNum.MaxOrderNum,
+			"is_secret":    isSecret(val),
+			"is_delete":    0,
+			"create_by":    userId,
+			"create_time":  now,
+			"update_by":    userId,
+			"update_time":  now,
+		})
+		ids[com.OpCreate] = append(ids[com.OpCreate], id)
 	}
-
-	tx := database.Conn().Begin()
+	var updateIds []string
+	for _, item := range updateItems {
+		updateIds = append(updateIds, item["id"].(string))
+	}
+	tx, before := selectRows(tx, "item", updateIds...)
 	for _, item := range updateItems {
 		tx = database.Update(tx, "item", item, "id=?", item["id"])
 	}
-	tx = database.Update(tx, "`release`", release, "id=?", lastHistory.PreReleaseId)
-	tx = database.Insert(tx, "release_history", releaseHistory)
-	tx = RecordTable(tx, "release", "", req.UserId, com.OpUpdate, lastHistory.PreReleaseId)
-	tx = RecordTable(tx, "release_history", "", req.UserId, com.OpCreate, historyId)
+	if len(insertItems) > 0 {
+		tx = database.InsertMany(tx, "item", insertItems)
+	}
+	for op, opIds := range ids {
+		tx = RecordTable(tx, "item", comment, userId, request, op, before, opIds...)
+	}
+	return tx, ids
+}
+
+type DiscardConfigReq struct {
+	NamespaceId string      `json:"namespace_id"`
+	UserId      string      `json:"-"`
+	Request     RequestInfo `json:"-"`
+}
+
+func (c *DiscardConfigReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//revert the items to the current release and unlock the namespace,
+//the changes locked by another user can only be discarded by the manager
+func (c *ConfigModel) Discard(req *DiscardConfigReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+	action := ActionEdit
+	if namespace.LockBy != "" && namespace.LockBy != req.UserId {
+		action = ActionManage
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, action); err != nil {
+		return err
+	}
+
+	lastRelease, err := c.getLastRelease(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+	config := lastRelease.Config
+	if config == nil {
+		config = map[string]string{}
+	}
+
+	tx := database.Conn().Begin()
+	tx, ids := c.restoreItems(tx, req.NamespaceId, config, "discard", req.UserId, req.Request)
+	tx = nsMdl.unlock(tx, req.NamespaceId)
+	tx, commits := c.recordCommit(tx, ids, "discard", req.UserId)
 	if tx.Error != nil {
 		tx.Rollback()
 		log.Error(tx.Error)
@@ -1122,18 +1737,16 @@ func (c *ConfigModel) Rollback(req *RollbackConfigReq) error {
 		tx.Commit()
 	}
 
-	if len(updateItemIds) > 0 {
-		c.recordItem(com.OpUpdate, req.UserId, updateItemIds...)
-	}
-
+	c.fireCommits(commits, "discard", req.UserId)
 	return nil
 }
 
 type SyncConfigReq struct {
-	FromNamespaceId string   `json:"namespace_id"`
-	ToClusterIds    []string `json:"to_cluster_ids"`
-	Keys            []string `json:"keys"`
-	UserId          string   `json:"user_id"`
+	FromNamespaceId string      `json:"namespace_id"`
+	ToClusterIds    []string    `json:"to_cluster_ids"`
+	Keys            []string    `json:"keys"`
+	UserId          string      `json:"-"`
+	Request         RequestInfo `json:"-"`
 }
 
 func (c *SyncConfigReq) Validate() error {
@@ -1155,10 +1768,11 @@ func (c *ConfigModel) Sync(req *SyncConfigReq) error {
 	var namespace struct {
 		Id        string
 		Name      string
+		AppId     string
 		ClusterId string
 	}
 	db := database.Conn()
-	db = db.Table("namespace").Select("id,name,cluster_id").Where("id=? AND is_delete=0", req.FromNamespaceId).Scan(&namespace)
+	db = db.Table("namespace").Select("id,name,app_id,cluster_id").Where("id=? AND is_delete=0", req.FromNamespaceId).Scan(&namespace)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
 		return errors.Wrap(db.Error, "db error")
@@ -1166,6 +1780,10 @@ func (c *ConfigModel) Sync(req *SyncConfigReq) error {
 	if namespace.Id == "" {
 		return errors.New("the namespace not exists")
 	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, namespace.AppId, namespace.ClusterId, namespace.Id, ActionView); err != nil {
+		return err
+	}
 	for _, cid := range req.ToClusterIds {
 		if cid == namespace.ClusterId {
 			return errors.New("the clusters to be sync contains source cluster")
@@ -1186,14 +1804,9 @@ func (c *ConfigModel) Sync(req *SyncConfigReq) error {
 	}
 
 	//get items to sync
-	var items []struct {
-		Id      string
-		Key     string
-		Value   string
-		Comment string
-	}
+	var items []syncItem
 	db = database.Conn()
-	db = db.Table("item").Select("id,`key`,value,comment").Where("namespace_id=? AND key IN (?) AND is_delete=0", req.FromNamespaceId, req.Keys).Scan(&items)
+	db = db.Table("item").Select(syncItemColumns).Where("namespace_id=? AND `key` IN (?) AND is_delete=0", req.FromNamespaceId, req.Keys).Scan(&items)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
 		return errors.Wrap(db.Error, "db error")
@@ -1202,34 +1815,46 @@ func (c *ConfigModel) Sync(req *SyncConfigReq) error {
 		return errors.New("the keys not exist")
 	}
 
-	//get items to be sync
-	type toItem struct {
-		Id          string
-		Key         string
-		Value       string
-		NamespaceId string
+	//get the namespaces to be sync, including the ones without any item yet
+	var toNamespaceIds []string
+	db = database.Conn()
+	db = db.Table("namespace").Where("name=? AND cluster_id IN (?) AND is_delete=0", namespace.Name, req.ToClusterIds).Pluck("id", &toNamespaceIds)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	if len(toNamespaceIds) == 0 {
+		return errors.New("the namespace not exists in the clusters to be sync")
 	}
-	var toItems []toItem
+
+	//get items to be sync
+	var toItems []syncTargetItem
 	db = database.Conn()
-	db = db.Table("item t1").Select("t1.id,t1.key,t1.value,t2.id namespace_id").
-		Joins("JOIN namespace t2 ON t1.namespace_id=t2.id AND t2.name=? AND cluster_id IN (?) AND t2.is_delete=0",
-			namespace.Name, req.ToClusterIds).
+	db = db.Table("item").Select(syncTargetItemColumns).
+		Where("namespace_id IN (?) AND `key` IN (?)", toNamespaceIds, req.Keys).
 		Find(&toItems)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
 		return errors.Wrap(db.Error, "db error")
 	}
-	if namespace.Id == "" {
-		return errors.New("the namespace not exists")
-	}
 
-	itemMap := map[string]map[string]toItem{}
-	for _, item := range toItems {
-		if itemMap[item.NamespaceId] == nil {
-			itemMap[item.NamespaceId] = map[string]toItem{}
+	//need to edit all the namespaces to be sync
+	nsMdl := NamespaceModel{}
+	var toNamespaces []*namespaceInfo
+	for _, nsId := range toNamespaceIds {
+		toNamespace, err := nsMdl.getInfo(nsId)
+		if err != nil {
+			return err
+		}
+		toNamespaces = append(toNamespaces, toNamespace)
+		if err := permMdl.checkNamespace(req.UserId, toNamespace, ActionEdit); err != nil {
+			return err
 		}
-		itemMap[item.NamespaceId][item.Key] = item
 	}
+	//lock in the same order to avoid the deadlock with another sync
+	sort.Slice(toNamespaces, func(i, j int) bool {
+		return toNamespaces[i].Id < toNamespaces[j].Id
+	})
 
 	//get max order_num per namespace
 	var itemOrderNums []struct {
@@ -1237,7 +1862,7 @@ func (c *ConfigModel) Sync(req *SyncConfigReq) error {
 		MaxOrderNum int
 	}
 	db = database.Conn()
-	db = db.Raw("SELECT namespace_id,MAX(order_num) max_order_num FROM item WHERE namespace_id IN (?) GROUP BY namespace_id").Find(&itemOrderNums)
+	db = db.Raw("SELECT namespace_id,MAX(order_num) max_order_num FROM item WHERE namespace_id IN (?) GROUP BY namespace_id", toNamespaceIds).Find(&itemOrderNums)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
 		return errors.Wrap(db.Error, "db error")
@@ -1247,73 +1872,193 @@ func (c *ConfigModel) Sync(req *SyncConfigReq) error {
 		itemOrderNumMap[num.NamespaceId] = num.MaxOrderNum
 	}
 
-	var updateItems []map[string]interface{}
-	var insertItems []map[string]interface{}
-	var updateItemIds []string
-	var insertItemIds []string
-	now := time.Now().Unix()
+	plan, err := c.planSync(items, toNamespaceIds, toItems, itemOrderNumMap, req.UserId, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	updateItems, insertItems := plan.updates, plan.inserts
+	updateItemIds, insertItemIds := plan.updateIds, plan.insertIds
+
+	//分别对被修改方进行 增删改
+	tx := database.Conn().Begin()
+	for _, toNamespace := range toNamespaces {
+		var err error
+		tx, err = nsMdl.lock(tx, toNamespace, req.UserId)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	tx, before := selectRows(tx, "item", updateItemIds...)
+	for _, item := range updateItems {
+		tx = database.Update(tx, "item", item, "id=?", item["id"])
+	}
+	tx = database.InsertMany(tx, "item", insertItems)
+	tx = RecordTable(tx, "item", "", req.UserId, req.Request, com.OpUpdate, before, updateItemIds...)
+	tx = RecordTable(tx, "item", "", req.UserId, req.Request, com.OpCreate, nil, insertItemIds...)
+	tx, commits := c.recordCommit(tx, map[com.OpType][]string{
+		com.OpUpdate: updateItemIds,
+		com.OpCreate: insertItemIds,
+	}, "sync", req.UserId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	c.fireCommits(commits, "sync", req.UserId)
+
+	hookMdl := WebhookModel{}
+	for _, toNamespace := range toNamespaces {
+		hookMdl.fire(toNamespace, EventSync, "", req.UserId, map[string]interface{}{
+			"from_namespace_id": req.FromNamespaceId,
+			"keys":              req.Keys,
+		})
+	}
+
+	return nil
+}
+
+//the columns selected into syncItem and syncTargetItem, every field read by planSync must be selected
+const (
+	syncItemColumns       = "id,`key`,value,value_type,value_rule,comment,is_secret"
+	syncTargetItemColumns = "id,`key`,value,value_type,value_rule,namespace_id,is_delete"
+)
+
+type syncItem struct {
+	Id        string
+	Key       string
+	Value     string
+	ValueType ValueType
+	ValueRule ValueRule
+	Comment   string
+	IsSecret  int
+}
+
+type syncTargetItem struct {
+	Id          string
+	Key         string
+	Value       string
+	ValueType   ValueType
+	ValueRule   ValueRule
+	NamespaceId string
+	IsDelete    int
+}
+
+type syncPlan struct {
+	updates   []map[string]interface{}
+	inserts   []map[string]interface{}
+	updateIds []string
+	insertIds []string
+}
+
+//check the items against the ones in the namespaces to be sync, and plan the writes of them
+func (c *ConfigModel) planSync(items []syncItem, toNamespaceIds []string, toItems []syncTargetItem, orderNums map[string]int, userId string, now int64) (*syncPlan, error) {
+	itemMap := map[string]map[string]syncTargetItem{}
+	for _, nsId := range toNamespaceIds {
+		itemMap[nsId] = map[string]syncTargetItem{}
+	}
+	for _, item := range toItems {
+		nsItems := itemMap[item.NamespaceId]
+		if nsItems == nil {
+			continue
+		}
+		//the key may have both a live and a deleted item, the live one is kept
+		if old, ok := nsItems[item.Key]; ok && old.IsDelete == 0 {
+			continue
+		}
+		nsItems[item.Key] = item
+	}
+
+	plan := &syncPlan{}
 	for _, item := range items {
-		for nsId, nsItem := range itemMap {
-			if v, ok := nsItem[item.Key]; ok {
-				if v.Value != item.Value {
-					updateItems = append(updateItems, map[string]interface{}{
+		//the sealed secret can be decrypted in any namespace, so it is copied as it is
+		value, err := openValue(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		for _, nsId := range toNamespaceIds {
+			v, ok := itemMap[nsId][item.Key]
+			if ok && v.IsDelete == 1 {
+				//the deleted item is created again with the type of the synced one
+				if err := validateValue(item.Key, value, item.ValueType, item.ValueRule); err != nil {
+					log.Warn(err)
+					return nil, err
+				}
+				plan.updates = append(plan.updates, map[string]interface{}{
+					"id":          v.Id,
+					"value":       item.Value,
+					"value_type":  item.ValueType,
+					"value_rule":  item.ValueRule,
+					"comment":     item.Comment,
+					"is_secret":   item.IsSecret,
+					"is_delete":   0,
+					"version":     gorm.Expr("version+1"),
+					"update_time": now,
+					"update_by":   userId,
+				})
+				plan.updateIds = append(plan.updateIds, v.Id)
+			} else if ok {
+				//the synced value should match the type of the existing item
+				if err := validateValue(item.Key, value, v.ValueType, v.ValueRule); err != nil {
+					log.Warn(err)
+					return nil, err
+				}
+				toValue, err := openValue(v.Value)
+				if err != nil {
+					return nil, err
+				}
+				if toValue != value || kms.IsSealed(v.Value) != kms.IsSealed(item.Value) {
+					plan.updates = append(plan.updates, map[string]interface{}{
 						"id":          v.Id,
 						"value":       item.Value,
-						"is_delete":   0,
+						"is_secret":   item.IsSecret,
+						"version":     gorm.Expr("version+1"),
 						"update_time": now,
-						"update_by":   req.UserId,
+						"update_by":   userId,
 					})
-					updateItemIds = append(updateItemIds, v.Id)
+					plan.updateIds = append(plan.updateIds, v.Id)
 				}
 			} else {
-				itemOrderNumMap[nsId]++
+				if err := validateValue(item.Key, value, item.ValueType, item.ValueRule); err != nil {
+					log.Warn(err)
+					return nil, err
+				}
+				orderNums[nsId]++
 				id := uuid.NewV1().String()
-				insertItems = append(insertItems, map[string]interface{}{
+				plan.inserts = append(plan.inserts, map[string]interface{}{
 					"id":           id,
 					"namespace_id": nsId,
 					"key":          item.Key,
 					"value":        item.Value,
+					"value_type":   item.ValueType,
+					"value_rule":   item.ValueRule,
 					"comment":      item.Comment,
-					"order_num":    itemOrderNumMap[nsId],
+					"order_num":    orderNums[nsId],
+					"is_secret":    item.IsSecret,
 					"is_delete":    0,
-					"create_by":    req.UserId,
+					"create_by":    userId,
 					"create_time":  now,
-					"update_by":    req.UserId,
+					"update_by":    userId,
 					"update_time":  now,
 				})
-				insertItemIds = append(insertItemIds, id)
+				plan.insertIds = append(plan.insertIds, id)
 			}
 		}
 	}
-
-	//分别对被修改方进行 增删改
-	tx := database.Conn().Begin()
-	for _, item := range updateItems {
-		tx = database.Update(tx, "item", item, "id=?", item["id"])
-	}
-	tx = database.InsertMany(tx, "item", insertItems)
-	tx = RecordTable(tx, "item", "", req.UserId, com.OpUpdate, updateItemIds...)
-	tx = RecordTable(tx, "item", "", req.UserId, com.OpCreate, insertItemIds...)
-	if tx.Error != nil {
-		tx.Rollback()
-		log.Error(tx.Error)
-		return errors.Wrap(tx.Error, "db error")
-	} else {
-		tx.Commit()
-	}
-
-	c.recordItem(com.OpUpdate, req.UserId, updateItemIds...)
-	c.recordItem(com.OpCreate, req.UserId, insertItemIds...)
-
-	return nil
+	return plan, nil
 }
 
 type WatchConfigReq struct {
-	Host    string      `json:"host"`
-	Port    int         `json:"port"`
-	Env     com.EnvType `json:"env"`
-	Cluster string      `json:"cluster"`
-	App     string      `json:"app"`
+	Host        string      `json:"host"`
+	Port        int         `json:"port"`
+	Env         com.EnvType `json:"env"`
+	Cluster     string      `json:"cluster"`
+	App         string      `json:"app"`
+	AccessAppId string      `json:"-"` //the app of the access key
+	AllowSecret bool        `json:"-"` //whether the access key can read the secrets
 }
 
 func (c *WatchConfigReq) Validate() error {
@@ -1329,7 +2074,7 @@ func (c *WatchConfigReq) Validate() error {
 type WatchConfigResp struct {
 	InstanceId string                   `json:"instance_id"`
 	EventType  com.ConfigWatchEventType `json:"event_type"`
-	//Configs    map[com.OpType][]core.ChangeConfig `json:"configs"`
+	Changes    []core.NamespaceChange   `json:"changes"`
 }
 
 func (c *ConfigModel) Watch(req *WatchConfigReq) (*WatchConfigResp, error) {
@@ -1349,26 +2094,19 @@ func (c *ConfigModel) Watch(req *WatchConfigReq) (*WatchConfigResp, error) {
 
 	now := time.Now().Unix()
 
-	var cluster struct {
-		AppId     string
-		ClusterId string
-	}
-	db := database.Conn()
-	db = db.Table("cluster t1").Select("t1.id cluster_id, t2.id app_id").
-		Joins("JOIN app t2 ON t1.app_id=t2.id AND t2.name=? AND t2.is_delete=0", req.App).
-		Where("t1.name=? AND t1.is_delete=0", req.Cluster).Scan(&cluster)
-	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
-		log.Error(db.Error)
-		return resp, errors.Wrap(db.Error, "db error")
+	cluster, err := c.clientCluster(req.App, req.Cluster)
+	if err != nil {
+		return resp, err
 	}
-	if cluster.ClusterId == "" {
-		return resp, errors.New("cluster or app not exists")
+	if cluster.AppId != req.AccessAppId {
+		log.Warnf("access key of app[%s] can not access app[%s]", req.AccessAppId, req.App)
+		return resp, errors.New("the access key can not access the app")
 	}
 
 	var instance struct {
 		Id string
 	}
-	db = database.Conn()
+	db := database.Conn()
 	db = db.Table("instance").Select("id").
 		Where("app_id=? AND cluster_id=? AND host=? AND port=? AND is_delete=0", cluster.AppId, cluster.ClusterId, req.Host, req.Port).
 		Scan(&instance)
@@ -1413,40 +2151,139 @@ func (c *ConfigModel) Watch(req *WatchConfigReq) (*WatchConfigResp, error) {
 		return resp, nil
 	}
 
-	var isConfigChange bool
+	//the instance may miss some releases while it is not online
 	if ins.Status != com.OnlineStatus {
-		isConfigChange = true
-		resp.EventType = com.CwRefreshAll
-	}
-	if len(ins.ChChange) > 0 {
-		isConfigChange = true
+		ins.PopChanges()
+		if len(ins.ChChange) > 0 {
+			<-ins.ChChange
+		}
+		ins.Status = com.OnlineStatus
+		ins.Life = 60
+		instances.Store(instance.Id, ins)
 		resp.EventType = com.CwRefreshAll
-		<-ins.ChChange
+		return resp, nil
 	}
-	ins.Status = com.OnlineStatus
 	ins.Life = 60
 	instances.Store(instance.Id, ins)
 
-	if isConfigChange {
-		return resp, nil
-	}
-
 	select {
 	case <-ins.ChChange:
-		resp.EventType = com.CwRefreshAll
+		for _, change := range ins.PopChanges() {
+			change, err := revealChange(change, req.AllowSecret)
+			if err != nil {
+				return resp, err
+			}
+			resp.Changes = append(resp.Changes, change)
+		}
+		if len(resp.Changes) > 0 {
+			resp.EventType = com.CwConfigChange
+		} else {
+			resp.EventType = com.CwRefreshAll
+		}
 	case <-time.After(time.Second * 45):
 		resp.EventType = com.CwNothing
 	}
 
+	if len(resp.Changes) > 0 {
+		releaseIds := map[string]string{}
+		for _, change := range resp.Changes {
+			releaseIds[change.NamespaceId] = change.ReleaseId
+		}
+		insMdl := InstanceModel{}
+		if err := insMdl.recordRelease(instance.Id, releaseIds); err != nil {
+			log.Warn(err)
+		}
+	}
+
 	return resp, nil
 }
 
-func (c *ConfigModel) notifyChange() {
-	server := core.GetServer()
-	server.Instances.Range(func(instanceId string, val *core.Instance) bool {
-		if len(val.ChChange) == 0 {
-			val.ChChange <- true
+//the released configs of the public namespace overridden by the configs
+func (c *ConfigModel) effectiveConfig(publicNamespaceId string, config map[string]string) (map[string]string, error) {
+	if publicNamespaceId == "" {
+		return config, nil
+	}
+	release, err := c.getLastRelease(publicNamespaceId)
+	if err != nil {
+		return config, err
+	}
+	merged := map[string]string{}
+	for k, v := range release.Config {
+		merged[k] = v
+	}
+	for k, v := range config {
+		merged[k] = v
+	}
+	return merged, nil
+}
+
+//notify the instances which consume the namespaces associated to the released public namespace
+func (c *ConfigModel) notifyAssociated(publicNamespaceId string, oldConfig, newConfig map[string]string, releaseId string) {
+	var namespaces []namespaceInfo
+	db := database.Conn()
+	db = db.Table("namespace").Select("id,name,app_id,cluster_id,format,public_namespace_id").
+		Where("public_namespace_id=? AND is_delete=0", publicNamespaceId).Find(&namespaces)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return
+	}
+
+	nsMdl := NamespaceModel{}
+	for _, ns := range namespaces {
+		release, err := c.getLastRelease(ns.Id)
+		if err != nil {
+			continue
 		}
-		return true
-	})
+		oldMerged := map[string]string{}
+		newMerged := map[string]string{}
+		for k, v := range oldConfig {
+			oldMerged[k] = v
+		}
+		for k, v := range newConfig {
+			newMerged[k] = v
+		}
+		for k, v := range release.Config {
+			oldMerged[k] = v
+			newMerged[k] = v
+		}
+		change, err := namespaceChange(&ns, releaseId, oldMerged, newMerged)
+		if err != nil || len(change.Configs) == 0 {
+			continue
+		}
+
+		clusterIds, err := nsMdl.consumerClusterIds(ns.AppId, ns.ClusterId, ns.Name)
+		if err != nil {
+			continue
+		}
+		c.notifyChange(core.ConsumerOf(ns.AppId, clusterIds...), change)
+	}
+}
+
+//the change pushed to clients, the document is flattened as ListByApp does
+func namespaceChange(namespace *namespaceInfo, releaseId string, oldConfig, newConfig map[string]string) (core.NamespaceChange, error) {
+	change := core.NamespaceChange{
+		NamespaceId: namespace.Id,
+		Namespace:   namespace.Name,
+		ReleaseId:   releaseId,
+	}
+	oldConfig, _, err := exposeConfig(namespace.Format, oldConfig)
+	if err != nil {
+		log.Warn(err) //the old document is replaced as a whole
+		oldConfig = map[string]string{}
+	}
+	newConfig, content, err := exposeConfig(namespace.Format, newConfig)
+	if err != nil {
+		log.Error(err)
+		return change, err
+	}
+	change.Configs = diffConfigs(oldConfig, newConfig)
+	change.Content = content
+	return change, nil
+}
+
+//only notify the instances which consume the released namespace
+func (c *ConfigModel) notifyChange(match func(ins *core.Instance) bool, change core.NamespaceChange) {
+	server := core.GetServer()
+	count := server.Instances.NotifyChange(change, match)
+	log.Debugf("notify %d instances of namespace[%s] release: %s", count, change.Namespace, change.ReleaseId)
 }
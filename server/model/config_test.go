@@ -0,0 +1,143 @@
+package model
+
+import (
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/jinzhu/gorm"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testSyncNamespaceId1 = "00000000-0000-0000-0000-000000000011"
+	testSyncNamespaceId2 = "00000000-0000-0000-0000-000000000012"
+)
+
+func TestPlanSync(t *testing.T) {
+	items := []syncItem{
+		{Id: "from-port", Key: "port", Value: "9090", ValueType: ValueInt, Comment: "the port"},
+		{Id: "from-host", Key: "host", Value: "example.com", ValueType: ValueString},
+	}
+	toItems := []syncTargetItem{
+		{Id: "to1-port-deleted", Key: "port", Value: "x", ValueType: ValueString, NamespaceId: testSyncNamespaceId1, IsDelete: 1},
+		{Id: "to1-port", Key: "port", Value: "8080", ValueType: ValueInt, NamespaceId: testSyncNamespaceId1},
+		{Id: "to1-port-deleted2", Key: "port", Value: "x", ValueType: ValueString, NamespaceId: testSyncNamespaceId1, IsDelete: 1},
+		{Id: "to1-host", Key: "host", Value: "example.com", ValueType: ValueString, NamespaceId: testSyncNamespaceId1},
+		{Id: "to2-port", Key: "port", Value: "9090", ValueType: ValueString, NamespaceId: testSyncNamespaceId2, IsDelete: 1},
+	}
+	orderNums := map[string]int{testSyncNamespaceId1: 5}
+	plan, err := (&ConfigModel{}).planSync(items, []string{testSyncNamespaceId1, testSyncNamespaceId2}, toItems, orderNums, testUserId, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.updateIds) != 2 || plan.updateIds[0] != "to1-port" || plan.updateIds[1] != "to2-port" {
+		t.Fatalf("update ids: got %v", plan.updateIds)
+	}
+	update := plan.updates[0]
+	if update["value"] != "9090" || update["update_time"] != int64(100) || update["value_type"] != nil {
+		t.Errorf("update keeping the old type: got %v", update)
+	}
+	revive := plan.updates[1]
+	if revive["is_delete"] != 0 || revive["value_type"] != ValueInt || revive["comment"] != "the port" {
+		t.Errorf("update over the deleted item: got %v", revive)
+	}
+
+	if len(plan.insertIds) != 1 || len(plan.inserts) != 1 {
+		t.Fatalf("inserts: got %v", plan.inserts)
+	}
+	insert := plan.inserts[0]
+	if insert["namespace_id"] != testSyncNamespaceId2 || insert["key"] != "host" ||
+		insert["order_num"] != 1 || insert["create_by"] != testUserId {
+		t.Errorf("insert into the namespace without the key: got %v", insert)
+	}
+}
+
+func TestPlanSyncInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    syncItem
+		toItems []syncTargetItem
+		err     string
+	}{
+		{
+			name:    "invalid value of the existing type",
+			item:    syncItem{Key: "port", Value: "abc", ValueType: ValueString},
+			toItems: []syncTargetItem{{Id: "to-port", Key: "port", Value: "1", ValueType: ValueInt, NamespaceId: testSyncNamespaceId1}},
+			err:     "config[port]",
+		},
+		{
+			name: "invalid value of the new item",
+			item: syncItem{Key: "port", Value: "abc", ValueType: ValueInt},
+			err:  "config[port]",
+		},
+	}
+	for _, tt := range tests {
+		plan, err := (&ConfigModel{}).planSync([]syncItem{tt.item}, []string{testSyncNamespaceId1}, tt.toItems, map[string]int{}, testUserId, 100)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+		if plan != nil {
+			t.Errorf("%s: nothing should be written, got %+v", tt.name, plan)
+		}
+	}
+}
+
+func TestReleaseStatus(t *testing.T) {
+	item := func(key, value string, isDelete int) ConfigItemInfo {
+		info := ConfigItemInfo{}
+		info.Key, info.Value, info.IsDelete = key, value, isDelete
+		return info
+	}
+	items := []ConfigItemInfo{
+		item("created", "a", 0),
+		item("released", "b", 0),
+		item("updated", "new", 0),
+		item("deleted", "d", 1),
+		item("deleted_unreleased", "e", 1),
+	}
+	config := map[string]string{"released": "b", "updated": "old", "deleted": "d"}
+	list := releaseStatus(items, config)
+
+	want := []struct {
+		key       string
+		isRelease int
+		status    com.OpType
+	}{
+		{"released", 1, ""},
+		{"created", 0, com.OpCreate},
+		{"updated", 0, com.OpUpdate},
+		{"deleted", 0, com.OpDelete},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("got %d items, want %d", len(list), len(want))
+	}
+	for i, w := range want {
+		if list[i].Key != w.key || list[i].IsRelease != w.isRelease || list[i].Status != w.status {
+			t.Errorf("item[%d]: got %s %d %s, want %+v", i, list[i].Key, list[i].IsRelease, list[i].Status, w)
+		}
+	}
+}
+
+//the fields not selected are always zero, e.g. the deleted target item would never be revived
+func TestSyncColumns(t *testing.T) {
+	tests := []struct {
+		columns string
+		item    interface{}
+	}{
+		{syncItemColumns, syncItem{}},
+		{syncTargetItemColumns, syncTargetItem{}},
+	}
+	for _, tt := range tests {
+		selected := map[string]bool{}
+		for _, col := range strings.Split(tt.columns, ",") {
+			selected[strings.Trim(col, "`")] = true
+		}
+		rt := reflect.TypeOf(tt.item)
+		for i := 0; i < rt.NumField(); i++ {
+			if col := gorm.ToColumnName(rt.Field(i).Name); !selected[col] {
+				t.Errorf("%s.%s: column %s is not selected", rt.Name(), rt.Field(i).Name, col)
+			}
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"sort"
+	"time"
+)
+
+type ConfigContentReq struct {
+	NamespaceId string `json:"namespace_id"`
+	UserId      string `json:"-"`
+}
+
+func (c *ConfigContentReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type ConfigContentResp struct {
+	Format  NamespaceFormat `json:"format"`
+	Content string          `json:"content"`
+}
+
+//the whole document of the namespace include the unreleased, properties namespace is rendered from its items
+func (c *ConfigModel) Content(req *ConfigContentReq) (*ConfigContentResp, error) {
+	resp := &ConfigContentResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
+	itemMap, err := c.getItemConfig(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Format = namespace.Format
+	if namespace.Format.IsDocument() {
+		resp.Content = itemMap[ContentKey]
+	} else {
+		resp.Content = renderProperties(maskConfig(itemMap))
+	}
+	return resp, nil
+}
+
+type SaveConfigContentReq struct {
+	NamespaceId string      `json:"namespace_id"`
+	Content     string      `json:"content"`
+	UserId      string      `json:"-"`
+	Request     RequestInfo `json:"-"`
+}
+
+func (c *SaveConfigContentReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//save the whole document of the namespace, the items of properties namespace are replaced by the parsed ones
+func (c *ConfigModel) SaveContent(req *SaveConfigContentReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionEdit); err != nil {
+		return err
+	}
+
+	if err := validateContent(namespace.Format, req.Content); err != nil {
+		log.Warn(err)
+		return err
+	}
+	configs := map[string]string{ContentKey: req.Content}
+	if !namespace.Format.IsDocument() {
+		configs, _ = parseProperties(req.Content)
+	}
+
+	changes, err := c.planItems(namespace, configs, true)
+	if err != nil {
+		return err
+	}
+	return c.applyItems(namespace, changes, "save content", req.UserId, req.Request)
+}
+
+type itemChange struct {
+	Id       string     `json:"-"` //the id of deleted item is reused when create
+	Key      string     `json:"key"`
+	OldValue string     `json:"old_value"`
+	Value    string     `json:"value"`
+	OpType   com.OpType `json:"op_type"`
+	IsSecret bool       `json:"is_secret"` //the value is encrypted when applied
+}
+
+//the item changes to make the namespace hold the configs, the keys not in configs are deleted if overwrite
+func (c *ConfigModel) planItems(namespace *namespaceInfo, configs map[string]string, overwrite bool) ([]itemChange, error) {
+	if namespace.Format.IsDocument() {
+		for key := range configs {
+			if key != ContentKey {
+				return nil, errors.Errorf("%s namespace only has the %s item", namespace.Format, ContentKey)
+			}
+		}
+	}
+
+	var items []struct {
+		Id        string
+		Key       string
+		Value     string
+		ValueType ValueType
+		ValueRule ValueRule
+		IsSecret  int
+		IsDelete  int
+	}
+	db := database.Conn()
+	db = db.Table("item").Select("id,`key`,value,value_type,value_rule,is_secret,is_delete").Where("namespace_id=?", namespace.Id).Find(&items)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+
+	var changes []itemChange
+	existKeys := map[string]bool{}
+	for _, item := range items {
+		val, ok := configs[item.Key]
+		//the deleted item is created again as a new string one
+		if item.IsDelete == 1 {
+			if ok {
+				if err := validateValue(item.Key, val, ValueString, ValueRule{}); err != nil {
+					log.Warn(err)
+					return nil, err
+				}
+				changes = append(changes, itemChange{Id: item.Id, Key: item.Key, Value: val, OpType: com.OpCreate})
+				existKeys[item.Key] = true
+			}
+			continue
+		}
+		existKeys[item.Key] = true
+		isSecret := item.IsSecret == 1
+		if !ok {
+			if overwrite {
+				changes = append(changes, itemChange{Id: item.Id, Key: item.Key, OldValue: maskValue(item.Value), Value: maskValue(item.Value), OpType: com.OpDelete, IsSecret: isSecret})
+			}
+			continue
+		}
+		//the masked secret is kept unchanged
+		if isSecret && val == SecretMask {
+			continue
+		}
+		oldValue, err := openValue(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		if val == oldValue {
+			continue
+		}
+		if err := validateValue(item.Key, val, item.ValueType, item.ValueRule); err != nil {
+			log.Warn(err)
+			return nil, err
+		}
+		changes = append(changes, itemChange{Id: item.Id, Key: item.Key, OldValue: maskValue(item.Value), Value: val, OpType: com.OpUpdate, IsSecret: isSecret})
+	}
+	for key, val := range configs {
+		if existKeys[key] {
+			continue
+		}
+		if len(key) > 128 {
+			return nil, errors.Errorf("config key[%s] is too long", key)
+		}
+		if err := validateValue(key, val, ValueString, ValueRule{}); err != nil {
+			log.Warn(err)
+			return nil, err
+		}
+		changes = append(changes, itemChange{Key: key, Value: val, OpType: com.OpCreate})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes, nil
+}
+
+//apply the item changes in one transaction
+func (c *ConfigModel) applyItems(namespace *namespaceInfo, changes []itemChange, message, userId string, request RequestInfo) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	namespaceId := namespace.Id
+
+	now := time.Now().Unix()
+	tx := database.Conn().Begin()
+	nsMdl := NamespaceModel{}
+	tx, err := nsMdl.lock(tx, namespace, userId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	var itemOrderNum struct {
+		MaxOrderNum int
+	}
+	tx = tx.Raw("SELECT MAX(order_num) max_order_num FROM item WHERE namespace_id=? FOR UPDATE", namespaceId).Scan(&itemOrderNum)
+
+	var changeIds []string
+	for _, change := range changes {
+		if change.Id != "" {
+			changeIds = append(changeIds, change.Id)
+		}
+	}
+	tx, before := selectRows(tx, "item", changeIds...)
+
+	ids := map[com.OpType][]string{}
+	var insertItems []map[string]interface{}
+	for _, change := range changes {
+		if change.OpType != com.OpDelete {
+			value, err := sealValue(change.Value, change.IsSecret)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			change.Value = value
+		}
+		isSecret := 0
+		if change.IsSecret {
+			isSecret = 1
+		}
+		switch {
+		case change.OpType == com.OpCreate && change.Id == "":
+			itemOrderNum.MaxOrderNum++
+			id := uuid.NewV1().String()
+			insertItems = append(insertItems, map[string]interface{}{
+				"id":           id,
+				"namespace_id": namespaceId,
+				"key":          change.Key,
+				"value":        change.Value,
+				"value_type":   ValueString,
+				"value_rule":   ValueRule{},
+				"comment":      "",
+				"order_num":    itemOrderNum.MaxOrderNum,
+				"is_secret":    isSecret,
+				"is_delete":    0,
+				"create_by":    userId,
+				"create_time":  now,
+				"update_by":    userId,
+				"update_time":  now,
+			})
+			ids[com.OpCreate] = append(ids[com.OpCreate], id)
+		case change.OpType == com.OpCreate:
+			itemOrderNum.MaxOrderNum++
+			tx = database.Update(tx, "item", map[string]interface{}{
+				"value":       change.Value,
+				"value_type":  ValueString,
+				"value_rule":  ValueRule{},
+				"comment":     "",
+				"order_num":   itemOrderNum.MaxOrderNum,
+				"is_secret":   isSecret,
+				"is_delete":   0,
+				"version":     gorm.Expr("version+1"),
+				"update_by":   userId,
+				"update_time": now,
+			}, "id=?", change.Id)
+			ids[com.OpCreate] = append(ids[com.OpCreate], change.Id)
+		case change.OpType == com.OpUpdate:
+			tx = database.Update(tx, "item", map[string]interface{}{
+				"value":       change.Value,
+				"is_secret":   isSecret,
+				"version":     gorm.Expr("version+1"),
+				"update_by":   userId,
+				"update_time": now,
+			}, "id=?", change.Id)
+			ids[com.OpUpdate] = append(ids[com.OpUpdate], change.Id)
+		case change.OpType == com.OpDelete:
+			tx = database.Update(tx, "item", map[string]interface{}{
+				"is_delete":   1,
+				"version":     gorm.Expr("version+1"),
+				"update_by":   userId,
+				"update_time": now,
+			}, "id=?", change.Id)
+			ids[com.OpDelete] = append(ids[com.OpDelete], change.Id)
+		}
+	}
+	if len(insertItems) > 0 {
+		tx = database.InsertMany(tx, "item", insertItems)
+	}
+	for op, opIds := range ids {
+		tx = RecordTable(tx, "item", "", userId, request, op, before, opIds...)
+	}
+	tx, commits := c.recordCommit(tx, ids, message, userId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	c.fireCommits(commits, message, userId)
+	return nil
+}
@@ -0,0 +1,324 @@
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type NamespaceFormat string
+
+const (
+	FormatProperties NamespaceFormat = "properties"
+	FormatYaml       NamespaceFormat = "yaml"
+	FormatJson       NamespaceFormat = "json"
+	FormatToml       NamespaceFormat = "toml"
+	FormatXml        NamespaceFormat = "xml"
+)
+
+var namespaceFormatRule = validation.In(FormatProperties, FormatYaml, FormatJson, FormatToml, FormatXml)
+
+//the item key of the document namespace which holds the whole document
+const ContentKey = "content"
+
+//properties namespace is made of key/value items, others are edited and released as a whole document
+func (f NamespaceFormat) IsDocument() bool {
+	return f != FormatProperties && f != ""
+}
+
+//check the syntax of the document
+func validateContent(format NamespaceFormat, content string) error {
+	if _, err := parseContent(format, content); err != nil {
+		return errors.Errorf("invalid %s content: %s", format, err)
+	}
+	return nil
+}
+
+func parseContent(format NamespaceFormat, content string) (interface{}, error) {
+	switch format {
+	case FormatProperties, "":
+		return parseProperties(content)
+	case FormatYaml:
+		var doc interface{}
+		if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+			return nil, err
+		}
+		return yamlToJson(doc), nil
+	case FormatJson:
+		var doc interface{}
+		dec := json.NewDecoder(strings.NewReader(content))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+		if dec.More() {
+			return nil, errors.New("unexpected data after the document")
+		}
+		return doc, nil
+	case FormatToml:
+		var doc map[string]interface{}
+		if _, err := toml.Decode(content, &doc); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	case FormatXml:
+		return parseXml(content)
+	default:
+		return nil, errors.Errorf("unsupported format %s", format)
+	}
+}
+
+//flatten the document to dotted keys, e.g. db.hosts[0]
+func flattenContent(format NamespaceFormat, content string) (map[string]string, error) {
+	doc, err := parseContent(format, content)
+	if err != nil {
+		return nil, err
+	}
+	if props, ok := doc.(map[string]string); ok {
+		return props, nil
+	}
+	config := map[string]string{}
+	flattenValue("", doc, config)
+	return config, nil
+}
+
+func flattenValue(prefix string, val interface{}, config map[string]string) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flattenValue(key, child, config)
+		}
+	case []map[string]interface{}: //array of tables in toml
+		for i, child := range v {
+			flattenValue(fmt.Sprintf("%s[%d]", prefix, i), child, config)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenValue(fmt.Sprintf("%s[%d]", prefix, i), child, config)
+		}
+	case nil:
+		if prefix != "" {
+			config[prefix] = ""
+		}
+	case float64:
+		config[prefix] = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		if prefix != "" {
+			config[prefix] = fmt.Sprintf("%v", v)
+		}
+	}
+}
+
+//the configs exposed to clients, document is flattened and returned as content too
+func exposeConfig(format NamespaceFormat, config map[string]string) (map[string]string, string, error) {
+	if !format.IsDocument() {
+		return config, "", nil
+	}
+	content := config[ContentKey]
+	if strings.TrimSpace(content) == "" {
+		return map[string]string{}, content, nil
+	}
+	flat, err := flattenContent(format, content)
+	if err != nil {
+		return nil, content, errors.Errorf("invalid %s content: %s", format, err)
+	}
+	return flat, content, nil
+}
+
+//parse java style properties, support `=` and `:` separators, comments and line continuation
+func parseProperties(content string) (map[string]string, error) {
+	config := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	var logical string
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+		if strings.HasSuffix(line, "\\") && !strings.HasSuffix(line, "\\\\") {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		key, val := splitProperty(logical)
+		logical = ""
+		if key == "" {
+			return nil, errors.Errorf("line %d: empty key", lineNo)
+		}
+		config[key] = val
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if logical != "" {
+		key, val := splitProperty(logical)
+		if key == "" {
+			return nil, errors.Errorf("line %d: empty key", lineNo)
+		}
+		config[key] = val
+	}
+	return config, nil
+}
+
+func splitProperty(line string) (string, string) {
+	escaped := false
+	for i, ch := range line {
+		if escaped {
+			escaped = false
+			continue
+		}
+		switch ch {
+		case '\\':
+			escaped = true
+		case '=', ':', ' ', '\t':
+			key := line[:i]
+			rest := strings.TrimLeft(line[i+1:], " \t")
+			if (ch == ' ' || ch == '\t') && rest != "" && (rest[0] == '=' || rest[0] == ':') {
+				rest = strings.TrimLeft(rest[1:], " \t")
+			}
+			return unescapeProperty(key), unescapeProperty(rest)
+		}
+	}
+	return unescapeProperty(line), ""
+}
+
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	escaped := false
+	for _, ch := range s {
+		if !escaped {
+			if ch == '\\' {
+				escaped = true
+			} else {
+				b.WriteRune(ch)
+			}
+			continue
+		}
+		escaped = false
+		switch ch {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteRune(ch)
+		}
+	}
+	return b.String()
+}
+
+var propertyKeyEscaper = strings.NewReplacer("\\", "\\\\", "=", "\\=", ":", "\\:", " ", "\\ ", "\n", "\\n", "\t", "\\t", "\r", "\\r")
+var propertyValueEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\t", "\\t", "\r", "\\r")
+
+//render the configs as properties document sorted by key
+func renderProperties(config map[string]string) string {
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(propertyKeyEscaper.Replace(k))
+		b.WriteByte('=')
+		b.WriteString(propertyValueEscaper.Replace(config[k]))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+//decode xml to maps, attributes are prefixed with @ and repeated elements become arrays
+func parseXml(content string) (interface{}, error) {
+	dec := xml.NewDecoder(strings.NewReader(content))
+	var doc map[string]interface{}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if doc != nil {
+				return nil, errors.New("multiple root elements")
+			}
+			val, err := decodeXmlElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			doc = map[string]interface{}{t.Name.Local: val}
+		case xml.CharData:
+			if strings.TrimSpace(string(t)) != "" {
+				return nil, errors.New("text outside of the root element")
+			}
+		}
+	}
+	if doc == nil {
+		return nil, errors.New("no root element")
+	}
+	return doc, nil
+}
+
+func decodeXmlElement(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	node := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		node["@"+attr.Name.Local] = attr.Value
+	}
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXmlElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			if exist, ok := node[name]; !ok {
+				node[name] = child
+			} else if list, ok := exist.([]interface{}); ok {
+				node[name] = append(list, child)
+			} else {
+				node[name] = []interface{}{exist, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(node) == 0 {
+				return s, nil
+			}
+			if s != "" {
+				node["#text"] = s
+			}
+			return node, nil
+		}
+	}
+}
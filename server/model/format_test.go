@@ -0,0 +1,170 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  NamespaceFormat
+		content string
+		want    map[string]string
+	}{
+		{
+			name:    "yaml nested",
+			format:  FormatYaml,
+			content: "db:\n  host: a\n  port: 3306\n  hosts:\n    - h1\n    - h2\n  shards:\n    - [1, 2]\n    - name: s\nnone: ~\n",
+			want: map[string]string{
+				"db.host":           "a",
+				"db.port":           "3306",
+				"db.hosts[0]":       "h1",
+				"db.hosts[1]":       "h2",
+				"db.shards[0][0]":   "1",
+				"db.shards[0][1]":   "2",
+				"db.shards[1].name": "s",
+				"none":              "",
+			},
+		},
+		{
+			name:    "json nested",
+			format:  FormatJson,
+			content: `{"db":{"host":"a","port":3306,"ratio":0.5,"big":12345678901234567890,"on":true},"list":[[1,{"k":"v"}],null]}`,
+			want: map[string]string{
+				"db.host":      "a",
+				"db.port":      "3306",
+				"db.ratio":     "0.5",
+				"db.big":       "12345678901234567890",
+				"db.on":        "true",
+				"list[0][0]":   "1",
+				"list[0][1].k": "v",
+				"list[1]":      "",
+			},
+		},
+		{
+			name:    "toml nested",
+			format:  FormatToml,
+			content: "title = \"t\"\n[db]\nhost = \"a\"\nports = [1, 2]\n[[servers]]\nname = \"s1\"\n[[servers]]\nname = \"s2\"\n",
+			want: map[string]string{
+				"title":           "t",
+				"db.host":         "a",
+				"db.ports[0]":     "1",
+				"db.ports[1]":     "2",
+				"servers[0].name": "s1",
+				"servers[1].name": "s2",
+			},
+		},
+		{
+			name:    "xml nested",
+			format:  FormatXml,
+			content: `<conf env="dev"><db><host>a</host><host>b</host></db><name lang="en">demo</name></conf>`,
+			want: map[string]string{
+				"conf.@env":       "dev",
+				"conf.db.host[0]": "a",
+				"conf.db.host[1]": "b",
+				"conf.name.@lang": "en",
+				"conf.name.#text": "demo",
+			},
+		},
+		{
+			name:    "properties",
+			format:  FormatProperties,
+			content: "# comment\na=1\nb : 2\nc 3\nlong=x\\\n  y\n",
+			want:    map[string]string{"a": "1", "b": "2", "c": "3", "long": "xy"},
+		},
+		{
+			name:    "empty yaml",
+			format:  FormatYaml,
+			content: "",
+			want:    map[string]string{},
+		},
+		{
+			name:    "empty toml",
+			format:  FormatToml,
+			content: "",
+			want:    map[string]string{},
+		},
+		{
+			name:    "empty json object",
+			format:  FormatJson,
+			content: "{}",
+			want:    map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		got, err := flattenContent(tt.format, tt.content)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  NamespaceFormat
+		content string
+	}{
+		{"yaml bad indent", FormatYaml, "a:\n  b: 1\n c: 2\n"},
+		{"json trailing comma", FormatJson, `{"a":1,}`},
+		{"json two documents", FormatJson, `{"a":1} {"b":2}`},
+		{"json empty", FormatJson, ""},
+		{"toml duplicate key", FormatToml, "a = 1\na = 2\n"},
+		{"xml unclosed", FormatXml, "<a><b></a>"},
+		{"xml two roots", FormatXml, "<a/><b/>"},
+		{"xml text outside", FormatXml, "text<a/>"},
+		{"xml empty", FormatXml, ""},
+		{"properties empty key", FormatProperties, "=1\n"},
+		{"unsupported", NamespaceFormat("ini"), "a=1"},
+	}
+	for _, tt := range tests {
+		if err := validateContent(tt.format, tt.content); err == nil {
+			t.Errorf("%s: should be invalid", tt.name)
+		}
+	}
+}
+
+func TestPropertiesRoundTrip(t *testing.T) {
+	config := map[string]string{
+		"a":          "1",
+		"key with =": "v:1",
+		"path":       `C:\dir`,
+		"multi":      "line1\nline2\ttab",
+		"empty":      "",
+	}
+	content := renderProperties(config)
+	got, err := parseProperties(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, config) {
+		t.Errorf("round trip: got %v, want %v\n%s", got, config, content)
+	}
+}
+
+func TestExposeConfig(t *testing.T) {
+	props := map[string]string{"a": "1"}
+	got, content, err := exposeConfig(FormatProperties, props)
+	if err != nil || content != "" || !reflect.DeepEqual(got, props) {
+		t.Errorf("properties: %v %q %v", got, content, err)
+	}
+
+	got, content, err = exposeConfig(FormatJson, map[string]string{ContentKey: "  "})
+	if err != nil || len(got) != 0 || content != "  " {
+		t.Errorf("empty document: %v %q %v", got, content, err)
+	}
+
+	got, content, err = exposeConfig(FormatYaml, map[string]string{ContentKey: "a:\n  b: [1]\n"})
+	if err != nil || got["a.b[0]"] != "1" || content != "a:\n  b: [1]\n" {
+		t.Errorf("yaml document: %v %q %v", got, content, err)
+	}
+
+	if _, _, err := exposeConfig(FormatJson, map[string]string{ContentKey: "{"}); err == nil {
+		t.Error("invalid document should fail")
+	}
+}
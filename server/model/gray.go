@@ -0,0 +1,589 @@
+package model
+
+import (
+	"encoding/json"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/core"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"reflect"
+	"time"
+)
+
+type GrayStatus string
+
+const (
+	GrayActive    GrayStatus = "active"
+	GrayPromoted  GrayStatus = "promoted"
+	GrayAbandoned GrayStatus = "abandoned"
+)
+
+type GrayModel struct {
+}
+
+//the instances to use the gray release, matched by instance id or host
+type GrayRules struct {
+	InstanceIds []string `json:"instance_ids"`
+	Hosts       []string `json:"hosts"`
+}
+
+func (r *GrayRules) Match(instanceId, host string) bool {
+	for _, id := range r.InstanceIds {
+		if id == instanceId {
+			return true
+		}
+	}
+	for _, h := range r.Hosts {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+type activeGray struct {
+	Id         string
+	ReleaseId  string
+	Name       string
+	Comment    string
+	CreateBy   string
+	CreateTime int
+	Rules      GrayRules
+	Config     map[string]string
+}
+
+func (g *GrayModel) getActive(namespaceId string) (*activeGray, error) {
+	resp := &activeGray{}
+	var gray struct {
+		Id         string
+		ReleaseId  string
+		Name       string
+		Comment    string
+		CreateBy   string
+		CreateTime int
+		Rules      []byte
+		Config     []byte
+	}
+	db := database.Conn()
+	db = db.Table("gray_release t1").
+		Select("t1.id,t1.release_id,t1.rules,t1.create_by,t1.create_time,t2.name,t2.comment,t2.config").
+		Joins("JOIN `release` t2 ON t1.release_id=t2.id AND t2.is_delete=0").
+		Where("t1.namespace_id=? AND t1.status=? AND t1.is_delete=0", namespaceId, GrayActive).
+		Order("t1.create_time DESC").Limit(1).Scan(&gray)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if gray.Id == "" {
+		return resp, nil
+	}
+
+	if err := json.Unmarshal(gray.Rules, &resp.Rules); err != nil {
+		log.Error(err)
+		return resp, err
+	}
+	if err := json.Unmarshal(gray.Config, &resp.Config); err != nil {
+		log.Error(err)
+		return resp, err
+	}
+	resp.Id = gray.Id
+	resp.ReleaseId = gray.ReleaseId
+	resp.Name = gray.Name
+	resp.Comment = gray.Comment
+	resp.CreateBy = gray.CreateBy
+	resp.CreateTime = gray.CreateTime
+	return resp, nil
+}
+
+//match the instances which consume the namespace and whether they are in the gray rules
+func (g *GrayModel) consumerOf(namespace *namespaceInfo, rules GrayRules, inGray bool) (func(ins *core.Instance) bool, error) {
+	nsMdl := NamespaceModel{}
+	clusterIds, err := nsMdl.consumerClusterIds(namespace.AppId, namespace.ClusterId, namespace.Name)
+	if err != nil {
+		return nil, err
+	}
+	consumer := core.ConsumerOf(namespace.AppId, clusterIds...)
+	return func(ins *core.Instance) bool {
+		return consumer(ins) && rules.Match(ins.Id, ins.Host) == inGray
+	}, nil
+}
+
+//notify the instances matched of the change of the released configs, the failure is only logged
+//as the release is done already
+func (g *GrayModel) notify(namespace *namespaceInfo, rules GrayRules, inGray bool, releaseId string, oldConfig, newConfig map[string]string) {
+	cfgMdl := ConfigModel{}
+	match, err := g.consumerOf(namespace, rules, inGray)
+	if err != nil {
+		return
+	}
+	oldEffective, err := cfgMdl.effectiveConfig(namespace.PublicNamespaceId, oldConfig)
+	if err != nil {
+		return
+	}
+	newEffective, err := cfgMdl.effectiveConfig(namespace.PublicNamespaceId, newConfig)
+	if err != nil {
+		return
+	}
+	change, err := namespaceChange(namespace, releaseId, oldEffective, newEffective)
+	if err != nil {
+		return
+	}
+	go cfgMdl.notifyChange(match, change)
+}
+
+type GrayReleaseReq struct {
+	NamespaceId string      `json:"namespace_id"`
+	Name        string      `json:"name"`
+	Comment     string      `json:"comment"`
+	InstanceIds []string    `json:"instance_ids"`
+	Hosts       []string    `json:"hosts"`
+	UserId      string      `json:"-"`
+	Request     RequestInfo `json:"-"`
+}
+
+func (c *GrayReleaseReq) Validate() error {
+	err := validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Name, validation.Required, validation.Length(1, 64)),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+	if err != nil {
+		return err
+	}
+	if len(c.InstanceIds) == 0 && len(c.Hosts) == 0 {
+		return errors.New("instance_ids or hosts is required")
+	}
+	return nil
+}
+
+type GrayReleaseResp struct {
+	Id        string `json:"id"`
+	ReleaseId string `json:"release_id"`
+}
+
+//publish current configs to the instances matched by the rules, others keep the last release
+func (g *GrayModel) Release(req *GrayReleaseReq) (*GrayReleaseResp, error) {
+	resp := &GrayReleaseResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	if namespace.NeedApproval == 1 {
+		return resp, errors.New("the namespace needs release approval, gray release is not allowed")
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionRelease); err != nil {
+		return resp, err
+	}
+	if err := nsMdl.checkLockOwner(namespace, req.UserId); err != nil {
+		return resp, err
+	}
+
+	gray, err := g.getActive(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	if gray.Id != "" {
+		return resp, errors.New("the namespace already has an active gray release")
+	}
+
+	cfgMdl := ConfigModel{}
+	lastRelease, err := cfgMdl.getLastRelease(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	if err := cfgMdl.validateItems(req.NamespaceId, namespace.Format); err != nil {
+		return resp, err
+	}
+	itemMap, err := cfgMdl.getItemConfig(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	changes := diffConfigs(lastRelease.Config, itemMap)
+	if len(changes) == 0 {
+		return resp, errors.New("no new configs to gray release")
+	}
+
+	rules := GrayRules{
+		InstanceIds: req.InstanceIds,
+		Hosts:       req.Hosts,
+	}
+	rulesData, _ := json.Marshal(rules)
+	config, _ := json.Marshal(itemMap)
+
+	now := time.Now().Unix()
+	releaseId := uuid.NewV1().String()
+	historyId := uuid.NewV1().String()
+	grayId := uuid.NewV1().String()
+	tx := database.Conn().Begin()
+	tx, err = nsMdl.lock(tx, namespace, nsMdl.lockOwner(namespace, req.UserId))
+	if err != nil {
+		tx.Rollback()
+		return resp, err
+	}
+	//check again after the namespace row is locked, the concurrent gray release waits for it until committed
+	var active struct {
+		Id string
+	}
+	db := tx.New().Raw("SELECT id FROM gray_release WHERE namespace_id=? AND status=? AND is_delete=0 LIMIT 1 FOR UPDATE", req.NamespaceId, GrayActive).Scan(&active)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		tx.Rollback()
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if active.Id != "" {
+		tx.Rollback()
+		return resp, errors.New("the namespace already has an active gray release")
+	}
+	tx = database.Insert(tx, "`release`", map[string]interface{}{
+		"id":           releaseId,
+		"name":         req.Name,
+		"comment":      req.Comment,
+		"app_id":       namespace.AppId,
+		"cluster_id":   namespace.ClusterId,
+		"namespace_id": req.NamespaceId,
+		"config":       config,
+		"create_by":    req.UserId,
+		"create_time":  now,
+		"update_by":    req.UserId,
+		"update_time":  now,
+	})
+	tx = database.Insert(tx, "release_history", map[string]interface{}{
+		"id":             historyId,
+		"app_id":         namespace.AppId,
+		"cluster_id":     namespace.ClusterId,
+		"namespace_id":   req.NamespaceId,
+		"release_id":     releaseId,
+		"pre_release_id": lastRelease.ReleaseId,
+		"op_type":        ReleaseOpGray,
+		"create_by":      req.UserId,
+		"create_time":    now,
+		"update_by":      req.UserId,
+		"update_time":    now,
+	})
+	tx = database.Insert(tx, "gray_release", map[string]interface{}{
+		"id":           grayId,
+		"app_id":       namespace.AppId,
+		"cluster_id":   namespace.ClusterId,
+		"namespace_id": req.NamespaceId,
+		"release_id":   releaseId,
+		"rules":        rulesData,
+		"status":       GrayActive,
+		"create_by":    req.UserId,
+		"create_time":  now,
+		"update_by":    req.UserId,
+		"update_time":  now,
+	})
+	tx = RecordTable(tx, "release", "", req.UserId, req.Request, com.OpCreate, nil, releaseId)
+	tx = RecordTable(tx, "release_history", "", req.UserId, req.Request, com.OpCreate, nil, historyId)
+	tx = RecordTable(tx, "gray_release", "", req.UserId, req.Request, com.OpCreate, nil, grayId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return resp, errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	hookMdl := WebhookModel{}
+	hookMdl.fire(namespace, EventGrayRelease, releaseId, req.UserId, releaseEventData(lastRelease.ReleaseId, lastRelease.Config, itemMap, map[string]interface{}{
+		"gray_id": grayId,
+		"name":    req.Name,
+		"comment": req.Comment,
+		"rules":   rules,
+	}))
+
+	g.notify(namespace, rules, true, releaseId, lastRelease.Config, itemMap)
+
+	resp.Id = grayId
+	resp.ReleaseId = releaseId
+	return resp, nil
+}
+
+type GrayOperateReq struct {
+	NamespaceId string      `json:"namespace_id"`
+	UserId      string      `json:"-"`
+	Request     RequestInfo `json:"-"`
+}
+
+func (c *GrayOperateReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//make the gray release to be the release of all instances
+func (g *GrayModel) Promote(req *GrayOperateReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionRelease); err != nil {
+		return err
+	}
+	gray, err := g.getActive(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+	if gray.Id == "" {
+		return errors.New("the namespace has no active gray release")
+	}
+
+	//the released configs should be exactly what the gray instances run
+	cfgMdl := ConfigModel{}
+	itemMap, err := cfgMdl.getItemConfig(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(itemMap, gray.Config) {
+		return errors.New("configs changed after gray release, abandon it and gray release again")
+	}
+
+	lastRelease, err := cfgMdl.getLastRelease(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	historyId := uuid.NewV1().String()
+	tx := database.Conn().Begin()
+	tx, err = nsMdl.lock(tx, namespace, nsMdl.lockOwner(namespace, req.UserId))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	//the gray release may be promoted or abandoned by others at the same time
+	tx, before := selectRows(tx, "gray_release", gray.Id)
+	tx = database.Update(tx, "gray_release", map[string]interface{}{
+		"status":      GrayPromoted,
+		"update_by":   req.UserId,
+		"update_time": now,
+	}, "id=? AND status=?", gray.Id, GrayActive)
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("the gray release is not active any more")
+	}
+	tx = database.Insert(tx, "release_history", map[string]interface{}{
+		"id":             historyId,
+		"app_id":         namespace.AppId,
+		"cluster_id":     namespace.ClusterId,
+		"namespace_id":   req.NamespaceId,
+		"release_id":     gray.ReleaseId,
+		"pre_release_id": lastRelease.ReleaseId,
+		"op_type":        ReleaseOpNormal,
+		"create_by":      req.UserId,
+		"create_time":    now,
+		"update_by":      req.UserId,
+		"update_time":    now,
+	})
+	tx = RecordTable(tx, "release_history", "", req.UserId, req.Request, com.OpCreate, nil, historyId)
+	tx = RecordTable(tx, "gray_release", "", req.UserId, req.Request, com.OpUpdate, before, gray.Id)
+	tx = nsMdl.unlock(tx, req.NamespaceId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	hookMdl := WebhookModel{}
+	hookMdl.fire(namespace, EventGrayPromote, gray.ReleaseId, req.UserId, releaseEventData(lastRelease.ReleaseId, lastRelease.Config, gray.Config, map[string]interface{}{
+		"gray_id": gray.Id,
+	}))
+
+	//the gray instances already run the release
+	g.notify(namespace, gray.Rules, false, gray.ReleaseId, lastRelease.Config, gray.Config)
+	if namespace.IsPublic == 1 {
+		go cfgMdl.notifyAssociated(namespace.Id, lastRelease.Config, gray.Config, gray.ReleaseId)
+	}
+
+	return nil
+}
+
+//the gray instances go back to the last release
+func (g *GrayModel) Abandon(req *GrayOperateReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionRelease); err != nil {
+		return err
+	}
+	gray, err := g.getActive(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+	if gray.Id == "" {
+		return errors.New("the namespace has no active gray release")
+	}
+
+	cfgMdl := ConfigModel{}
+	lastRelease, err := cfgMdl.getLastRelease(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	tx := database.Conn().Begin()
+	tx, beforeGray := selectRows(tx, "gray_release", gray.Id)
+	tx, beforeRelease := selectRows(tx, "release", gray.ReleaseId)
+	tx = database.Update(tx, "gray_release", map[string]interface{}{
+		"status":      GrayAbandoned,
+		"update_by":   req.UserId,
+		"update_time": now,
+	}, "id=? AND status=?", gray.Id, GrayActive)
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("the gray release is not active any more")
+	}
+	tx = database.Update(tx, "`release`", map[string]interface{}{
+		"is_disabled": 1,
+		"update_by":   req.UserId,
+		"update_time": now,
+	}, "id=?", gray.ReleaseId)
+	tx = RecordTable(tx, "gray_release", "", req.UserId, req.Request, com.OpUpdate, beforeGray, gray.Id)
+	tx = RecordTable(tx, "release", "", req.UserId, req.Request, com.OpUpdate, beforeRelease, gray.ReleaseId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	hookMdl := WebhookModel{}
+	hookMdl.fire(namespace, EventGrayAbandon, gray.ReleaseId, req.UserId, map[string]interface{}{
+		"gray_id":        gray.Id,
+		"pre_release_id": lastRelease.ReleaseId, //the release the gray instances go back to
+	})
+
+	g.notify(namespace, gray.Rules, true, lastRelease.ReleaseId, gray.Config, lastRelease.Config)
+
+	return nil
+}
+
+type GrayDetailReq struct {
+	NamespaceId string `json:"namespace_id"`
+	UserId      string `json:"-"`
+}
+
+func (c *GrayDetailReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type GrayDetailResp struct {
+	Id         string                `json:"id"`
+	ReleaseId  string                `json:"release_id"`
+	Name       string                `json:"name"`
+	Comment    string                `json:"comment"`
+	Rules      GrayRules             `json:"rules"`
+	CreateBy   string                `json:"create_by"`
+	CreateTime int                   `json:"create_time"`
+	Config     map[string]string     `json:"config"`
+	Change     map[string]ChangeItem `json:"change"`
+	Instances  []InstanceItem        `json:"instances"`
+}
+
+//the active gray release of the namespace and the instances running it, empty id if none
+func (g *GrayModel) Detail(req *GrayDetailReq) (*GrayDetailResp, error) {
+	resp := &GrayDetailResp{
+		Config:    map[string]string{},
+		Change:    map[string]ChangeItem{},
+		Instances: []InstanceItem{},
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
+
+	gray, err := g.getActive(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	if gray.Id == "" {
+		return resp, nil
+	}
+
+	cfgMdl := ConfigModel{}
+	lastRelease, err := cfgMdl.getLastRelease(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	for op, configs := range diffConfigs(lastRelease.Config, gray.Config) {
+		for _, cf := range configs {
+			change := ChangeItem{
+				Key:  cf.Key,
+				Type: op,
+			}
+			if op == com.OpDelete {
+				change.OldValue = cf.Value
+			} else {
+				change.NewValue = cf.Value
+				change.OldValue = lastRelease.Config[cf.Key]
+			}
+			resp.Change[cf.Key] = change
+		}
+	}
+
+	db := database.Conn()
+	db = db.Table("instance t1").Select("t1.id,t1.host,t1.port,t1.create_time,t1.update_time").
+		Joins("JOIN instance_release t2 ON t2.instance_id=t1.id AND t2.is_delete=0").
+		Where("t2.namespace_id=? AND t2.release_id=? AND t1.is_delete=0", req.NamespaceId, gray.ReleaseId).
+		Find(&resp.Instances)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	resp.Id = gray.Id
+	resp.ReleaseId = gray.ReleaseId
+	resp.Name = gray.Name
+	resp.Comment = gray.Comment
+	resp.Rules = gray.Rules
+	resp.CreateBy = gray.CreateBy
+	resp.CreateTime = gray.CreateTime
+	resp.Config = maskConfig(gray.Config)
+	maskChange(resp.Change)
+	return resp, nil
+}
@@ -0,0 +1,369 @@
+package model
+
+import (
+	"encoding/json"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"sort"
+)
+
+//the release made effective at the time
+type releaseLive struct {
+	ReleaseId string
+	Name      string
+	Time      int
+	Config    map[string]string
+}
+
+//the effective releases of the namespace since the time in order
+func (c *ConfigModel) getReleasesSince(namespaceId string, since int) ([]releaseLive, error) {
+	var histories []struct {
+		ReleaseId  string
+		Name       string
+		CreateTime int
+		Config     []byte
+	}
+	db := database.Conn()
+	db = db.Table("release_history t1").Select("t1.release_id,t2.name,t1.create_time,t2.config").
+		Joins("JOIN `release` t2 ON t1.release_id=t2.id AND t2.is_delete=0").
+		Where("t1.namespace_id=? AND t1.op_type IN (?) AND t1.create_time>=? AND t1.is_delete=0",
+			namespaceId, []ReleaseOpType{ReleaseOpNormal, ReleaseOpRollback}, since).
+		Order("t1.create_time").Find(&histories)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+
+	releases := make([]releaseLive, 0, len(histories))
+	for _, h := range histories {
+		config := map[string]string{}
+		if err := json.Unmarshal(h.Config, &config); err != nil {
+			log.Error(err)
+			continue
+		}
+		releases = append(releases, releaseLive{
+			ReleaseId: h.ReleaseId,
+			Name:      h.Name,
+			Time:      h.CreateTime,
+			Config:    config,
+		})
+	}
+	return releases, nil
+}
+
+//the first release between the times which has the value of the key, or has no such key if deleted
+func liveRelease(releases []releaseLive, key, value string, deleted bool, from, until int) *releaseLive {
+	for i := range releases {
+		r := &releases[i]
+		if r.Time < from {
+			continue
+		}
+		if until != 0 && r.Time > until {
+			break
+		}
+		val, ok := r.Config[key]
+		if (deleted && !ok) || (!deleted && ok && val == value) {
+			return r
+		}
+	}
+	return nil
+}
+
+type KeyHistoryReq struct {
+	NamespaceId string `json:"namespace_id"`
+	Key         string `json:"key"`
+	Limit       int    `json:"limit"`
+	Offset      int    `json:"offset"`
+	UserId      string `json:"-"`
+}
+
+func (c *KeyHistoryReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Key, validation.Required, validation.Length(1, 128)),
+		validation.Field(&c.Limit, validation.Max(100)),
+		validation.Field(&c.Offset, validation.Min(0)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type KeyHistoryItem struct {
+	CommitId    string     `json:"commit_id"`
+	Message     string     `json:"message"`
+	OpType      com.OpType `json:"op_type"`
+	Value       string     `json:"value"`
+	ValueType   ValueType  `json:"value_type"`
+	Comment     string     `json:"comment"`
+	IsSecret    int        `json:"is_secret"`
+	CreateBy    string     `json:"create_by"`
+	CreateTime  int        `json:"create_time"`
+	ReleaseId   string     `json:"release_id"` //the release the change went live in, empty if never
+	ReleaseName string     `json:"release_name"`
+	ReleaseTime int        `json:"release_time"`
+}
+
+type KeyHistoryResp struct {
+	List   []KeyHistoryItem `json:"list"`
+	Offset int              `json:"offset"`
+	Total  int              `json:"total"`
+}
+
+//every change of the key in the namespace from the newest, with the release it went live in
+func (c *ConfigModel) GetKeyHistory(req *KeyHistoryReq) (*KeyHistoryResp, error) {
+	resp := &KeyHistoryResp{
+		List:   []KeyHistoryItem{},
+		Offset: -1,
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	//the change sets are json, find the commits mentioning the key first
+	quoted, _ := json.Marshal(req.Key)
+	pattern := database.EscapeLike(`"key":` + string(quoted))
+	var commits []struct {
+		Id         string
+		Message    string
+		ChangeSets []byte
+		CreateBy   string
+		CreateTime int
+	}
+	db := database.Conn()
+	db = db.Table("commit").Select("id,message,change_sets,create_by,create_time").
+		Where("namespace_id=? AND change_sets LIKE ? AND is_delete=0", req.NamespaceId, "%"+pattern+"%").
+		Order("create_time").Find(&commits)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	var history []KeyHistoryItem
+	for _, cm := range commits {
+		var sets CommitItem
+		if err := json.Unmarshal(cm.ChangeSets, &sets); err != nil {
+			log.Error(err)
+			continue
+		}
+		for op, items := range sets {
+			for _, item := range items {
+				if item.Key != req.Key {
+					continue
+				}
+				history = append(history, KeyHistoryItem{
+					CommitId:   cm.Id,
+					Message:    cm.Message,
+					OpType:     op,
+					Value:      item.Value,
+					ValueType:  item.ValueType,
+					Comment:    item.Comment,
+					IsSecret:   item.IsSecret,
+					CreateBy:   cm.CreateBy,
+					CreateTime: cm.CreateTime,
+				})
+			}
+		}
+	}
+	resp.Total = len(history)
+	if len(history) == 0 {
+		return resp, nil
+	}
+
+	releases, err := c.getReleasesSince(req.NamespaceId, history[0].CreateTime)
+	if err != nil {
+		return resp, err
+	}
+	for i := range history {
+		until := 0
+		if i+1 < len(history) {
+			until = history[i+1].CreateTime
+		}
+		live := liveRelease(releases, req.Key, history[i].Value, history[i].OpType == com.OpDelete, history[i].CreateTime, until)
+		if live != nil {
+			history[i].ReleaseId = live.ReleaseId
+			history[i].ReleaseName = live.Name
+			history[i].ReleaseTime = live.Time
+		}
+		history[i].Value = maskValue(history[i].Value)
+	}
+
+	//the newest first
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].CreateTime > history[j].CreateTime
+	})
+	if req.Offset < len(history) {
+		end := req.Offset + req.Limit
+		if end > len(history) {
+			end = len(history)
+		}
+		resp.List = history[req.Offset:end]
+	}
+	if len(resp.List) < req.Limit {
+		resp.Offset = -1
+	} else {
+		resp.Offset = req.Offset + len(resp.List)
+	}
+	return resp, nil
+}
+
+type ConfigBlameReq struct {
+	NamespaceId string `json:"namespace_id"`
+	UserId      string `json:"-"`
+}
+
+func (c *ConfigBlameReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type BlameItem struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	CommitId    string `json:"commit_id"` //empty if the item is changed before commits were recorded
+	Message     string `json:"message"`
+	UpdateBy    string `json:"update_by"`
+	UpdateTime  int    `json:"update_time"`
+	ReleaseId   string `json:"release_id"` //the release the current value went live in, empty if unreleased
+	ReleaseName string `json:"release_name"`
+	ReleaseTime int    `json:"release_time"`
+}
+
+type ConfigBlameResp struct {
+	List []BlameItem `json:"list"`
+}
+
+//the last change of each current item of the namespace
+func (c *ConfigModel) Blame(req *ConfigBlameReq) (*ConfigBlameResp, error) {
+	resp := &ConfigBlameResp{
+		List: []BlameItem{},
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
+
+	var items []struct {
+		Key        string
+		Value      string
+		UpdateBy   string
+		UpdateTime int
+	}
+	db := database.Conn()
+	db = db.Table("item").Select("`key`,value,update_by,update_time").
+		Where("namespace_id=? AND is_delete=0", req.NamespaceId).Order("order_num").Find(&items)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if len(items) == 0 {
+		return resp, nil
+	}
+
+	blames := make(map[string]*BlameItem, len(items))
+	for _, item := range items {
+		blames[item.Key] = &BlameItem{
+			Key:        item.Key,
+			Value:      item.Value,
+			UpdateBy:   item.UpdateBy,
+			UpdateTime: item.UpdateTime,
+		}
+	}
+
+	//walk the commits from the newest until every key is found
+	const pageSize = 100
+	found := 0
+	for offset := 0; found < len(blames); offset += pageSize {
+		var commits []struct {
+			Id         string
+			Message    string
+			ChangeSets []byte
+			CreateBy   string
+			CreateTime int
+		}
+		db = database.Conn()
+		db = db.Table("commit").Select("id,message,change_sets,create_by,create_time").
+			Where("namespace_id=? AND is_delete=0", req.NamespaceId).
+			Order("create_time DESC").Offset(offset).Limit(pageSize).Find(&commits)
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			log.Error(db.Error)
+			return resp, errors.Wrap(db.Error, "db error")
+		}
+		for _, cm := range commits {
+			var sets CommitItem
+			if err := json.Unmarshal(cm.ChangeSets, &sets); err != nil {
+				log.Error(err)
+				continue
+			}
+			for op, setItems := range sets {
+				if op == com.OpDelete {
+					continue
+				}
+				for _, item := range setItems {
+					blame, ok := blames[item.Key]
+					if !ok || blame.CommitId != "" {
+						continue
+					}
+					blame.CommitId = cm.Id
+					blame.Message = cm.Message
+					blame.UpdateBy = cm.CreateBy
+					blame.UpdateTime = cm.CreateTime
+					found++
+				}
+			}
+		}
+		if len(commits) < pageSize {
+			break
+		}
+	}
+
+	since := 0
+	for _, blame := range blames {
+		if since == 0 || blame.UpdateTime < since {
+			since = blame.UpdateTime
+		}
+	}
+	releases, err := c.getReleasesSince(req.NamespaceId, since)
+	if err != nil {
+		return resp, err
+	}
+	for _, item := range items {
+		blame := blames[item.Key]
+		if live := liveRelease(releases, blame.Key, blame.Value, false, blame.UpdateTime, 0); live != nil {
+			blame.ReleaseId = live.ReleaseId
+			blame.ReleaseName = live.Name
+			blame.ReleaseTime = live.Time
+		}
+		blame.Value = maskValue(blame.Value)
+		resp.List = append(resp.List, *blame)
+	}
+	return resp, nil
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/hackbeex/configcenter/util/log"
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"time"
 )
 
 type InstanceModel struct {
@@ -16,12 +18,14 @@ type InstanceModel struct {
 type InstanceListReq struct {
 	AppId     string `json:"app_id"`
 	ClusterId string `json:"cluster_id"`
+	UserId    string `json:"-"`
 }
 
 func (c *InstanceListReq) Validate() error {
 	return validation.ValidateStruct(c,
 		validation.Field(&c.AppId, validation.Required),
 		validation.Field(&c.ClusterId, validation.Required),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
 	)
 }
 
@@ -46,6 +50,10 @@ func (m *InstanceModel) List(req *InstanceListReq) (*InstanceListResp, error) {
 		log.Warn(err)
 		return resp, err
 	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, req.AppId, req.ClusterId, "", ActionView); err != nil {
+		return resp, err
+	}
 
 	db := database.Conn()
 	db = db.Table("instance").Select("id,host,port,create_time,update_time").
@@ -59,7 +67,8 @@ func (m *InstanceModel) List(req *InstanceListReq) (*InstanceListResp, error) {
 }
 
 type ExitInstanceReq struct {
-	InstanceId string `json:"instance_id"`
+	InstanceId  string `json:"instance_id"`
+	AccessAppId string `json:"-"` //the app of the access key
 }
 
 func (c *ExitInstanceReq) Validate() error {
@@ -79,9 +88,77 @@ func (m *InstanceModel) ExitInstance(req *ExitInstanceReq) error {
 	if !ok {
 		return nil
 	}
+	if ins.AppId != req.AccessAppId {
+		return errors.New("the access key can not access the instance")
+	}
 	ins.Life = 0
 	ins.Status = com.OfflineStatus
 	instances.Store(req.InstanceId, ins)
 
 	return nil
 }
+
+//record the release which the instance runs per namespace
+func (m *InstanceModel) recordRelease(instanceId string, releaseIds map[string]string) error {
+	var records []struct {
+		Id          string
+		NamespaceId string
+		ReleaseId   string
+	}
+	db := database.Conn()
+	db = db.Table("instance_release").Select("id,namespace_id,release_id").Where("instance_id=? AND is_delete=0", instanceId).Find(&records)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	recordMap := map[string]string{}
+	var changeIds []string
+	for _, r := range records {
+		if releaseIds[r.NamespaceId] != "" && releaseIds[r.NamespaceId] != r.ReleaseId {
+			recordMap[r.NamespaceId] = r.Id
+			changeIds = append(changeIds, r.Id)
+		} else {
+			recordMap[r.NamespaceId] = ""
+		}
+	}
+
+	now := time.Now().Unix()
+	var insertIds []string
+	var updateIds []string
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "instance_release", changeIds...)
+	for nsId, releaseId := range releaseIds {
+		if releaseId == "" {
+			continue
+		}
+		id, ok := recordMap[nsId]
+		if !ok {
+			id = uuid.NewV1().String()
+			tx = database.Insert(tx, "instance_release", map[string]interface{}{
+				"id":           id,
+				"instance_id":  instanceId,
+				"namespace_id": nsId,
+				"release_id":   releaseId,
+				"create_time":  now,
+				"update_time":  now,
+			})
+			insertIds = append(insertIds, id)
+		} else if id != "" {
+			tx = database.Update(tx, "instance_release", map[string]interface{}{
+				"release_id":  releaseId,
+				"update_time": now,
+			}, "id=?", id)
+			updateIds = append(updateIds, id)
+		}
+	}
+	tx = RecordTable(tx, "instance_release", "", "", RequestInfo{}, com.OpCreate, nil, insertIds...)
+	tx = RecordTable(tx, "instance_release", "", "", RequestInfo{}, com.OpUpdate, before, updateIds...)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/core"
 	"github.com/hackbeex/configcenter/server/database"
 	"github.com/hackbeex/configcenter/util/com"
 	"github.com/hackbeex/configcenter/util/log"
@@ -11,14 +12,20 @@ import (
 	"time"
 )
 
+const DefaultNamespaceName = "application"
+
 type NamespaceModel struct {
 }
 
 type CreateNamespaceReq struct {
-	ClusterId string `json:"cluster_id"`
-	Name      string `json:"name"`
-	Comment   string `json:"comment"`
-	UserId    string `json:"user_id"`
+	ClusterId    string          `json:"cluster_id"`
+	Name         string          `json:"name"`
+	Comment      string          `json:"comment"`
+	Format       NamespaceFormat `json:"format"` //properties by default
+	IsPublic     bool            `json:"is_public"`
+	NeedApproval bool            `json:"need_approval"` //release must be approved by another user
+	UserId       string          `json:"-"`
+	Request      RequestInfo     `json:"-"`
 }
 
 func (c *CreateNamespaceReq) Validate() error {
@@ -26,6 +33,7 @@ func (c *CreateNamespaceReq) Validate() error {
 		validation.Field(&c.ClusterId, validation.Required, validation.Length(36, 36)),
 		validation.Field(&c.Name, validation.Required, validation.Length(1, 64)),
 		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.Format, namespaceFormatRule),
 		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
 	)
 }
@@ -41,6 +49,9 @@ func (a *NamespaceModel) Create(req *CreateNamespaceReq) (*CreateNamespaceResp,
 		log.Warn(err)
 		return resp, err
 	}
+	if req.Format == "" {
+		req.Format = FormatProperties
+	}
 
 	var cluster struct {
 		Id    string
@@ -55,38 +66,176 @@ func (a *NamespaceModel) Create(req *CreateNamespaceReq) (*CreateNamespaceResp,
 	if cluster.Id == "" {
 		return resp, errors.New("the cluster not exists")
 	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, cluster.AppId, cluster.Id, "", ActionManage); err != nil {
+		return resp, err
+	}
 
 	var existNamespace struct {
 		Id string
 	}
 	db = database.Conn()
-	db = db.Table("namespace").Select("id").Where("name=? AND is_delete=0", req.Name).Scan(&existNamespace)
+	db = db.Table("namespace").Select("id").Where("cluster_id=? AND name=? AND is_delete=0", req.ClusterId, req.Name).Scan(&existNamespace)
 	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
 		log.Error(db.Error)
 		return resp, errors.Wrap(db.Error, "db error")
 	}
 	if existNamespace.Id != "" {
-		return resp, errors.New("the namespace name exists")
+		return resp, errors.New("the namespace name exists in the cluster")
+	}
+
+	needApproval := 0
+	if req.NeedApproval {
+		needApproval = 1
+	}
+
+	//public namespace is shared by name among apps
+	isPublic := 0
+	if req.IsPublic {
+		isPublic = 1
+		db = database.Conn()
+		db = db.Table("namespace").Select("id").Where("name=? AND is_public=1 AND is_delete=0", req.Name).Scan(&existNamespace)
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			log.Error(db.Error)
+			return resp, errors.Wrap(db.Error, "db error")
+		}
+		if existNamespace.Id != "" {
+			return resp, errors.New("the public namespace name exists")
+		}
 	}
 
 	now := time.Now().Unix()
 	id := uuid.NewV1().String()
 	tx := database.Conn().Begin()
 	tx = database.Insert(tx, "namespace", map[string]interface{}{
-		"id":          id,
-		"app_id":      cluster.AppId,
-		"cluster_id":  req.ClusterId,
-		"is_public":   0,
-		"name":        req.Name,
-		"comment":     req.Comment,
-		"create_by":   req.UserId,
-		"create_time": now,
-		"update_by":   req.UserId,
-		"update_time": now,
+		"id":            id,
+		"app_id":        cluster.AppId,
+		"cluster_id":    req.ClusterId,
+		"is_public":     isPublic,
+		"name":          req.Name,
+		"format":        req.Format,
+		"need_approval": needApproval,
+		"comment":       req.Comment,
+		"create_by":     req.UserId,
+		"create_time":   now,
+		"update_by":     req.UserId,
+		"update_time":   now,
+	})
+	tx = RecordTable(tx, "namespace", "", req.UserId, req.Request, com.OpCreate, nil, id)
+	if tx.Error != nil {
+		tx.Rollback()
+		if database.IsDuplicate(tx.Error) {
+			return resp, errors.New("the namespace name exists in the cluster")
+		}
+		log.Error(tx.Error)
+		return resp, errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	resp.Id = id
+	return resp, nil
+}
+
+type AssociateNamespaceReq struct {
+	ClusterId         string      `json:"cluster_id"`
+	PublicNamespaceId string      `json:"public_namespace_id"`
+	Comment           string      `json:"comment"`
+	UserId            string      `json:"-"`
+	Request           RequestInfo `json:"-"`
+}
+
+func (c *AssociateNamespaceReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.ClusterId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.PublicNamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//associate the public namespace to the cluster, the items of the associated namespace override the public ones
+func (a *NamespaceModel) Associate(req *AssociateNamespaceReq) (*CreateNamespaceResp, error) {
+	resp := &CreateNamespaceResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	var cluster struct {
+		Id    string
+		AppId string
+	}
+	db := database.Conn()
+	db = db.Table("cluster").Select("id,app_id").Where("id=? AND is_delete=0", req.ClusterId).Scan(&cluster)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if cluster.Id == "" {
+		return resp, errors.New("the cluster not exists")
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, cluster.AppId, cluster.Id, "", ActionManage); err != nil {
+		return resp, err
+	}
+
+	var public struct {
+		Id        string
+		Name      string
+		Format    NamespaceFormat
+		ClusterId string
+	}
+	db = database.Conn()
+	db = db.Table("namespace").Select("id,name,format,cluster_id").Where("id=? AND is_public=1 AND is_delete=0", req.PublicNamespaceId).Scan(&public)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if public.Id == "" {
+		return resp, errors.New("the public namespace not exists")
+	}
+	if public.ClusterId == req.ClusterId {
+		return resp, errors.New("the public namespace belongs to the cluster")
+	}
+
+	var existNamespace struct {
+		Id string
+	}
+	db = database.Conn()
+	db = db.Table("namespace").Select("id").Where("cluster_id=? AND name=? AND is_delete=0", req.ClusterId, public.Name).Scan(&existNamespace)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if existNamespace.Id != "" {
+		return resp, errors.New("the namespace name exists in the cluster")
+	}
+
+	now := time.Now().Unix()
+	id := uuid.NewV1().String()
+	tx := database.Conn().Begin()
+	tx = database.Insert(tx, "namespace", map[string]interface{}{
+		"id":                  id,
+		"app_id":              cluster.AppId,
+		"cluster_id":          req.ClusterId,
+		"is_public":           0,
+		"public_namespace_id": req.PublicNamespaceId,
+		"name":                public.Name,
+		"format":              public.Format,
+		"comment":             req.Comment,
+		"create_by":           req.UserId,
+		"create_time":         now,
+		"update_by":           req.UserId,
+		"update_time":         now,
 	})
-	tx = RecordTable(tx, "namespace", "", req.UserId, com.OpCreate, id)
+	tx = RecordTable(tx, "namespace", "associate", req.UserId, req.Request, com.OpCreate, nil, id)
 	if tx.Error != nil {
 		tx.Rollback()
+		if database.IsDuplicate(tx.Error) {
+			return resp, errors.New("the namespace name exists in the cluster")
+		}
 		log.Error(tx.Error)
 		return resp, errors.Wrap(tx.Error, "db error")
 	} else {
@@ -96,3 +245,627 @@ func (a *NamespaceModel) Create(req *CreateNamespaceReq) (*CreateNamespaceResp,
 	resp.Id = id
 	return resp, nil
 }
+
+type PublicNamespaceListReq struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+func (c *PublicNamespaceListReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Limit, validation.Max(100)),
+		validation.Field(&c.Offset, validation.Min(0)),
+	)
+}
+
+type PublicNamespaceItem struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Comment string `json:"comment"`
+	AppId   string `json:"app_id"`
+	AppName string `json:"app_name"`
+}
+
+type PublicNamespaceListResp struct {
+	Offset int                   `json:"offset"`
+	Total  int                   `json:"total"`
+	List   []PublicNamespaceItem `json:"list"`
+}
+
+func (a *NamespaceModel) ListPublic(req *PublicNamespaceListReq) (*PublicNamespaceListResp, error) {
+	resp := &PublicNamespaceListResp{
+		List:   []PublicNamespaceItem{},
+		Offset: -1,
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	db := database.Conn()
+	db = db.Table("namespace t1").Select("t1.id,t1.name,t1.comment,t1.app_id,t2.name app_name").
+		Joins("JOIN app t2 ON t1.app_id=t2.id AND t2.is_delete=0").
+		Where("t1.is_public=1 AND t1.is_delete=0").Offset(req.Offset).Limit(req.Limit).Find(&resp.List)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	if len(resp.List) < req.Limit {
+		resp.Offset = -1
+	} else {
+		resp.Offset = req.Offset + len(resp.List)
+	}
+
+	db = database.Conn()
+	db = db.Table("namespace").Where("is_public=1 AND is_delete=0").Count(&resp.Total)
+	if db.Error != nil {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	return resp, nil
+}
+
+//the namespaces used by the cluster, include the namespaces of default cluster which are not overridden
+func (a *NamespaceModel) listByCluster(appId, clusterId string) ([]NamespaceItem, error) {
+	var namespaces []struct {
+		NamespaceItem
+		ClusterId string
+	}
+	db := database.Conn()
+	db = db.Table("namespace t1").Select("t1.id,t1.name,t1.comment,t1.format,t1.is_public,t1.public_namespace_id,t1.cluster_id").
+		Joins("JOIN cluster t2 ON t1.cluster_id=t2.id AND t2.is_delete=0").
+		Where("t1.app_id=? AND (t1.cluster_id=? OR t2.name=?) AND t1.is_delete=0", appId, clusterId, DefaultClusterName).
+		Find(&namespaces)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+
+	own := map[string]bool{}
+	for _, ns := range namespaces {
+		if ns.ClusterId == clusterId {
+			own[ns.Name] = true
+		}
+	}
+	list := make([]NamespaceItem, 0, len(namespaces))
+	for _, ns := range namespaces {
+		if ns.ClusterId != clusterId && own[ns.Name] {
+			continue
+		}
+		list = append(list, ns.NamespaceItem)
+	}
+	return list, nil
+}
+
+//the clusters which use the namespace, namespace of default cluster is used by clusters which do not override it
+func (a *NamespaceModel) consumerClusterIds(appId, clusterId, name string) ([]string, error) {
+	var cluster struct {
+		Name string
+	}
+	db := database.Conn()
+	db = db.Table("cluster").Select("name").Where("id=?", clusterId).Scan(&cluster)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+	if cluster.Name != DefaultClusterName {
+		return []string{clusterId}, nil
+	}
+
+	var clusters []struct {
+		Id string
+	}
+	db = database.Conn()
+	db = db.Table("cluster t1").Select("t1.id").
+		Joins("LEFT JOIN namespace t2 ON t2.cluster_id=t1.id AND t2.name=? AND t2.is_delete=0", name).
+		Where("t1.app_id=? AND t1.is_delete=0 AND (t1.id=? OR t2.id IS NULL)", appId, clusterId).
+		Find(&clusters)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+	ids := make([]string, 0, len(clusters))
+	for _, c := range clusters {
+		ids = append(ids, c.Id)
+	}
+	return ids, nil
+}
+
+type namespaceInfo struct {
+	Id                string
+	Name              string
+	AppId             string
+	ClusterId         string
+	Format            NamespaceFormat
+	IsPublic          int
+	PublicNamespaceId string
+	NeedApproval      int
+	LockBy            string
+	LockTime          int
+	Version           int
+}
+
+func (a *NamespaceModel) getInfo(namespaceId string) (*namespaceInfo, error) {
+	namespace := &namespaceInfo{}
+	db := database.Conn()
+	db = db.Table("namespace").Select("id,name,app_id,cluster_id,format,is_public,public_namespace_id,need_approval,lock_by,lock_time,version").
+		Where("id=? AND is_delete=0", namespaceId).Scan(namespace)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return namespace, errors.Wrap(db.Error, "db error")
+	}
+	if namespace.Id == "" {
+		return namespace, errors.New("the namespace not exists")
+	}
+	return namespace, nil
+}
+
+//the first user modifying the items locks the namespace until the items are released or discarded,
+//the version of the namespace is increased by every write of the items
+func (a *NamespaceModel) lock(tx *gorm.DB, namespace *namespaceInfo, userId string) (*gorm.DB, error) {
+	//take the lock in the write transaction, so that it is rolled back with the failed write
+	tx = database.Update(tx, "namespace", map[string]interface{}{
+		"lock_by":   userId,
+		"lock_time": gorm.Expr("IF(lock_time=0,?,lock_time)", time.Now().Unix()),
+		"version":   gorm.Expr("version+1"),
+	}, "id=? AND lock_by IN (?)", namespace.Id, []string{"", userId})
+	if tx.Error != nil {
+		return tx, nil
+	}
+	if tx.RowsAffected > 0 {
+		namespace.LockBy = userId
+		return tx, nil
+	}
+
+	var lock struct {
+		LockBy string
+	}
+	db := database.Conn()
+	db = db.Table("namespace").Select("lock_by").Where("id=?", namespace.Id).Scan(&lock)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return tx, errors.Wrap(db.Error, "db error")
+	}
+	userMdl := UserModel{}
+	owner, err := userMdl.Info(lock.LockBy)
+	if err != nil {
+		return tx, errors.New("the namespace is locked by another user, wait for the release or discard")
+	}
+	return tx, errors.Errorf("the namespace is locked by %s, wait for the release or discard", owner.Username)
+}
+
+//check the version after the namespace is locked in the transaction, it is increased by the lock only
+//if the items are not changed since the version is read
+func (a *NamespaceModel) checkVersion(tx *gorm.DB, namespaceId string, version int) error {
+	var namespace struct {
+		Version int
+	}
+	db := tx.New().Table("namespace").Select("version").Where("id=?", namespaceId).Scan(&namespace)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	if namespace.Version != version+1 {
+		return errors.New("configs changed since read, check them and release again")
+	}
+	return nil
+}
+
+//the unreleased changes locked by another user can only be released or discarded by the manager
+func (a *NamespaceModel) checkLockOwner(namespace *namespaceInfo, userId string) error {
+	if namespace.LockBy == "" || namespace.LockBy == userId {
+		return nil
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(userId, namespace, ActionManage); err != nil {
+		return errors.Wrapf(err, "the namespace is locked by %s", namespace.LockBy)
+	}
+	return nil
+}
+
+//the owner of the lock checked by checkLockOwner, the lock is taken by the owner in the transaction
+//so that it is not changed since checked
+func (a *NamespaceModel) lockOwner(namespace *namespaceInfo, userId string) string {
+	if namespace.LockBy != "" {
+		return namespace.LockBy
+	}
+	return userId
+}
+
+func (a *NamespaceModel) unlock(tx *gorm.DB, namespaceId string) *gorm.DB {
+	return database.Update(tx, "namespace", map[string]interface{}{
+		"lock_by":   "",
+		"lock_time": 0,
+		"version":   gorm.Expr("version+1"),
+	}, "id=?", namespaceId)
+}
+
+type SetNamespaceApprovalReq struct {
+	NamespaceId  string      `json:"namespace_id"`
+	NeedApproval bool        `json:"need_approval"`
+	UserId       string      `json:"-"`
+	Request      RequestInfo `json:"-"`
+}
+
+func (c *SetNamespaceApprovalReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//turn on or off the release approval of the namespace, only the owner of the app can do it
+func (a *NamespaceModel) SetApproval(req *SetNamespaceApprovalReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	namespace, err := a.getInfo(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionManage); err != nil {
+		return err
+	}
+
+	needApproval := 0
+	if req.NeedApproval {
+		needApproval = 1
+	}
+	if namespace.NeedApproval == needApproval {
+		return nil
+	}
+	if needApproval == 1 {
+		//promoting the gray release would bypass the approval
+		grayMdl := GrayModel{}
+		gray, err := grayMdl.getActive(req.NamespaceId)
+		if err != nil {
+			return err
+		}
+		if gray.Id != "" {
+			return errors.New("the namespace has an active gray release, promote or abandon it first")
+		}
+	} else {
+		//the pending requests will never be approved
+		reqMdl := ReleaseRequestModel{}
+		pending, err := reqMdl.getPending(req.NamespaceId)
+		if err != nil {
+			return err
+		}
+		if pending.Id != "" {
+			return errors.New("the namespace has a pending release request, approve, reject or cancel it first")
+		}
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "namespace", req.NamespaceId)
+	tx = database.Update(tx, "namespace", map[string]interface{}{
+		"need_approval": needApproval,
+		"update_by":     req.UserId,
+		"update_time":   time.Now().Unix(),
+	}, "id=?", req.NamespaceId)
+	tx = RecordTable(tx, "namespace", "set approval", req.UserId, req.Request, com.OpUpdate, before, req.NamespaceId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	return nil
+}
+
+type UpdateNamespaceReq struct {
+	NamespaceId string      `json:"namespace_id"`
+	Name        string      `json:"name"`
+	Comment     string      `json:"comment"`
+	UserId      string      `json:"-"`
+	Request     RequestInfo `json:"-"`
+}
+
+func (c *UpdateNamespaceReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Name, validation.Required, validation.Length(1, 64)),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//rename the namespace or change its comment, the public and associated namespaces can not be renamed
+//because they are matched by name, the clients must use the new name after renamed
+func (a *NamespaceModel) Update(req *UpdateNamespaceReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	namespace, err := a.getInfo(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionManage); err != nil {
+		return err
+	}
+
+	if req.Name != namespace.Name {
+		if namespace.IsPublic == 1 || namespace.PublicNamespaceId != "" {
+			return errors.New("the public or associated namespace can not be renamed")
+		}
+		var existNamespace struct {
+			Id string
+		}
+		db := database.Conn()
+		db = db.Table("namespace").Select("id").Where("cluster_id=? AND name=? AND is_delete=0", namespace.ClusterId, req.Name).Scan(&existNamespace)
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			log.Error(db.Error)
+			return errors.Wrap(db.Error, "db error")
+		}
+		if existNamespace.Id != "" {
+			return errors.New("the namespace name exists in the cluster")
+		}
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "namespace", req.NamespaceId)
+	tx = database.Update(tx, "namespace", map[string]interface{}{
+		"name":        req.Name,
+		"comment":     req.Comment,
+		"update_by":   req.UserId,
+		"update_time": time.Now().Unix(),
+	}, "id=?", req.NamespaceId)
+	tx = RecordTable(tx, "namespace", "", req.UserId, req.Request, com.OpUpdate, before, req.NamespaceId)
+	if tx.Error != nil {
+		tx.Rollback()
+		if database.IsDuplicate(tx.Error) {
+			return errors.New("the namespace name exists in the cluster")
+		}
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	return nil
+}
+
+type DeleteNamespaceReq struct {
+	NamespaceId string      `json:"namespace_id"`
+	UserId      string      `json:"-"`
+	Request     RequestInfo `json:"-"`
+}
+
+func (c *DeleteNamespaceReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//delete the namespace with its items, releases and the records of the instances,
+//the instances consuming it are notified that all the configs are deleted
+func (a *NamespaceModel) Delete(req *DeleteNamespaceReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	namespace, err := a.getInfo(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionManage); err != nil {
+		return err
+	}
+	if err := a.checkAssociated([]string{req.NamespaceId}, ""); err != nil {
+		return err
+	}
+
+	cfgMdl := ConfigModel{}
+	lastRelease, err := cfgMdl.getLastRelease(req.NamespaceId)
+	if err != nil {
+		return err
+	}
+
+	tx := database.Conn().Begin()
+	tx = a.deleteCascade(tx, []string{req.NamespaceId}, req.UserId, req.Request)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	if err := a.notifyDelete(namespace, lastRelease.Config); err != nil {
+		log.Warn(err)
+	}
+	log.Infof("user[%s] delete namespace[%s]", req.UserId, req.NamespaceId)
+	return nil
+}
+
+//the instances consuming the deleted namespace drop all the configs of it
+func (a *NamespaceModel) notifyDelete(namespace *namespaceInfo, config map[string]string) error {
+	cfgMdl := ConfigModel{}
+	oldConfig, err := cfgMdl.effectiveConfig(namespace.PublicNamespaceId, config)
+	if err != nil {
+		return err
+	}
+	if len(oldConfig) == 0 {
+		return nil
+	}
+	clusterIds, err := a.consumerClusterIds(namespace.AppId, namespace.ClusterId, namespace.Name)
+	if err != nil {
+		return err
+	}
+	change, err := namespaceChange(namespace, "", oldConfig, map[string]string{})
+	if err != nil {
+		return err
+	}
+	go cfgMdl.notifyChange(core.ConsumerOf(namespace.AppId, clusterIds...), change)
+	return nil
+}
+
+//the public namespaces can not be deleted while the namespaces out of the app are associated to them,
+//the associated namespaces of the app are deleted together
+func (a *NamespaceModel) checkAssociated(namespaceIds []string, appId string) error {
+	var associated struct {
+		Id string
+	}
+	db := database.Conn()
+	if appId == "" {
+		db = db.Table("namespace").Select("id").
+			Where("public_namespace_id IN (?) AND is_delete=0", namespaceIds).Scan(&associated)
+	} else {
+		db = db.Table("namespace").Select("id").
+			Where("public_namespace_id IN (?) AND app_id<>? AND is_delete=0", namespaceIds, appId).Scan(&associated)
+	}
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	if associated.Id != "" {
+		return errors.New("the public namespace is associated by other namespaces, delete them first")
+	}
+	return nil
+}
+
+//the tables of which the rows belong to a namespace
+var namespaceTables = []string{"item", "commit", "`release`", "release_history", "gray_release", "release_request", "release_schedule", "permission"}
+
+//soft delete the namespaces and everything belongs to them
+func (a *NamespaceModel) deleteCascade(tx *gorm.DB, namespaceIds []string, userId string, request RequestInfo) *gorm.DB {
+	if len(namespaceIds) == 0 {
+		return tx
+	}
+	now := time.Now().Unix()
+	tx, before := selectRows(tx, "namespace", namespaceIds...)
+	tx = database.Update(tx, "namespace", map[string]interface{}{
+		"is_delete":   1,
+		"delete_id":   gorm.Expr("id"),
+		"lock_by":     "",
+		"lock_time":   0,
+		"update_by":   userId,
+		"update_time": now,
+	}, "id IN (?) AND is_delete=0", namespaceIds)
+	for _, table := range namespaceTables {
+		tx = database.Update(tx, table, map[string]interface{}{
+			"is_delete":   1,
+			"update_by":   userId,
+			"update_time": now,
+		}, "namespace_id IN (?) AND is_delete=0", namespaceIds)
+	}
+	//the instances have no operator
+	tx = database.Update(tx, "instance_release", map[string]interface{}{
+		"is_delete":   1,
+		"update_time": now,
+	}, "namespace_id IN (?) AND is_delete=0", namespaceIds)
+	return RecordTable(tx, "namespace", "", userId, request, com.OpDelete, before, namespaceIds...)
+}
+
+type NamespaceListReq struct {
+	AppId     string `json:"app_id"`
+	ClusterId string `json:"cluster_id"` //all clusters if empty
+	Keyword   string `json:"keyword"`    //match the name or comment
+	Limit     int    `json:"limit"`
+	Offset    int    `json:"offset"`
+	UserId    string `json:"-"`
+}
+
+func (c *NamespaceListReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.AppId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.ClusterId, validation.Length(36, 36)),
+		validation.Field(&c.Keyword, validation.Length(1, 64)),
+		validation.Field(&c.Limit, validation.Max(100)),
+		validation.Field(&c.Offset, validation.Min(0)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type NamespaceListItem struct {
+	NamespaceItem
+	ClusterId    string `json:"cluster_id"`
+	ClusterName  string `json:"cluster_name"`
+	NeedApproval int    `json:"need_approval"`
+	LockBy       string `json:"lock_by"`
+	CreateBy     string `json:"create_by"`
+	CreateTime   int    `json:"create_time"`
+	UpdateBy     string `json:"update_by"`
+	UpdateTime   int    `json:"update_time"`
+}
+
+type NamespaceListResp struct {
+	Offset int                 `json:"offset"`
+	Total  int                 `json:"total"`
+	List   []NamespaceListItem `json:"list"`
+}
+
+func (a *NamespaceModel) List(req *NamespaceListReq) (*NamespaceListResp, error) {
+	resp := &NamespaceListResp{
+		List:   []NamespaceListItem{},
+		Offset: -1,
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, req.AppId, req.ClusterId, "", ActionView); err != nil {
+		return resp, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	where := "t1.app_id=? AND t1.is_delete=0"
+	params := []interface{}{req.AppId}
+	if req.ClusterId != "" {
+		where += " AND t1.cluster_id=?"
+		params = append(params, req.ClusterId)
+	}
+	if req.Keyword != "" {
+		keyword := "%" + database.EscapeLike(req.Keyword) + "%"
+		where += " AND (t1.name LIKE ? OR t1.comment LIKE ?)"
+		params = append(params, keyword, keyword)
+	}
+
+	db := database.Conn()
+	db = db.Table("namespace t1").
+		Select("t1.id,t1.name,t1.comment,t1.format,t1.is_public,t1.public_namespace_id,t1.cluster_id,t2.name cluster_name,"+
+			"t1.need_approval,t1.lock_by,t1.create_by,t1.create_time,t1.update_by,t1.update_time").
+		Joins("JOIN cluster t2 ON t1.cluster_id=t2.id AND t2.is_delete=0").
+		Where(where, params...).Order("t2.name,t1.name").Offset(req.Offset).Limit(req.Limit).Find(&resp.List)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	if len(resp.List) < req.Limit {
+		resp.Offset = -1
+	} else {
+		resp.Offset = req.Offset + len(resp.List)
+	}
+
+	db = database.Conn()
+	db = db.Table("namespace t1").Joins("JOIN cluster t2 ON t1.cluster_id=t2.id AND t2.is_delete=0").
+		Where(where, params...).Count(&resp.Total)
+	if db.Error != nil {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	return resp, nil
+}
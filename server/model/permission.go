@@ -0,0 +1,333 @@
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/core"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+type RoleType string
+
+const (
+	RoleOwner    RoleType = "owner"
+	RoleEditor   RoleType = "editor"
+	RoleReleaser RoleType = "releaser"
+	RoleViewer   RoleType = "viewer"
+)
+
+var roleTypeRule = validation.In(RoleOwner, RoleEditor, RoleReleaser, RoleViewer)
+
+type ActionType string
+
+const (
+	ActionView    ActionType = "view"
+	ActionEdit    ActionType = "edit"
+	ActionRelease ActionType = "release"
+	ActionManage  ActionType = "manage" //create clusters and namespaces, grant roles and manage access keys
+)
+
+//the actions allowed by the role, releasing in product env needs the releaser role explicitly
+func (r RoleType) Allow(action ActionType, env com.EnvType) bool {
+	switch action {
+	case ActionView:
+		return true
+	case ActionEdit:
+		return r == RoleOwner || r == RoleEditor
+	case ActionRelease:
+		if env == com.EnvProd {
+			return r == RoleReleaser
+		}
+		return r == RoleOwner || r == RoleReleaser
+	case ActionManage:
+		return r == RoleOwner
+	default:
+		return false
+	}
+}
+
+type PermissionModel struct {
+}
+
+//check the action of the user on the app, the cluster and namespace narrow the scope if not empty.
+//admin can do anything.
+func (p *PermissionModel) check(userId, appId, clusterId, namespaceId string, action ActionType) error {
+	var user struct {
+		Id      string
+		IsAdmin int
+	}
+	db := database.Conn()
+	db = db.Table("user").Select("id,is_admin").Where("id=? AND is_delete=0", userId).Scan(&user)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	if user.Id == "" {
+		return errors.New("the user not exists")
+	}
+	if user.IsAdmin == 1 {
+		return nil
+	}
+
+	var roles []struct {
+		Role RoleType
+	}
+	db = database.Conn()
+	db = db.Table("permission").Select("role").
+		Where("user_id=? AND app_id=? AND (cluster_id='' OR cluster_id=?) AND (namespace_id='' OR namespace_id=?) AND is_delete=0",
+			userId, appId, clusterId, namespaceId).Find(&roles)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+
+	env := core.GetServer().Env
+	for _, r := range roles {
+		if r.Role.Allow(action, env) {
+			return nil
+		}
+	}
+	log.Warnf("user[%s] has no permission to %s app[%s] cluster[%s] namespace[%s]", userId, action, appId, clusterId, namespaceId)
+	if action == ActionRelease && env == com.EnvProd {
+		return errors.New("releasing in product env needs the releaser role")
+	}
+	return errors.Errorf("no permission to %s", action)
+}
+
+//check the action of the user on the namespace
+func (p *PermissionModel) checkNamespace(userId string, namespace *namespaceInfo, action ActionType) error {
+	return p.check(userId, namespace.AppId, namespace.ClusterId, namespace.Id, action)
+}
+
+type GrantRoleReq struct {
+	AppId        string      `json:"app_id"`
+	ClusterId    string      `json:"cluster_id"`   //all clusters if empty
+	NamespaceId  string      `json:"namespace_id"` //all namespaces if empty
+	TargetUserId string      `json:"target_user_id"`
+	Role         RoleType    `json:"role"`
+	UserId       string      `json:"-"`
+	Request      RequestInfo `json:"-"`
+}
+
+func (c *GrantRoleReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.AppId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.ClusterId, validation.Length(36, 36)),
+		validation.Field(&c.NamespaceId, validation.Length(36, 36)),
+		validation.Field(&c.TargetUserId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Role, validation.Required, roleTypeRule),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type GrantRoleResp struct {
+	Id string `json:"id"`
+}
+
+//grant the role to the user, only the owner of the app can do it
+func (p *PermissionModel) Grant(req *GrantRoleReq) (*GrantRoleResp, error) {
+	resp := &GrantRoleResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	if err := p.check(req.UserId, req.AppId, "", "", ActionManage); err != nil {
+		return resp, err
+	}
+
+	//the cluster and namespace must belong to the app
+	if req.NamespaceId != "" {
+		nsMdl := NamespaceModel{}
+		namespace, err := nsMdl.getInfo(req.NamespaceId)
+		if err != nil {
+			return resp, err
+		}
+		if namespace.AppId != req.AppId {
+			return resp, errors.New("the namespace not belongs to the app")
+		}
+		if req.ClusterId == "" {
+			req.ClusterId = namespace.ClusterId
+		} else if req.ClusterId != namespace.ClusterId {
+			return resp, errors.New("the namespace not belongs to the cluster")
+		}
+	} else if req.ClusterId != "" {
+		var cluster struct {
+			Id string
+		}
+		db := database.Conn()
+		db = db.Table("cluster").Select("id").Where("id=? AND app_id=? AND is_delete=0", req.ClusterId, req.AppId).Scan(&cluster)
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			log.Error(db.Error)
+			return resp, errors.Wrap(db.Error, "db error")
+		}
+		if cluster.Id == "" {
+			return resp, errors.New("the cluster not belongs to the app")
+		}
+	}
+
+	userMdl := UserModel{}
+	if _, err := userMdl.Info(req.TargetUserId); err != nil {
+		return resp, err
+	}
+
+	var exist struct {
+		Id string
+	}
+	db := database.Conn()
+	db = db.Table("permission").Select("id").
+		Where("user_id=? AND app_id=? AND cluster_id=? AND namespace_id=? AND role=? AND is_delete=0",
+			req.TargetUserId, req.AppId, req.ClusterId, req.NamespaceId, req.Role).Scan(&exist)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if exist.Id != "" {
+		resp.Id = exist.Id
+		return resp, nil
+	}
+
+	tx := database.Conn().Begin()
+	id := uuid.NewV1().String()
+	tx = p.insert(tx, id, req.TargetUserId, req.AppId, req.ClusterId, req.NamespaceId, req.Role, req.UserId)
+	tx = RecordTable(tx, "permission", "", req.UserId, req.Request, com.OpCreate, nil, id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return resp, errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	resp.Id = id
+	return resp, nil
+}
+
+func (p *PermissionModel) insert(tx *gorm.DB, id, userId, appId, clusterId, namespaceId string, role RoleType, createBy string) *gorm.DB {
+	now := time.Now().Unix()
+	return database.Insert(tx, "permission", map[string]interface{}{
+		"id":           id,
+		"user_id":      userId,
+		"app_id":       appId,
+		"cluster_id":   clusterId,
+		"namespace_id": namespaceId,
+		"role":         role,
+		"create_by":    createBy,
+		"create_time":  now,
+		"update_by":    createBy,
+		"update_time":  now,
+	})
+}
+
+type RevokeRoleReq struct {
+	Id      string      `json:"id"`
+	UserId  string      `json:"-"`
+	Request RequestInfo `json:"-"`
+}
+
+func (c *RevokeRoleReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Id, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+func (p *PermissionModel) Revoke(req *RevokeRoleReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	var permission struct {
+		Id    string
+		AppId string
+	}
+	db := database.Conn()
+	db = db.Table("permission").Select("id,app_id").Where("id=? AND is_delete=0", req.Id).Scan(&permission)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	if permission.Id == "" {
+		return errors.New("the permission not exists")
+	}
+	if err := p.check(req.UserId, permission.AppId, "", "", ActionManage); err != nil {
+		return err
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "permission", req.Id)
+	tx = database.Update(tx, "permission", map[string]interface{}{
+		"is_delete":   1,
+		"update_by":   req.UserId,
+		"update_time": time.Now().Unix(),
+	}, "id=?", req.Id)
+	tx = RecordTable(tx, "permission", "", req.UserId, req.Request, com.OpDelete, before, req.Id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	return nil
+}
+
+type PermissionListReq struct {
+	AppId  string `json:"app_id"`
+	UserId string `json:"-"`
+}
+
+func (c *PermissionListReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.AppId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type PermissionItem struct {
+	Id          string   `json:"id"`
+	UserId      string   `json:"user_id"`
+	Username    string   `json:"username"`
+	ClusterId   string   `json:"cluster_id"`
+	NamespaceId string   `json:"namespace_id"`
+	Role        RoleType `json:"role"`
+	CreateBy    string   `json:"create_by"`
+	CreateTime  int      `json:"create_time"`
+}
+
+type PermissionListResp struct {
+	List []PermissionItem `json:"list"`
+}
+
+func (p *PermissionModel) List(req *PermissionListReq) (*PermissionListResp, error) {
+	resp := &PermissionListResp{
+		List: []PermissionItem{},
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	if err := p.check(req.UserId, req.AppId, "", "", ActionView); err != nil {
+		return resp, err
+	}
+
+	db := database.Conn()
+	db = db.Table("permission t1").Select("t1.id,t1.user_id,t2.username,t1.cluster_id,t1.namespace_id,t1.role,t1.create_by,t1.create_time").
+		Joins("JOIN user t2 ON t1.user_id=t2.id AND t2.is_delete=0").
+		Where("t1.app_id=? AND t1.is_delete=0", req.AppId).Order("t1.create_time").Find(&resp.List)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,260 @@
+package model
+
+import (
+	"fmt"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/core"
+	"github.com/hackbeex/configcenter/server/kms"
+	"github.com/hackbeex/configcenter/server/peer"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/pkg/errors"
+)
+
+//the released configs go through the envs in order
+var promoteEnvs = []com.EnvType{com.EnvDev, com.EnvTest, com.EnvProd}
+
+func nextEnv(env com.EnvType) (com.EnvType, error) {
+	for i, e := range promoteEnvs {
+		if e == env && i+1 < len(promoteEnvs) {
+			return promoteEnvs[i+1], nil
+		}
+	}
+	return "", errors.Errorf("can not promote from %s env", env)
+}
+
+type PromoteConfigReq struct {
+	NamespaceId string   `json:"namespace_id"`
+	ClusterName string   `json:"cluster_name"` //the cluster of the next env, the same cluster name by default
+	Keys        []string `json:"keys"`         //the keys to promote, required unless dry run
+	DryRun      bool     `json:"dry_run"`      //only return the changes of all keys
+	PeerToken   string   `json:"peer_token"`   //the login token of the user on the server of the next env
+	UserId      string   `json:"-"`
+}
+
+func (c *PromoteConfigReq) Validate() error {
+	err := validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.ClusterName, validation.Length(1, 64)),
+		validation.Field(&c.PeerToken, validation.Required),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+	if err != nil {
+		return err
+	}
+	if !c.DryRun && len(c.Keys) == 0 {
+		return errors.New("keys is required")
+	}
+	return nil
+}
+
+type PromoteConfigResp struct {
+	Env       com.EnvType  `json:"env"`
+	ReleaseId string       `json:"release_id"`
+	Create    int          `json:"create"`
+	Update    int          `json:"update"`
+	Delete    int          `json:"delete"`
+	Changes   []itemChange `json:"changes"`
+}
+
+//promote the current release of the namespace to the items of the same namespace in the next env,
+//the items are created or updated but not released there
+func (c *ConfigModel) Promote(req *PromoteConfigReq) (*PromoteConfigResp, error) {
+	resp := &PromoteConfigResp{
+		Changes: []itemChange{},
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	env, err := nextEnv(core.GetServer().Env)
+	if err != nil {
+		return resp, err
+	}
+	resp.Env = env
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
+
+	lastRelease, err := c.getLastRelease(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	if lastRelease.ReleaseId == "" {
+		return resp, errors.New("the namespace has not been released")
+	}
+	resp.ReleaseId = lastRelease.ReleaseId
+
+	//the secrets are sent encrypted by the peer token
+	configs := map[string]string{}
+	var secrets []string
+	for key, val := range lastRelease.Config {
+		if kms.IsSealed(val) {
+			plaintext, err := openValue(val)
+			if err != nil {
+				return resp, err
+			}
+			if val, err = peer.Seal(plaintext); err != nil {
+				log.Error(err)
+				return resp, err
+			}
+			secrets = append(secrets, key)
+		}
+		configs[key] = val
+	}
+
+	names, err := c.peerConfigReq(namespace, req.ClusterName, false)
+	if err != nil {
+		return resp, err
+	}
+	peerReq := &PeerPromoteReq{
+		PeerConfigReq: *names,
+		Format:        namespace.Format,
+		Configs:       configs,
+		Secrets:       secrets,
+		Keys:          req.Keys,
+		SourceEnv:     core.GetServer().Env,
+		ReleaseId:     lastRelease.ReleaseId,
+		DryRun:        req.DryRun,
+	}
+	peerResp := &PeerPromoteResp{}
+	if err := peer.Call(env, "/api/v1/peer/config/promote", req.PeerToken, peerReq, peerResp); err != nil {
+		return resp, err
+	}
+	for _, change := range peerResp.Changes {
+		switch change.OpType {
+		case com.OpCreate:
+			resp.Create++
+		case com.OpUpdate:
+			resp.Update++
+		case com.OpDelete:
+			resp.Delete++
+		}
+		resp.Changes = append(resp.Changes, change)
+	}
+	log.Infof("user[%s] promote namespace[%s] release[%s] to %s, dry run: %v, changes: %d",
+		req.UserId, req.NamespaceId, lastRelease.ReleaseId, env, req.DryRun, len(resp.Changes))
+	return resp, nil
+}
+
+type PeerPromoteReq struct {
+	PeerConfigReq
+	Format    NamespaceFormat   `json:"format"`
+	Configs   map[string]string `json:"configs"`
+	Secrets   []string          `json:"secrets"` //the keys of the secrets encrypted by the peer token
+	Keys      []string          `json:"keys"`    //the keys to apply, all if dry run without keys
+	SourceEnv com.EnvType       `json:"source_env"`
+	ReleaseId string            `json:"release_id"`
+	DryRun    bool              `json:"dry_run"`
+	Request   RequestInfo       `json:"-"`
+}
+
+func (c *PeerPromoteReq) Validate() error {
+	if err := c.PeerConfigReq.Validate(); err != nil {
+		return err
+	}
+	if c.UserId == "" {
+		return errors.New("need login in this env")
+	}
+	err := validation.ValidateStruct(c,
+		validation.Field(&c.SourceEnv, validation.Required),
+		validation.Field(&c.ReleaseId, validation.Required, validation.Length(36, 36)),
+	)
+	if err != nil {
+		return err
+	}
+	if !c.DryRun && len(c.Keys) == 0 {
+		return errors.New("keys is required")
+	}
+	return nil
+}
+
+type PeerPromoteResp struct {
+	Changes []itemChange `json:"changes"`
+}
+
+//apply the configs promoted from the previous env to the items, on behalf of the user logged in this env
+func (c *ConfigModel) PeerPromote(req *PeerPromoteReq) (*PeerPromoteResp, error) {
+	resp := &PeerPromoteResp{
+		Changes: []itemChange{},
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	if env, err := nextEnv(req.SourceEnv); err != nil || env != core.GetServer().Env {
+		return resp, errors.Errorf("can not promote from %s env to %s env", req.SourceEnv, core.GetServer().Env)
+	}
+
+	namespace, err := c.peerNamespace(&req.PeerConfigReq)
+	if err != nil {
+		return resp, err
+	}
+	action := ActionEdit
+	if req.DryRun {
+		action = ActionView
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, action); err != nil {
+		return resp, err
+	}
+	if namespace.Format != req.Format {
+		return resp, errors.Errorf("the namespace is %s in %s env but %s in %s env", req.Format, req.SourceEnv, namespace.Format, core.GetServer().Env)
+	}
+
+	secrets := map[string]bool{}
+	for _, key := range req.Secrets {
+		plaintext, err := peer.Open(req.Configs[key])
+		if err != nil {
+			log.Warn(err)
+			return resp, err
+		}
+		req.Configs[key] = plaintext
+		secrets[key] = true
+	}
+	keys := map[string]bool{}
+	for _, key := range req.Keys {
+		keys[key] = true
+	}
+
+	changes, err := c.planItems(namespace, req.Configs, true)
+	if err != nil {
+		return resp, err
+	}
+	var picked []itemChange
+	for _, change := range changes {
+		if len(keys) > 0 && !keys[change.Key] {
+			continue
+		}
+		if change.OpType != com.OpDelete && secrets[change.Key] {
+			change.IsSecret = true
+		}
+		picked = append(picked, change)
+	}
+	for _, change := range picked {
+		if change.IsSecret {
+			change.Value = SecretMask
+		}
+		resp.Changes = append(resp.Changes, change)
+	}
+	if req.DryRun {
+		return resp, nil
+	}
+
+	if err := c.applyItems(namespace, picked, fmt.Sprintf("promote from %s", req.SourceEnv), req.UserId, req.Request); err != nil {
+		return resp, err
+	}
+	log.Infof("user[%s] promote release[%s] from %s env to namespace[%s], changes: %d",
+		req.UserId, req.ReleaseId, req.SourceEnv, namespace.Id, len(picked))
+	return resp, nil
+}
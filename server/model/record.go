@@ -1,16 +1,40 @@
 package model
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/hackbeex/configcenter/server/database"
 	"github.com/hackbeex/configcenter/util/com"
 	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
 	"time"
 )
 
-func RecordTable(db *gorm.DB, table, comment, userId string, op com.OpType, ids ...string) *gorm.DB {
-	if len(ids) == 0 {
+//the columns not kept in the records, they are secret or can be found by the table id as they never change
+var recordOmitColumns = map[string]map[string]bool{
+	"user":       {"password": true},
+	"access_key": {"key_hash": true},
+	"release":    {"config": true},
+	"webhook":    {"secret": true},
+}
+
+//record the rows after the operation, the rows before are read by selectRows in the transaction before they are changed,
+//and nil if the rows are created
+func RecordTable(db *gorm.DB, table, comment, userId string, request RequestInfo, op com.OpType, before tableRows, ids ...string) *gorm.DB {
+	if len(ids) == 0 || db.Error != nil {
+		return db
+	}
+
+	db, after := selectRows(db, table, ids...)
+	if db.Error != nil {
 		return db
 	}
+	appIds, err := recordAppIds(db, table, after)
+	if err != nil {
+		db.AddError(err)
+		return db
+	}
+
 	now := time.Now().Unix()
 	var data []map[string]interface{}
 	for _, id := range ids {
@@ -19,6 +43,12 @@ func RecordTable(db *gorm.DB, table, comment, userId string, op com.OpType, ids
 			"table_id":    id,
 			"op_type":     op,
 			"comment":     comment,
+			"app_id":      appIds[id],
+			"client_ip":   request.ClientIp,
+			"user_agent":  request.UserAgent,
+			"request_id":  request.RequestId,
+			"before_data": before.data(id),
+			"after_data":  after.data(id),
 			"create_by":   userId,
 			"create_time": now,
 			"update_by":   userId,
@@ -27,3 +57,117 @@ func RecordTable(db *gorm.DB, table, comment, userId string, op com.OpType, ids
 	}
 	return database.InsertMany(db, "record", data)
 }
+
+//the rows of the table by id, without the omitted columns
+type tableRows map[string]map[string]interface{}
+
+//the json of the row, empty if the row not exists
+func (t tableRows) data(id string) string {
+	row, ok := t[id]
+	if !ok {
+		return ""
+	}
+	b, _ := json.Marshal(row)
+	return string(b)
+}
+
+//the current rows of the table in the transaction, read them before the change to record the rows before
+func selectRows(db *gorm.DB, table string, ids ...string) (*gorm.DB, tableRows) {
+	result := tableRows{}
+	if len(ids) == 0 || db.Error != nil {
+		return db, result
+	}
+	//a new search of the transaction, which may carry the conditions of the last query
+	rows, err := db.New().Raw(fmt.Sprintf("SELECT * FROM `%s` WHERE id IN (?) FOR UPDATE", table), ids).Rows()
+	if err != nil {
+		db.AddError(errors.Wrap(err, "db error"))
+		return db, result
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		db.AddError(errors.Wrap(err, "db error"))
+		return db, result
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			db.AddError(errors.Wrap(err, "db error"))
+			return db, result
+		}
+		row := recordRow(table, columns, values)
+		if id, ok := row["id"].(string); ok {
+			result[id] = row
+		}
+	}
+	if err := rows.Err(); err != nil {
+		db.AddError(errors.Wrap(err, "db error"))
+	}
+	return db, result
+}
+
+//the row to record from the scanned values, the omitted columns are dropped
+func recordRow(table string, columns []string, values []interface{}) map[string]interface{} {
+	omit := recordOmitColumns[table]
+	row := map[string]interface{}{}
+	for i, col := range columns {
+		if omit[col] {
+			continue
+		}
+		if b, ok := values[i].([]byte); ok {
+			row[col] = string(b)
+		} else {
+			row[col] = values[i]
+		}
+	}
+	return row
+}
+
+//the apps which the rows belong to, empty if the table is not of apps
+func recordAppIds(db *gorm.DB, table string, rows map[string]map[string]interface{}) (map[string]string, error) {
+	appIds := map[string]string{}
+	var namespaceIds []string
+	for id, row := range rows {
+		if table == "app" {
+			appIds[id] = id
+		} else if appId, ok := row["app_id"].(string); ok {
+			appIds[id] = appId
+		} else if namespaceId, ok := row["namespace_id"].(string); ok {
+			namespaceIds = append(namespaceIds, namespaceId)
+		}
+	}
+	if len(namespaceIds) == 0 {
+		return appIds, nil
+	}
+
+	var namespaces []struct {
+		Id    string
+		AppId string
+	}
+	db = db.New().Raw("SELECT id,app_id FROM namespace WHERE id IN (?)", namespaceIds).Scan(&namespaces)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+	nsApps := map[string]string{}
+	for _, ns := range namespaces {
+		nsApps[ns.Id] = ns.AppId
+	}
+	for id, row := range rows {
+		if namespaceId, ok := row["namespace_id"].(string); ok && appIds[id] == "" {
+			appIds[id] = nsApps[namespaceId]
+		}
+	}
+	return appIds, nil
+}
+
+//the info of the portal request, attached to the records written by it and empty for the jobs
+type RequestInfo struct {
+	RequestId string
+	ClientIp  string
+	UserAgent string
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRecordRow(t *testing.T) {
+	columns := []string{"id", "username", "password", "is_admin", "create_time"}
+	values := []interface{}{[]byte("u1"), []byte("admin"), []byte("$2a$10$hash"), int64(1), nil}
+
+	row := recordRow("user", columns, values)
+	if _, ok := row["password"]; ok {
+		t.Error("the omitted column should not be recorded")
+	}
+	if row["id"] != "u1" || row["username"] != "admin" || row["is_admin"] != int64(1) || row["create_time"] != nil {
+		t.Errorf("row: got %v", row)
+	}
+
+	//the column is omitted only for its table
+	row = recordRow("item", []string{"id", "password"}, []interface{}{[]byte("i1"), []byte("x")})
+	if row["password"] != "x" {
+		t.Errorf("row: got %v", row)
+	}
+}
+
+func TestTableRowsData(t *testing.T) {
+	rows := tableRows{
+		"i1": {"id": "i1", "value": "v1", "version": int64(2)},
+	}
+	data := rows.data("i1")
+	got := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["id"] != "i1" || got["value"] != "v1" || got["version"] != float64(2) {
+		t.Errorf("data: got %s", data)
+	}
+
+	//the created rows have no data before
+	if data := rows.data("i2"); data != "" {
+		t.Errorf("data of the row not exists: got %s", data)
+	}
+	var before tableRows
+	if data := before.data("i1"); data != "" {
+		t.Errorf("data of nil rows: got %s", data)
+	}
+}
+
+func TestMaskRecordRow(t *testing.T) {
+	if row := maskRecordRow(""); row != nil {
+		t.Errorf("empty data: got %v", row)
+	}
+	data, _ := json.Marshal(map[string]interface{}{"key": "password", "value": "enc:v1:local:a2V5:Y3Q=", "version": 1})
+	row := maskRecordRow(string(data))
+	if row["value"] != SecretMask || row["key"] != "password" || row["version"] != float64(1) {
+		t.Errorf("row: got %v", row)
+	}
+}
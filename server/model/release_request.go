@@ -0,0 +1,499 @@
+package model
+
+import (
+	"encoding/json"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"time"
+)
+
+type ReleaseRequestStatus string
+
+const (
+	RequestPending  ReleaseRequestStatus = "pending"
+	RequestApproved ReleaseRequestStatus = "approved"
+	RequestRejected ReleaseRequestStatus = "rejected"
+	RequestCanceled ReleaseRequestStatus = "canceled"
+)
+
+var releaseRequestStatusRule = validation.In(RequestPending, RequestApproved, RequestRejected, RequestCanceled)
+
+//ReleaseRequestModel manages the releases of the namespaces which need approval,
+//the release is published only after another user approves it
+type ReleaseRequestModel struct {
+}
+
+type releaseRequestInfo struct {
+	Id          string
+	NamespaceId string
+	Name        string
+	Comment     string
+	Config      []byte
+	Status      ReleaseRequestStatus
+	Version     int
+	CreateBy    string
+	CreateTime  int
+}
+
+func (r *ReleaseRequestModel) getInfo(requestId string) (*releaseRequestInfo, error) {
+	request := &releaseRequestInfo{}
+	db := database.Conn()
+	db = db.Table("release_request").Select("id,namespace_id,name,comment,config,status,version,create_by,create_time").
+		Where("id=? AND is_delete=0", requestId).Scan(request)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return request, errors.Wrap(db.Error, "db error")
+	}
+	if request.Id == "" {
+		return request, errors.New("the release request not exists")
+	}
+	return request, nil
+}
+
+func (r *ReleaseRequestModel) getPending(namespaceId string) (*releaseRequestInfo, error) {
+	request := &releaseRequestInfo{}
+	db := database.Conn()
+	db = db.Table("release_request").Select("id,namespace_id,name,comment,config,status,version,create_by,create_time").
+		Where("namespace_id=? AND status=? AND is_delete=0", namespaceId, RequestPending).Limit(1).Scan(request)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return request, errors.Wrap(db.Error, "db error")
+	}
+	return request, nil
+}
+
+//request to release the configs, only one pending request is allowed in a namespace
+func (r *ReleaseRequestModel) create(namespace *namespaceInfo, itemMap map[string]string, name, comment, userId string, request RequestInfo) (string, error) {
+	pending, err := r.getPending(namespace.Id)
+	if err != nil {
+		return "", err
+	}
+	if pending.Id != "" {
+		return "", errors.New("the namespace already has a pending release request")
+	}
+
+	config, _ := json.Marshal(itemMap)
+	now := time.Now().Unix()
+	id := uuid.NewV1().String()
+	nsMdl := NamespaceModel{}
+	tx := database.Conn().Begin()
+	tx, err = nsMdl.lock(tx, namespace, nsMdl.lockOwner(namespace, userId))
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	//check again after the namespace row is locked, the concurrent request waits for it until committed
+	var locked struct {
+		Version   int
+		PendingId string
+	}
+	db := tx.New().Raw("SELECT version,(SELECT id FROM release_request WHERE namespace_id=? AND status=? AND is_delete=0 LIMIT 1) pending_id"+
+		" FROM namespace WHERE id=?", namespace.Id, RequestPending, namespace.Id).Scan(&locked)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		tx.Rollback()
+		log.Error(db.Error)
+		return "", errors.Wrap(db.Error, "db error")
+	}
+	if locked.PendingId != "" {
+		tx.Rollback()
+		return "", errors.New("the namespace already has a pending release request")
+	}
+	//the version is increased by the lock only, otherwise the items are changed after read
+	if locked.Version != namespace.Version+1 {
+		tx.Rollback()
+		return "", errors.New("configs changed while requesting, request again")
+	}
+	tx = database.Insert(tx, "release_request", map[string]interface{}{
+		"id":           id,
+		"app_id":       namespace.AppId,
+		"cluster_id":   namespace.ClusterId,
+		"namespace_id": namespace.Id,
+		"name":         name,
+		"comment":      comment,
+		"config":       config,
+		"status":       RequestPending,
+		"version":      locked.Version,
+		"create_by":    userId,
+		"create_time":  now,
+		"update_by":    userId,
+		"update_time":  now,
+	})
+	tx = RecordTable(tx, "release_request", "", userId, request, com.OpCreate, nil, id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return "", errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	return id, nil
+}
+
+//change the status of the pending request, error if it is reviewed by others at the same time
+func (r *ReleaseRequestModel) finish(requestId string, status ReleaseRequestStatus, comment, userId string, request RequestInfo) error {
+	now := time.Now().Unix()
+	data := map[string]interface{}{
+		"status":      status,
+		"update_by":   userId,
+		"update_time": now,
+	}
+	if status != RequestCanceled {
+		data["review_by"] = userId
+		data["review_comment"] = comment
+		data["review_time"] = now
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "release_request", requestId)
+	tx = database.Update(tx, "release_request", data, "id=? AND status=?", requestId, RequestPending)
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("the release request is not pending")
+	}
+	tx = RecordTable(tx, "release_request", string(status), userId, request, com.OpUpdate, before, requestId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+	return nil
+}
+
+type ReviewReleaseRequestReq struct {
+	Id      string      `json:"id"`
+	Comment string      `json:"comment"`
+	UserId  string      `json:"-"`
+	Request RequestInfo `json:"-"`
+}
+
+func (c *ReviewReleaseRequestReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Id, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type ApproveReleaseRequestResp struct {
+	ReleaseId string `json:"release_id"`
+}
+
+//approve and publish the request, the approver must be neither the requester nor an editor of the released configs,
+//and the configs must not be changed since the request
+func (r *ReleaseRequestModel) Approve(req *ReviewReleaseRequestReq) (*ApproveReleaseRequestResp, error) {
+	resp := &ApproveReleaseRequestResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	request, err := r.getInfo(req.Id)
+	if err != nil {
+		return resp, err
+	}
+	if request.Status != RequestPending {
+		return resp, errors.Errorf("the release request is %s", request.Status)
+	}
+	if request.CreateBy == req.UserId {
+		return resp, errors.New("the release request can not be approved by the requester")
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(request.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionRelease); err != nil {
+		return resp, err
+	}
+
+	cfgMdl := ConfigModel{}
+	lastRelease, err := cfgMdl.getLastRelease(request.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+
+	//the released configs should be exactly what was requested
+	if namespace.Version != request.Version {
+		return resp, errors.New("configs changed after the release request, reject it and request again")
+	}
+
+	//the users edited the configs to release can not publish them
+	var editedItem struct {
+		Id string
+	}
+	db := database.Conn()
+	db = db.Table("item").Select("id").
+		Where("namespace_id=? AND update_by=? AND update_time >= ?", request.NamespaceId, req.UserId, lastRelease.UpdateTime).
+		Limit(1).Scan(&editedItem)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	var editedCommit struct {
+		Id string
+	}
+	db = database.Conn()
+	db = db.Table("commit").Select("id").
+		Where("namespace_id=? AND create_by=? AND create_time >= ? AND is_delete=0", request.NamespaceId, req.UserId, lastRelease.UpdateTime).
+		Limit(1).Scan(&editedCommit)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if editedItem.Id != "" || editedCommit.Id != "" {
+		return resp, errors.New("the release request can not be approved by the editor of the configs")
+	}
+
+	var itemMap map[string]string
+	if err := json.Unmarshal(request.Config, &itemMap); err != nil {
+		log.Error(err)
+		return resp, err
+	}
+
+	//claim the request first so that it is published only once
+	if err := r.finish(req.Id, RequestApproved, req.Comment, req.UserId, req.Request); err != nil {
+		return resp, err
+	}
+	//the version is checked again when published, the configs may be edited since checked above
+	resp.ReleaseId, err = cfgMdl.publish(namespace, request.Version, lastRelease, itemMap, request.Name, request.Comment, req.UserId, req.Request)
+
+	//the request goes back to pending if the publish fails
+	data := map[string]interface{}{
+		"release_id": resp.ReleaseId,
+	}
+	comment := "release"
+	if resp.ReleaseId == "" {
+		data = map[string]interface{}{
+			"status":         RequestPending,
+			"review_by":      "",
+			"review_comment": "",
+			"review_time":    0,
+		}
+		comment = "revert"
+	}
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "release_request", req.Id)
+	tx = database.Update(tx, "release_request", data, "id=?", req.Id)
+	tx = RecordTable(tx, "release_request", comment, req.UserId, req.Request, com.OpUpdate, before, req.Id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return resp, errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	return resp, err
+}
+
+//reject the request with the comment, the configs are kept unreleased
+func (r *ReleaseRequestModel) Reject(req *ReviewReleaseRequestReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	request, err := r.getInfo(req.Id)
+	if err != nil {
+		return err
+	}
+	if request.Status != RequestPending {
+		return errors.Errorf("the release request is %s", request.Status)
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(request.NamespaceId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionRelease); err != nil {
+		return err
+	}
+
+	return r.finish(req.Id, RequestRejected, req.Comment, req.UserId, req.Request)
+}
+
+type CancelReleaseRequestReq struct {
+	Id      string      `json:"id"`
+	UserId  string      `json:"-"`
+	Request RequestInfo `json:"-"`
+}
+
+func (c *CancelReleaseRequestReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Id, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//cancel the request by the requester
+func (r *ReleaseRequestModel) Cancel(req *CancelReleaseRequestReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	request, err := r.getInfo(req.Id)
+	if err != nil {
+		return err
+	}
+	if request.Status != RequestPending {
+		return errors.Errorf("the release request is %s", request.Status)
+	}
+	if request.CreateBy != req.UserId {
+		return errors.New("only the requester can cancel the release request")
+	}
+
+	return r.finish(req.Id, RequestCanceled, "", req.UserId, req.Request)
+}
+
+type ReleaseRequestListReq struct {
+	NamespaceId string               `json:"namespace_id"`
+	Status      ReleaseRequestStatus `json:"status"` //all status if empty
+	Limit       int                  `json:"limit"`
+	Offset      int                  `json:"offset"`
+	UserId      string               `json:"-"`
+}
+
+func (c *ReleaseRequestListReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Status, releaseRequestStatusRule),
+		validation.Field(&c.Limit, validation.Max(100)),
+		validation.Field(&c.Offset, validation.Min(0)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type ReleaseRequestItem struct {
+	Id            string                `json:"id"`
+	Name          string                `json:"name"`
+	Comment       string                `json:"comment"`
+	Status        ReleaseRequestStatus  `json:"status"`
+	ReleaseId     string                `json:"release_id"`
+	ReviewBy      string                `json:"review_by"`
+	ReviewComment string                `json:"review_comment"`
+	ReviewTime    int                   `json:"review_time"`
+	CreateBy      string                `json:"create_by"`
+	CreateTime    int                   `json:"create_time"`
+	Config        map[string]string     `json:"config"`
+	Change        map[string]ChangeItem `json:"change"` //the changes to the current release of the pending request
+}
+
+type ReleaseRequestListResp struct {
+	List   []ReleaseRequestItem `json:"list"`
+	Offset int                  `json:"offset"`
+	Total  int                  `json:"total"`
+}
+
+func (r *ReleaseRequestModel) List(req *ReleaseRequestListReq) (*ReleaseRequestListResp, error) {
+	resp := &ReleaseRequestListResp{
+		List:   []ReleaseRequestItem{},
+		Offset: -1,
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	var requests []struct {
+		Id            string
+		Name          string
+		Comment       string
+		Status        ReleaseRequestStatus
+		ReleaseId     string
+		ReviewBy      string
+		ReviewComment string
+		ReviewTime    int
+		CreateBy      string
+		CreateTime    int
+		Config        []byte
+	}
+	db := database.Conn()
+	db = db.Table("release_request").
+		Select("id,name,comment,status,release_id,review_by,review_comment,review_time,create_by,create_time,config").
+		Where("namespace_id=? AND is_delete=0", req.NamespaceId)
+	if req.Status != "" {
+		db = db.Where("status=?", req.Status)
+	}
+	db = db.Order("create_time DESC").Offset(req.Offset).Limit(req.Limit).Find(&requests)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	cfgMdl := ConfigModel{}
+	for _, request := range requests {
+		config := map[string]string{}
+		if err := json.Unmarshal(request.Config, &config); err != nil {
+			log.Error(err)
+			continue
+		}
+		change := map[string]ChangeItem{}
+		if request.Status == RequestPending {
+			lastRelease, err := cfgMdl.getLastRelease(req.NamespaceId)
+			if err != nil {
+				return resp, err
+			}
+			change = maskChange(releaseChange(lastRelease.Config, config))
+		}
+		resp.List = append(resp.List, ReleaseRequestItem{
+			Id:            request.Id,
+			Name:          request.Name,
+			Comment:       request.Comment,
+			Status:        request.Status,
+			ReleaseId:     request.ReleaseId,
+			ReviewBy:      request.ReviewBy,
+			ReviewComment: request.ReviewComment,
+			ReviewTime:    request.ReviewTime,
+			CreateBy:      request.CreateBy,
+			CreateTime:    request.CreateTime,
+			Config:        maskConfig(config),
+			Change:        change,
+		})
+	}
+
+	if len(requests) < req.Limit {
+		resp.Offset = -1
+	} else {
+		resp.Offset = req.Offset + len(requests)
+	}
+
+	db = database.Conn()
+	db = db.Table("release_request").Where("namespace_id=? AND is_delete=0", req.NamespaceId)
+	if req.Status != "" {
+		db = db.Where("status=?", req.Status)
+	}
+	db = db.Count(&resp.Total)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	return resp, nil
+}
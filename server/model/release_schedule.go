@@ -0,0 +1,597 @@
+package model
+
+import (
+	"encoding/json"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"reflect"
+	"time"
+)
+
+type ReleaseScheduleStatus string
+
+const (
+	SchedulePending     ReleaseScheduleStatus = "pending"
+	SchedulePublishing  ReleaseScheduleStatus = "publishing"
+	SchedulePublished   ReleaseScheduleStatus = "published"
+	ScheduleRollingBack ReleaseScheduleStatus = "rolling_back"
+	ScheduleRolledBack  ReleaseScheduleStatus = "rolled_back"
+	ScheduleCanceled    ReleaseScheduleStatus = "canceled"
+	ScheduleFailed      ReleaseScheduleStatus = "failed"
+)
+
+var releaseScheduleStatusRule = validation.In(SchedulePending, SchedulePublishing, SchedulePublished,
+	ScheduleRollingBack, ScheduleRolledBack, ScheduleCanceled, ScheduleFailed)
+
+//the seconds a server holds the schedule it is running, another server takes over after it expires
+const scheduleLease = 60
+
+//ReleaseScheduleModel publishes the items of the namespace at the publish time, and rolls back at the rollback time.
+//the schedules are stored in database and claimed by the servers through a lease, so that each one runs only once.
+type ReleaseScheduleModel struct {
+}
+
+type releaseScheduleInfo struct {
+	Id           string
+	NamespaceId  string
+	Name         string
+	Comment      string
+	Config       []byte
+	Version      int
+	PublishTime  int
+	RollbackTime int
+	Status       ReleaseScheduleStatus
+	ReleaseId    string
+	PreReleaseId string
+	CreateBy     string
+}
+
+func (r *ReleaseScheduleModel) getInfo(scheduleId string) (*releaseScheduleInfo, error) {
+	schedule := &releaseScheduleInfo{}
+	db := database.Conn()
+	db = db.Table("release_schedule").
+		Select("id,namespace_id,name,comment,config,version,publish_time,rollback_time,status,release_id,pre_release_id,create_by").
+		Where("id=? AND is_delete=0", scheduleId).Scan(schedule)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return schedule, errors.Wrap(db.Error, "db error")
+	}
+	if schedule.Id == "" {
+		return schedule, errors.New("the release schedule not exists")
+	}
+	return schedule, nil
+}
+
+type CreateReleaseScheduleReq struct {
+	NamespaceId  string      `json:"namespace_id"`
+	Name         string      `json:"name"`
+	Comment      string      `json:"comment"`
+	PublishTime  int         `json:"publish_time"`  //unix seconds
+	RollbackTime int         `json:"rollback_time"` //roll back to the previous release at the time, never if 0
+	UserId       string      `json:"-"`
+	Request      RequestInfo `json:"-"`
+}
+
+func (c *CreateReleaseScheduleReq) Validate() error {
+	err := validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Name, validation.Required, validation.Length(1, 64)),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.PublishTime, validation.Required),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+	if err != nil {
+		return err
+	}
+	if int64(c.PublishTime) <= time.Now().Unix() {
+		return errors.New("publish_time must be in the future")
+	}
+	if c.RollbackTime != 0 && c.RollbackTime <= c.PublishTime {
+		return errors.New("rollback_time must be after publish_time")
+	}
+	return nil
+}
+
+type CreateReleaseScheduleResp struct {
+	Id string `json:"id"`
+}
+
+//schedule to release the items of the namespace at the time, the items are released as they are now,
+//and the release fails if they are changed before the time
+func (r *ReleaseScheduleModel) Create(req *CreateReleaseScheduleReq) (*CreateReleaseScheduleResp, error) {
+	resp := &CreateReleaseScheduleResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionRelease); err != nil {
+		return resp, err
+	}
+	if namespace.NeedApproval == 1 {
+		return resp, errors.New("the namespace needs release approval, can not schedule the release")
+	}
+	cfgMdl := ConfigModel{}
+	itemMap, err := cfgMdl.getItemConfig(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	config, _ := json.Marshal(itemMap)
+
+	now := time.Now().Unix()
+	id := uuid.NewV1().String()
+	tx := database.Conn().Begin()
+	tx = database.Insert(tx, "release_schedule", map[string]interface{}{
+		"id":            id,
+		"app_id":        namespace.AppId,
+		"cluster_id":    namespace.ClusterId,
+		"namespace_id":  namespace.Id,
+		"name":          req.Name,
+		"comment":       req.Comment,
+		"config":        config,
+		"version":       namespace.Version,
+		"publish_time":  req.PublishTime,
+		"rollback_time": req.RollbackTime,
+		"status":        SchedulePending,
+		"create_by":     req.UserId,
+		"create_time":   now,
+		"update_by":     req.UserId,
+		"update_time":   now,
+	})
+	tx = RecordTable(tx, "release_schedule", "", req.UserId, req.Request, com.OpCreate, nil, id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return resp, errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	resp.Id = id
+	return resp, nil
+}
+
+type CancelReleaseScheduleReq struct {
+	Id      string      `json:"id"`
+	UserId  string      `json:"-"`
+	Request RequestInfo `json:"-"`
+}
+
+func (c *CancelReleaseScheduleReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Id, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//cancel the pending schedule, or the rollback of the published one
+func (r *ReleaseScheduleModel) Cancel(req *CancelReleaseScheduleReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	schedule, err := r.getInfo(req.Id)
+	if err != nil {
+		return err
+	}
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(schedule.NamespaceId)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionRelease); err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{
+		"update_by":   req.UserId,
+		"update_time": time.Now().Unix(),
+	}
+	var where string
+	switch {
+	case schedule.Status == SchedulePending:
+		data["status"] = ScheduleCanceled
+		where = "id=? AND status=?"
+	case schedule.Status == SchedulePublished && schedule.RollbackTime != 0:
+		data["rollback_time"] = 0
+		where = "id=? AND status=? AND rollback_time<>0"
+	default:
+		return errors.Errorf("the release schedule is %s", schedule.Status)
+	}
+
+	//the schedule may be claimed by the runner at the same time
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "release_schedule", req.Id)
+	tx = database.Update(tx, "release_schedule", data, where, req.Id, schedule.Status)
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("the release schedule is running")
+	}
+	tx = RecordTable(tx, "release_schedule", "cancel", req.UserId, req.Request, com.OpUpdate, before, req.Id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+	return nil
+}
+
+type ReleaseScheduleListReq struct {
+	NamespaceId string                `json:"namespace_id"`
+	Status      ReleaseScheduleStatus `json:"status"` //all status if empty
+	Limit       int                   `json:"limit"`
+	Offset      int                   `json:"offset"`
+	UserId      string                `json:"-"`
+}
+
+func (c *ReleaseScheduleListReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Status, releaseScheduleStatusRule),
+		validation.Field(&c.Limit, validation.Max(100)),
+		validation.Field(&c.Offset, validation.Min(0)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type ReleaseScheduleItem struct {
+	Id           string                `json:"id"`
+	Name         string                `json:"name"`
+	Comment      string                `json:"comment"`
+	PublishTime  int                   `json:"publish_time"`
+	RollbackTime int                   `json:"rollback_time"`
+	Status       ReleaseScheduleStatus `json:"status"`
+	ReleaseId    string                `json:"release_id"`
+	PreReleaseId string                `json:"pre_release_id"`
+	Message      string                `json:"message"` //the reason of the failure
+	CreateBy     string                `json:"create_by"`
+	CreateTime   int                   `json:"create_time"`
+	UpdateBy     string                `json:"update_by"`
+	UpdateTime   int                   `json:"update_time"`
+}
+
+type ReleaseScheduleListResp struct {
+	List   []ReleaseScheduleItem `json:"list"`
+	Offset int                   `json:"offset"`
+	Total  int                   `json:"total"`
+}
+
+func (r *ReleaseScheduleModel) List(req *ReleaseScheduleListReq) (*ReleaseScheduleListResp, error) {
+	resp := &ReleaseScheduleListResp{
+		List:   []ReleaseScheduleItem{},
+		Offset: -1,
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	db := database.Conn()
+	db = db.Table("release_schedule").
+		Select("id,name,comment,publish_time,rollback_time,status,release_id,pre_release_id,message,create_by,create_time,update_by,update_time").
+		Where("namespace_id=? AND is_delete=0", req.NamespaceId)
+	if req.Status != "" {
+		db = db.Where("status=?", req.Status)
+	}
+	db = db.Order("publish_time DESC").Offset(req.Offset).Limit(req.Limit).Find(&resp.List)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	if len(resp.List) < req.Limit {
+		resp.Offset = -1
+	} else {
+		resp.Offset = req.Offset + len(resp.List)
+	}
+
+	db = database.Conn()
+	db = db.Table("release_schedule").Where("namespace_id=? AND is_delete=0", req.NamespaceId)
+	if req.Status != "" {
+		db = db.Where("status=?", req.Status)
+	}
+	db = db.Count(&resp.Total)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	return resp, nil
+}
+
+//run the due schedules, called by every server periodically
+func (r *ReleaseScheduleModel) RunDue(serverId string) {
+	now := time.Now().Unix()
+	var schedules []struct {
+		Id string
+	}
+	db := database.Conn()
+	db = db.Table("release_schedule").Select("id").
+		Where("is_delete=0 AND ((status=? AND publish_time<=?) OR (status=? AND rollback_time<>0 AND rollback_time<=?) OR (status IN (?) AND lease_expire<?))",
+			SchedulePending, now, SchedulePublished, now, []ReleaseScheduleStatus{SchedulePublishing, ScheduleRollingBack}, now).
+		Order("publish_time").Find(&schedules)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return
+	}
+
+	for _, item := range schedules {
+		schedule, err := r.getInfo(item.Id)
+		if err != nil {
+			continue
+		}
+		switch schedule.Status {
+		case SchedulePending, SchedulePublishing:
+			if r.claim(schedule, SchedulePublishing, serverId) {
+				stop := r.keepLease(schedule, SchedulePublishing, serverId)
+				r.publish(schedule)
+				stop()
+			}
+		case SchedulePublished, ScheduleRollingBack:
+			if r.claim(schedule, ScheduleRollingBack, serverId) {
+				stop := r.keepLease(schedule, ScheduleRollingBack, serverId)
+				r.rollback(schedule)
+				stop()
+			}
+		}
+	}
+}
+
+//take the schedule by the lease, only one server succeeds
+func (r *ReleaseScheduleModel) claim(schedule *releaseScheduleInfo, status ReleaseScheduleStatus, serverId string) bool {
+	now := time.Now().Unix()
+	db := database.Conn()
+	db = db.Table("release_schedule").
+		Where("id=? AND status=? AND lease_expire<?", schedule.Id, schedule.Status, now)
+	if status == ScheduleRollingBack {
+		//the rollback may be cancelled after the schedule is read
+		db = db.Where("rollback_time<>0")
+	}
+	db = db.Updates(map[string]interface{}{
+			"status":       status,
+			"lease_owner":  serverId,
+			"lease_expire": now + scheduleLease,
+		})
+	if db.Error != nil {
+		log.Error(db.Error)
+		return false
+	}
+	if db.RowsAffected == 0 {
+		return false
+	}
+	log.Infof("server[%s] claims release schedule[%s] to %s", serverId, schedule.Id, status)
+	return true
+}
+
+//renew the lease until the returned func is called, so that the schedule is not taken over while running
+func (r *ReleaseScheduleModel) keepLease(schedule *releaseScheduleInfo, status ReleaseScheduleStatus, serverId string) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(scheduleLease / 3 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				db := database.Conn()
+				db = db.Table("release_schedule").
+					Where("id=? AND status=? AND lease_owner=?", schedule.Id, status, serverId).
+					Update("lease_expire", time.Now().Unix()+scheduleLease)
+				if db.Error != nil {
+					log.Error(db.Error)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+type scheduleHistory struct {
+	ReleaseId    string
+	PreReleaseId string
+}
+
+//the latest release history of the namespace by the user scheduled since the time, which is done by the schedule
+//if the server running it is gone before finishing
+func (r *ReleaseScheduleModel) getHistory(schedule *releaseScheduleInfo, opType ReleaseOpType, since int) (*scheduleHistory, error) {
+	history := &scheduleHistory{}
+	db := database.Conn()
+	db = db.Table("release_history").Select("release_id,pre_release_id").
+		Where("namespace_id=? AND op_type=? AND create_by=? AND create_time>=? AND is_delete=0", schedule.NamespaceId, opType, schedule.CreateBy, since).
+		Order("create_time DESC").Limit(1).Scan(history)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return history, errors.Wrap(db.Error, "db error")
+	}
+	return history, nil
+}
+
+//publish the configs scheduled, checked as the release by the user scheduled
+func (r *ReleaseScheduleModel) publish(schedule *releaseScheduleInfo) {
+	//taken over from another server, complete it by the release if published already
+	if schedule.Status == SchedulePublishing {
+		history, err := r.getHistory(schedule, ReleaseOpNormal, schedule.PublishTime)
+		if err != nil {
+			r.finish(schedule, ScheduleFailed, nil, err)
+			return
+		}
+		if history.ReleaseId != "" {
+			r.finish(schedule, SchedulePublished, map[string]interface{}{
+				"release_id":     history.ReleaseId,
+				"pre_release_id": history.PreReleaseId,
+			}, nil)
+			return
+		}
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(schedule.NamespaceId)
+	if err != nil {
+		r.finish(schedule, ScheduleFailed, nil, err)
+		return
+	}
+	if namespace.NeedApproval == 1 {
+		r.finish(schedule, ScheduleFailed, nil, errors.New("the namespace needs release approval"))
+		return
+	}
+	//the released configs should be exactly what was scheduled
+	if namespace.Version != schedule.Version {
+		r.finish(schedule, ScheduleFailed, nil, errors.New("configs changed after the release schedule, cancel it and schedule again"))
+		return
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(schedule.CreateBy, namespace, ActionRelease); err != nil {
+		r.finish(schedule, ScheduleFailed, nil, err)
+		return
+	}
+	if err := nsMdl.checkLockOwner(namespace, schedule.CreateBy); err != nil {
+		r.finish(schedule, ScheduleFailed, nil, err)
+		return
+	}
+	grayMdl := GrayModel{}
+	gray, err := grayMdl.getActive(schedule.NamespaceId)
+	if err != nil {
+		r.finish(schedule, ScheduleFailed, nil, err)
+		return
+	}
+	if gray.Id != "" {
+		r.finish(schedule, ScheduleFailed, nil, errors.New("the namespace has an active gray release"))
+		return
+	}
+
+	cfgMdl := ConfigModel{}
+	lastRelease, err := cfgMdl.getLastRelease(schedule.NamespaceId)
+	if err != nil {
+		r.finish(schedule, ScheduleFailed, nil, err)
+		return
+	}
+	var itemMap map[string]string
+	if err := json.Unmarshal(schedule.Config, &itemMap); err != nil {
+		log.Error(err)
+		r.finish(schedule, ScheduleFailed, nil, err)
+		return
+	}
+	if reflect.DeepEqual(itemMap, lastRelease.Config) {
+		r.finish(schedule, ScheduleFailed, nil, errors.New("no new configs to release"))
+		return
+	}
+	if err := cfgMdl.validateItems(schedule.NamespaceId, namespace.Format); err != nil {
+		r.finish(schedule, ScheduleFailed, nil, err)
+		return
+	}
+
+	releaseId, err := cfgMdl.publish(namespace, schedule.Version, lastRelease, itemMap, schedule.Name, schedule.Comment, schedule.CreateBy, RequestInfo{})
+	if err != nil {
+		r.finish(schedule, ScheduleFailed, nil, err)
+		return
+	}
+	r.finish(schedule, SchedulePublished, map[string]interface{}{
+		"release_id":     releaseId,
+		"pre_release_id": lastRelease.ReleaseId,
+	}, nil)
+}
+
+//go back to the release before the schedule if it is still the current one
+func (r *ReleaseScheduleModel) rollback(schedule *releaseScheduleInfo) {
+	//taken over from another server, complete it if rolled back already
+	if schedule.Status == ScheduleRollingBack {
+		history, err := r.getHistory(schedule, ReleaseOpRollback, schedule.RollbackTime)
+		if err != nil {
+			r.finish(schedule, ScheduleFailed, nil, err)
+			return
+		}
+		if history.ReleaseId == schedule.PreReleaseId && history.PreReleaseId == schedule.ReleaseId {
+			r.finish(schedule, ScheduleRolledBack, nil, nil)
+			return
+		}
+	}
+
+	cfgMdl := ConfigModel{}
+	lastRelease, err := cfgMdl.getLastRelease(schedule.NamespaceId)
+	if err != nil {
+		r.finish(schedule, ScheduleFailed, nil, err)
+		return
+	}
+	if lastRelease.ReleaseId != schedule.ReleaseId {
+		r.finish(schedule, ScheduleFailed, nil, errors.New("the namespace is released again after the schedule, skip the rollback"))
+		return
+	}
+	if schedule.PreReleaseId == "" {
+		r.finish(schedule, ScheduleFailed, nil, errors.New("the schedule published the first release, can not rollback"))
+		return
+	}
+
+	err = cfgMdl.Rollback(&RollbackConfigReq{
+		NamespaceId: schedule.NamespaceId,
+		ReleaseId:   schedule.PreReleaseId,
+		Publish:     true,
+		UserId:      schedule.CreateBy,
+	})
+	if err != nil {
+		r.finish(schedule, ScheduleFailed, nil, err)
+		return
+	}
+	r.finish(schedule, ScheduleRolledBack, nil, nil)
+}
+
+//release the lease with the result
+func (r *ReleaseScheduleModel) finish(schedule *releaseScheduleInfo, status ReleaseScheduleStatus, data map[string]interface{}, err error) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["status"] = status
+	data["lease_owner"] = ""
+	data["lease_expire"] = 0
+	data["update_time"] = time.Now().Unix()
+	if err != nil {
+		log.Warnf("release schedule[%s] fail: %s", schedule.Id, err)
+		message := err.Error()
+		if len(message) > 1024 {
+			message = message[:1024]
+		}
+		data["message"] = message
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "release_schedule", schedule.Id)
+	tx = database.Update(tx, "release_schedule", data, "id=?", schedule.Id)
+	tx = RecordTable(tx, "release_schedule", string(status), schedule.CreateBy, RequestInfo{}, com.OpUpdate, before, schedule.Id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+	} else {
+		tx.Commit()
+	}
+}
@@ -0,0 +1,234 @@
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+)
+
+type ReleaseStatus string
+
+const (
+	StatusReleased ReleaseStatus = "released" //the same value in the current release
+	StatusModified ReleaseStatus = "modified" //the current release has another value
+	StatusNew      ReleaseStatus = "new"      //not in the current release
+)
+
+type SearchConfigReq struct {
+	Key     string `json:"key"`     //`*` matches any characters, otherwise matched as a substring
+	Value   string `json:"value"`   //substring of the value, the secrets are never matched
+	Regex   bool   `json:"regex"`   //the value is a regular expression of go syntax, matched in the server
+	Comment string `json:"comment"` //substring of the comment
+	AppId   string `json:"app_id"`  //all the visible apps if empty
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+	UserId  string `json:"-"`
+}
+
+func (c *SearchConfigReq) Validate() error {
+	err := validation.ValidateStruct(c,
+		validation.Field(&c.Key, validation.Length(1, 128)),
+		validation.Field(&c.Value, validation.Length(1, 255)),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.AppId, validation.Length(36, 36)),
+		validation.Field(&c.Limit, validation.Max(100)),
+		validation.Field(&c.Offset, validation.Min(0)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+	if err != nil {
+		return err
+	}
+	if c.Key == "" && c.Value == "" && c.Comment == "" {
+		return errors.New("key, value or comment is required")
+	}
+	if c.Regex {
+		if len(c.Value) > maxSearchRegex {
+			return errors.Errorf("the value regex is longer than %d", maxSearchRegex)
+		}
+		if _, err := regexp.Compile(c.Value); err != nil {
+			return errors.Errorf("invalid value regex: %s", err)
+		}
+	}
+	return nil
+}
+
+const (
+	maxSearchRegex     = 128  //the max length of the value regex
+	maxRegexCandidates = 5000 //the max items read to match the value regex
+)
+
+type SearchConfigItem struct {
+	Id            string          `json:"id"`
+	AppId         string          `json:"app_id"`
+	AppName       string          `json:"app_name"`
+	ClusterId     string          `json:"cluster_id"`
+	ClusterName   string          `json:"cluster_name"`
+	NamespaceId   string          `json:"namespace_id"`
+	NamespaceName string          `json:"namespace_name"`
+	Format        NamespaceFormat `json:"format"`
+	Key           string          `json:"key"`
+	Value         string          `json:"value"`
+	Comment       string          `json:"comment"`
+	IsSecret      int             `json:"is_secret"`
+	Status        ReleaseStatus   `json:"status"`
+	UpdateBy      string          `json:"update_by"`
+	UpdateByName  string          `json:"update_by_name"`
+	UpdateTime    int             `json:"update_time"`
+}
+
+type SearchConfigResp struct {
+	Offset int                `json:"offset"`
+	Total  int                `json:"total"`
+	List   []SearchConfigItem `json:"list"`
+}
+
+//search the items of the namespaces which the user can view
+func (c *ConfigModel) Search(req *SearchConfigReq) (*SearchConfigResp, error) {
+	resp := &SearchConfigResp{
+		List:   []SearchConfigItem{},
+		Offset: -1,
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	userMdl := UserModel{}
+	user, err := userMdl.Info(req.UserId)
+	if err != nil {
+		return resp, err
+	}
+
+	where := "t1.is_delete=0"
+	var params []interface{}
+	if user.IsAdmin != 1 {
+		where += " AND EXISTS (SELECT 1 FROM permission p WHERE p.user_id=? AND p.app_id=t2.app_id" +
+			" AND (p.cluster_id='' OR p.cluster_id=t2.cluster_id) AND (p.namespace_id='' OR p.namespace_id=t2.id) AND p.is_delete=0)"
+		params = append(params, req.UserId)
+	}
+	if req.AppId != "" {
+		where += " AND t2.app_id=?"
+		params = append(params, req.AppId)
+	}
+	if req.Key != "" {
+		pattern := database.EscapeLike(req.Key)
+		if strings.Contains(pattern, "*") {
+			pattern = strings.Replace(pattern, "*", "%", -1)
+		} else {
+			pattern = "%" + pattern + "%"
+		}
+		where += " AND t1.`key` LIKE ?"
+		params = append(params, pattern)
+	}
+	var re *regexp.Regexp
+	if req.Value != "" {
+		where += " AND t1.is_secret=0"
+		if req.Regex {
+			//the regex is matched in the server, only the literal prefix of it can narrow the items in db
+			re = regexp.MustCompile(req.Value)
+			if prefix, _ := re.LiteralPrefix(); prefix != "" {
+				where += " AND t1.value LIKE ?"
+				params = append(params, "%"+database.EscapeLike(prefix)+"%")
+			}
+		} else {
+			where += " AND t1.value LIKE ?"
+			params = append(params, "%"+database.EscapeLike(req.Value)+"%")
+		}
+	}
+	if req.Comment != "" {
+		where += " AND t1.comment LIKE ?"
+		params = append(params, "%"+database.EscapeLike(req.Comment)+"%")
+	}
+	joins := "JOIN namespace t2 ON t1.namespace_id=t2.id AND t2.is_delete=0 " +
+		"JOIN cluster t3 ON t2.cluster_id=t3.id AND t3.is_delete=0 " +
+		"JOIN app t4 ON t2.app_id=t4.id AND t4.is_delete=0"
+
+	db := database.Conn()
+	db = db.Table("item t1").
+		Select("t1.id,t4.id app_id,t4.name app_name,t3.id cluster_id,t3.name cluster_name,t2.id namespace_id,t2.name namespace_name,"+
+			"t2.format,t1.`key`,t1.value,t1.comment,t1.is_secret,t1.update_by,t5.username update_by_name,t1.update_time").
+		Joins(joins).Joins("LEFT JOIN user t5 ON t1.update_by=t5.id").
+		Where(where, params...).Order("t4.name,t3.name,t2.name,t1.order_num")
+	if re == nil {
+		db = db.Offset(req.Offset).Limit(req.Limit)
+	} else {
+		db = db.Limit(maxRegexCandidates + 1)
+	}
+	db = db.Find(&resp.List)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if re != nil {
+		if len(resp.List) > maxRegexCandidates {
+			return resp, errors.New("too many configs to match the value regex, narrow them by app, key or comment")
+		}
+		resp.List, resp.Total = matchValues(resp.List, re, req.Offset, req.Limit)
+	}
+
+	releases := map[string]map[string]string{}
+	for i := range resp.List {
+		item := &resp.List[i]
+		config, ok := releases[item.NamespaceId]
+		if !ok {
+			lastRelease, err := c.getLastRelease(item.NamespaceId)
+			if err != nil {
+				return resp, err
+			}
+			config = lastRelease.Config
+			releases[item.NamespaceId] = config
+		}
+		if val, ok := config[item.Key]; !ok {
+			item.Status = StatusNew
+		} else if val != item.Value {
+			item.Status = StatusModified
+		} else {
+			item.Status = StatusReleased
+		}
+		item.Value = maskValue(item.Value)
+	}
+
+	if len(resp.List) < req.Limit {
+		resp.Offset = -1
+	} else {
+		resp.Offset = req.Offset + len(resp.List)
+	}
+
+	if re != nil {
+		return resp, nil
+	}
+	db = database.Conn()
+	db = db.Table("item t1").Joins(joins).Where(where, params...).Count(&resp.Total)
+	if db.Error != nil {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	return resp, nil
+}
+
+//filter the items whose value matches the regex, and return the page of them with the total count
+func matchValues(items []SearchConfigItem, re *regexp.Regexp, offset, limit int) ([]SearchConfigItem, int) {
+	matched := make([]SearchConfigItem, 0, len(items))
+	for _, item := range items {
+		if re.MatchString(item.Value) {
+			matched = append(matched, item)
+		}
+	}
+	total := len(matched)
+	if offset > total {
+		offset = total
+	}
+	if offset+limit < total {
+		matched = matched[:offset+limit]
+	}
+	return matched[offset:], total
+}
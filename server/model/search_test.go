@@ -0,0 +1,57 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSearchValidateRegex(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"go syntax", `^\d+\.\d+$`, true},
+		{"lookahead of mysql", `^(?=a)`, false},
+		{"too long", strings.Repeat("a", maxSearchRegex+1), false},
+		{"max length", strings.Repeat("a", maxSearchRegex), true},
+	}
+	for _, tt := range tests {
+		req := &SearchConfigReq{Value: tt.value, Regex: true, UserId: testUserId}
+		if err := req.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+	}
+}
+
+func TestMatchValues(t *testing.T) {
+	var items []SearchConfigItem
+	for _, v := range []string{"10.0.0.1", "localhost", "10.0.0.2", "10.0.0.3", "db.local"} {
+		items = append(items, SearchConfigItem{Value: v})
+	}
+	re := regexp.MustCompile(`^10\.0\.0\.\d$`)
+
+	tests := []struct {
+		offset, limit int
+		want          []string
+	}{
+		{0, 20, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+		{0, 2, []string{"10.0.0.1", "10.0.0.2"}},
+		{2, 2, []string{"10.0.0.3"}},
+		{5, 2, []string{}},
+	}
+	for _, tt := range tests {
+		list, total := matchValues(items, re, tt.offset, tt.limit)
+		if total != 3 {
+			t.Errorf("offset %d: got total %d, want 3", tt.offset, total)
+		}
+		var got []string
+		for _, item := range list {
+			got = append(got, item.Value)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("offset %d limit %d: got %v, want %v", tt.offset, tt.limit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"github.com/hackbeex/configcenter/server/core"
+	"github.com/hackbeex/configcenter/server/kms"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/pkg/errors"
+)
+
+//the value shown in portal instead of the secret, saving it back keeps the secret unchanged
+const SecretMask = "******"
+
+//the value to store, the masked value is replaced by the stored secret, but refused if the item is no longer a secret
+//so that clearing the flag always needs a new value
+func unmaskValue(value, stored string, wasSecret, isSecret bool) (string, error) {
+	if !wasSecret || value != SecretMask {
+		return value, nil
+	}
+	if !isSecret {
+		return "", errors.New("a new value is required when the item is no longer a secret")
+	}
+	return openValue(stored)
+}
+
+//encrypt the value of the secret item before it is stored
+func sealValue(value string, isSecret bool) (string, error) {
+	if !isSecret {
+		return value, nil
+	}
+	sealed, err := kms.Seal(value)
+	if err != nil {
+		log.Error(err)
+		return "", errors.Wrap(err, "encrypt secret")
+	}
+	return sealed, nil
+}
+
+//decrypt the value if it is a secret
+func openValue(value string) (string, error) {
+	if !kms.IsSealed(value) {
+		return value, nil
+	}
+	plaintext, err := kms.Open(value)
+	if err != nil {
+		log.Error(err)
+		return "", errors.Wrap(err, "decrypt secret")
+	}
+	return plaintext, nil
+}
+
+func maskValue(value string) string {
+	if kms.IsSealed(value) {
+		return SecretMask
+	}
+	return value
+}
+
+func maskConfig(config map[string]string) map[string]string {
+	masked := make(map[string]string, len(config))
+	for k, v := range config {
+		masked[k] = maskValue(v)
+	}
+	return masked
+}
+
+func maskChange(change map[string]ChangeItem) map[string]ChangeItem {
+	for k, item := range change {
+		item.NewValue = maskValue(item.NewValue)
+		item.OldValue = maskValue(item.OldValue)
+		change[k] = item
+	}
+	return change
+}
+
+//the configs sent to the client, secrets are decrypted if the access key allows, otherwise left out
+func revealConfig(config map[string]string, allowSecret bool) (map[string]string, error) {
+	revealed := make(map[string]string, len(config))
+	for k, v := range config {
+		if !kms.IsSealed(v) {
+			revealed[k] = v
+			continue
+		}
+		if !allowSecret {
+			continue
+		}
+		plaintext, err := openValue(v)
+		if err != nil {
+			return nil, err
+		}
+		revealed[k] = plaintext
+	}
+	return revealed, nil
+}
+
+//the changes sent to the client, like revealConfig the secrets are left out if the access key does not allow,
+//the key updated to a secret is deleted from such client as it may still hold the plaintext value
+func revealChange(change core.NamespaceChange, allowSecret bool) (core.NamespaceChange, error) {
+	revealed := change
+	revealed.Configs = map[com.OpType][]core.ChangeConfig{}
+	for op, configs := range change.Configs {
+		for _, cf := range configs {
+			if kms.IsSealed(cf.Value) {
+				if !allowSecret {
+					if op == com.OpUpdate {
+						revealed.Configs[com.OpDelete] = append(revealed.Configs[com.OpDelete], core.ChangeConfig{Key: cf.Key})
+					}
+					continue
+				}
+				plaintext, err := openValue(cf.Value)
+				if err != nil {
+					return change, err
+				}
+				cf.Value = plaintext
+			}
+			revealed.Configs[op] = append(revealed.Configs[op], cf)
+		}
+	}
+	return revealed, nil
+}
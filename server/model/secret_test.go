@@ -0,0 +1,55 @@
+package model
+
+import (
+	"github.com/hackbeex/configcenter/server/core"
+	"github.com/hackbeex/configcenter/util/com"
+	"reflect"
+	"testing"
+)
+
+func TestUnmaskValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wasSecret bool
+		isSecret  bool
+		want      string
+		ok        bool
+	}{
+		{"keep the secret", SecretMask, true, true, "old", true},
+		{"new secret", "new", true, true, "new", true},
+		{"clear the flag with the mask", SecretMask, true, false, "", false},
+		{"clear the flag with a new value", "new", true, false, "new", true},
+		{"the mask of a plain item", SecretMask, false, true, SecretMask, true},
+	}
+	for _, tt := range tests {
+		got, err := unmaskValue(tt.value, "old", tt.wasSecret, tt.isSecret)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("%s: got %q %v", tt.name, got, err)
+		}
+	}
+}
+
+func TestRevealChangeWithoutSecret(t *testing.T) {
+	sealed := "enc:v1:local:a2V5:Y3Q="
+	change := core.NamespaceChange{
+		Namespace: "application",
+		Configs: map[com.OpType][]core.ChangeConfig{
+			com.OpCreate: {{Key: "new_token", Value: sealed}, {Key: "host", Value: "localhost"}},
+			com.OpUpdate: {{Key: "token", Value: sealed}, {Key: "port", Value: "8080"}},
+			com.OpDelete: {{Key: "old_token", Value: sealed}},
+		},
+	}
+	got, err := revealChange(change, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[com.OpType][]core.ChangeConfig{
+		com.OpCreate: {{Key: "host", Value: "localhost"}},
+		com.OpUpdate: {{Key: "port", Value: "8080"}},
+		com.OpDelete: {{Key: "token"}},
+	}
+	if !reflect.DeepEqual(got.Configs, want) {
+		t.Errorf("got %+v, want %+v", got.Configs, want)
+	}
+}
@@ -0,0 +1,258 @@
+package model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+	"io"
+	"sort"
+	"strings"
+)
+
+type TransferFormat string
+
+const (
+	TransferProperties TransferFormat = "properties"
+	TransferYaml       TransferFormat = "yaml"
+	TransferJson       TransferFormat = "json"
+	TransferCsv        TransferFormat = "csv"
+)
+
+var transferFormatRule = validation.In(TransferProperties, TransferYaml, TransferJson, TransferCsv)
+
+type ImportConfigReq struct {
+	NamespaceId string         `json:"namespace_id"`
+	Format      TransferFormat `json:"format"`
+	Content     string         `json:"content"`
+	Overwrite   bool           `json:"overwrite"` //delete the keys not in content, merge by default
+	DryRun      bool           `json:"dry_run"`   //only return the changes
+	UserId      string         `json:"-"`
+	Request     RequestInfo    `json:"-"`
+}
+
+func (c *ImportConfigReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Format, validation.Required, transferFormatRule),
+		validation.Field(&c.Content, validation.Required),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type ImportConfigResp struct {
+	Create  int          `json:"create"`
+	Update  int          `json:"update"`
+	Delete  int          `json:"delete"`
+	Changes []itemChange `json:"changes"`
+}
+
+//import the configs to the items of the namespace, nested yaml and json are flattened to dotted keys
+func (c *ConfigModel) Import(req *ImportConfigReq) (*ImportConfigResp, error) {
+	resp := &ImportConfigResp{
+		Changes: []itemChange{},
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionEdit); err != nil {
+		return resp, err
+	}
+	if namespace.Format.IsDocument() {
+		return resp, errors.Errorf("%s namespace is saved as a whole content, can not import items", namespace.Format)
+	}
+
+	configs, err := parseTransfer(req.Format, req.Content)
+	if err != nil {
+		err = errors.Errorf("invalid %s content: %s", req.Format, err)
+		log.Warn(err)
+		return resp, err
+	}
+
+	changes, err := c.planItems(namespace, configs, req.Overwrite)
+	if err != nil {
+		return resp, err
+	}
+	for _, change := range changes {
+		switch change.OpType {
+		case com.OpCreate:
+			resp.Create++
+		case com.OpUpdate:
+			resp.Update++
+		case com.OpDelete:
+			resp.Delete++
+		}
+	}
+	for _, change := range changes {
+		if change.IsSecret {
+			change.Value = SecretMask
+		}
+		resp.Changes = append(resp.Changes, change)
+	}
+	if req.DryRun {
+		return resp, nil
+	}
+
+	if err := c.applyItems(namespace, changes, "import", req.UserId, req.Request); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+type ExportConfigReq struct {
+	NamespaceId string         `json:"namespace_id"`
+	Format      TransferFormat `json:"format"` //the format of the namespace by default
+	UserId      string         `json:"-"`
+}
+
+func (c *ExportConfigReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.NamespaceId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Format, transferFormatRule),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type ExportConfigResp struct {
+	Format  TransferFormat `json:"format"`
+	Content string         `json:"content"`
+}
+
+//export the current items include the unreleased, document in the same format is exported as it is
+func (c *ConfigModel) Export(req *ExportConfigReq) (*ExportConfigResp, error) {
+	resp := &ExportConfigResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	nsMdl := NamespaceModel{}
+	namespace, err := nsMdl.getInfo(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.checkNamespace(req.UserId, namespace, ActionView); err != nil {
+		return resp, err
+	}
+	if req.Format == "" {
+		req.Format = TransferFormat(namespace.Format)
+		if req.Format == TransferFormat(FormatToml) || req.Format == TransferFormat(FormatXml) {
+			req.Format = TransferProperties
+		}
+	}
+
+	itemMap, err := c.getItemConfig(req.NamespaceId)
+	if err != nil {
+		return resp, err
+	}
+	resp.Format = req.Format
+	if namespace.Format.IsDocument() && string(namespace.Format) == string(req.Format) {
+		resp.Content = itemMap[ContentKey]
+		return resp, nil
+	}
+
+	//the secrets are exported masked, importing them back keeps them unchanged
+	configs, _, err := exposeConfig(namespace.Format, maskConfig(itemMap))
+	if err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	resp.Content, err = renderTransfer(req.Format, configs)
+	if err != nil {
+		log.Error(err)
+		return resp, err
+	}
+	return resp, nil
+}
+
+func parseTransfer(format TransferFormat, content string) (map[string]string, error) {
+	switch format {
+	case TransferProperties:
+		return parseProperties(content)
+	case TransferYaml:
+		return flattenContent(FormatYaml, content)
+	case TransferJson:
+		return flattenContent(FormatJson, content)
+	case TransferCsv:
+		return parseCsv(content)
+	default:
+		return nil, errors.Errorf("unsupported format %s", format)
+	}
+}
+
+func renderTransfer(format TransferFormat, configs map[string]string) (string, error) {
+	switch format {
+	case TransferProperties:
+		return renderProperties(configs), nil
+	case TransferYaml:
+		data, err := yaml.Marshal(configs)
+		return string(data), err
+	case TransferJson:
+		data, err := json.MarshalIndent(configs, "", "  ")
+		return string(data), err
+	case TransferCsv:
+		return renderCsv(configs)
+	default:
+		return "", errors.Errorf("unsupported format %s", format)
+	}
+}
+
+//csv of key and value columns, the header line is optional
+func parseCsv(content string) (map[string]string, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	configs := map[string]string{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && len(record) >= 2 && strings.EqualFold(record[0], "key") && strings.EqualFold(record[1], "value") {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, errors.Errorf("line %d: need key and value columns", line)
+		}
+		key := strings.TrimSpace(record[0])
+		if key == "" {
+			return nil, errors.Errorf("line %d: empty key", line)
+		}
+		configs[key] = record[1]
+	}
+	return configs, nil
+}
+
+func renderCsv(configs map[string]string) (string, error) {
+	keys := make([]string, 0, len(configs))
+	for k := range configs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	_ = writer.Write([]string{"key", "value"})
+	for _, k := range keys {
+		_ = writer.Write([]string{k, configs[k]})
+	}
+	writer.Flush()
+	return buf.String(), writer.Error()
+}
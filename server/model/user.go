@@ -0,0 +1,405 @@
+package model
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/auth"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+)
+
+func init() {
+	auth.RegisterProvider(auth.LocalProvider, &localProvider{})
+}
+
+//verify the user by the password stored in database
+type localProvider struct {
+}
+
+func (p *localProvider) Authenticate(username, password string) (*auth.Identity, error) {
+	var user struct {
+		Username string
+		Password string
+		Name     string
+		Email    string
+	}
+	db := database.Conn()
+	db = db.Table("user").Select("username,password,name,email").
+		Where("username=? AND provider=? AND is_delete=0", username, auth.LocalProvider).Scan(&user)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return nil, errors.Wrap(db.Error, "db error")
+	}
+	if user.Username == "" || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return nil, errors.New("invalid username or password")
+	}
+	return &auth.Identity{
+		Username: user.Username,
+		Name:     user.Name,
+		Email:    user.Email,
+	}, nil
+}
+
+type UserModel struct {
+}
+
+type UserItem struct {
+	Id       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Provider string `json:"provider"`
+	IsAdmin  int    `json:"is_admin"`
+}
+
+type LoginReq struct {
+	Username string      `json:"username"`
+	Password string      `json:"password"`
+	Provider string      `json:"provider"` //local by default
+	Request  RequestInfo `json:"-"`
+}
+
+func (c *LoginReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Username, validation.Required, validation.Length(1, 64)),
+		validation.Field(&c.Password, validation.Required),
+		validation.Field(&c.Provider, validation.Length(1, 32)),
+	)
+}
+
+type LoginResp struct {
+	Token    string   `json:"token"`
+	ExpireAt int64    `json:"expire_at"`
+	User     UserItem `json:"user"`
+}
+
+//login by the provider, the user of other providers is created at the first login
+func (u *UserModel) Login(req *LoginReq) (*LoginResp, error) {
+	resp := &LoginResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	if req.Provider == "" {
+		req.Provider = auth.LocalProvider
+	}
+
+	provider, err := auth.GetProvider(req.Provider)
+	if err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	identity, err := provider.Authenticate(req.Username, req.Password)
+	if err != nil {
+		log.Warnf("user[%s] login by %s fail: %s", req.Username, req.Provider, err)
+		return resp, err
+	}
+
+	if err := u.getByName(identity.Username, req.Provider, &resp.User); err != nil {
+		return resp, err
+	}
+	if resp.User.Id == "" {
+		now := time.Now().Unix()
+		resp.User = UserItem{
+			Id:       uuid.NewV1().String(),
+			Username: identity.Username,
+			Name:     identity.Name,
+			Email:    identity.Email,
+			Provider: req.Provider,
+		}
+		tx := database.Conn().Begin()
+		tx = database.Insert(tx, "user", map[string]interface{}{
+			"id":          resp.User.Id,
+			"username":    identity.Username,
+			"password":    "",
+			"name":        identity.Name,
+			"email":       identity.Email,
+			"provider":    req.Provider,
+			"is_admin":    0,
+			"create_by":   resp.User.Id,
+			"create_time": now,
+			"update_by":   resp.User.Id,
+			"update_time": now,
+		})
+		tx = RecordTable(tx, "user", req.Provider, resp.User.Id, req.Request, com.OpCreate, nil, resp.User.Id)
+		if tx.Error != nil {
+			tx.Rollback()
+			if !database.IsDuplicate(tx.Error) {
+				log.Error(tx.Error)
+				return resp, errors.Wrap(tx.Error, "db error")
+			}
+			//the first login of the user at the same time has created it
+			resp.User = UserItem{}
+			if err := u.getByName(identity.Username, req.Provider, &resp.User); err != nil {
+				return resp, err
+			}
+			if resp.User.Id == "" {
+				return resp, errors.New("the user not exists")
+			}
+		} else {
+			tx.Commit()
+		}
+	}
+
+	resp.Token, resp.ExpireAt, err = auth.SignToken(resp.User.Id)
+	if err != nil {
+		log.Error(err)
+		return resp, err
+	}
+	return resp, nil
+}
+
+//the user of the name in the provider, empty if not exists
+func (u *UserModel) getByName(username, provider string, user *UserItem) error {
+	db := database.Conn()
+	db = db.Table("user").Select("id,username,name,email,provider,is_admin").
+		Where("username=? AND provider=? AND is_delete=0", username, provider).Scan(user)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	return nil
+}
+
+//the user of the token, error if the user not exists anymore
+func (u *UserModel) Info(userId string) (*UserItem, error) {
+	user := &UserItem{}
+	db := database.Conn()
+	db = db.Table("user").Select("id,username,name,email,provider,is_admin").
+		Where("id=? AND is_delete=0", userId).Scan(user)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return user, errors.Wrap(db.Error, "db error")
+	}
+	if user.Id == "" {
+		return user, errors.New("the user not exists")
+	}
+	return user, nil
+}
+
+type CreateUserReq struct {
+	Username string      `json:"username"`
+	Password string      `json:"password"`
+	Name     string      `json:"name"`
+	Email    string      `json:"email"`
+	IsAdmin  bool        `json:"is_admin"`
+	UserId   string      `json:"-"`
+	Request  RequestInfo `json:"-"`
+}
+
+func (c *CreateUserReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Username, validation.Required, validation.Length(1, 64)),
+		validation.Field(&c.Password, validation.Required, validation.Length(8, 72)),
+		validation.Field(&c.Name, validation.Length(1, 64)),
+		validation.Field(&c.Email, validation.Length(1, 128)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type CreateUserResp struct {
+	Id string `json:"id"`
+}
+
+//create the local user, only admin can do it
+func (u *UserModel) Create(req *CreateUserReq) (*CreateUserResp, error) {
+	resp := &CreateUserResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+
+	operator, err := u.Info(req.UserId)
+	if err != nil {
+		return resp, err
+	}
+	if operator.IsAdmin != 1 {
+		return resp, errors.New("only admin can create user")
+	}
+
+	var existUser struct {
+		Id string
+	}
+	db := database.Conn()
+	db = db.Table("user").Select("id").Where("username=? AND provider=? AND is_delete=0", req.Username, auth.LocalProvider).Scan(&existUser)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+	if existUser.Id != "" {
+		return resp, errors.New("the username exists")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error(err)
+		return resp, err
+	}
+	isAdmin := 0
+	if req.IsAdmin {
+		isAdmin = 1
+	}
+
+	now := time.Now().Unix()
+	id := uuid.NewV1().String()
+	tx := database.Conn().Begin()
+	tx = database.Insert(tx, "user", map[string]interface{}{
+		"id":          id,
+		"username":    req.Username,
+		"password":    string(hash),
+		"name":        req.Name,
+		"email":       req.Email,
+		"provider":    auth.LocalProvider,
+		"is_admin":    isAdmin,
+		"create_by":   req.UserId,
+		"create_time": now,
+		"update_by":   req.UserId,
+		"update_time": now,
+	})
+	tx = RecordTable(tx, "user", "", req.UserId, req.Request, com.OpCreate, nil, id)
+	if tx.Error != nil {
+		tx.Rollback()
+		if database.IsDuplicate(tx.Error) {
+			return resp, errors.New("the username exists")
+		}
+		log.Error(tx.Error)
+		return resp, errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	resp.Id = id
+	return resp, nil
+}
+
+type ChangePasswordReq struct {
+	OldPassword string      `json:"old_password"`
+	NewPassword string      `json:"new_password"`
+	UserId      string      `json:"-"`
+	Request     RequestInfo `json:"-"`
+}
+
+func (c *ChangePasswordReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.OldPassword, validation.Required),
+		validation.Field(&c.NewPassword, validation.Required, validation.Length(8, 72)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+func (u *UserModel) ChangePassword(req *ChangePasswordReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	var user struct {
+		Id       string
+		Password string
+		Provider string
+	}
+	db := database.Conn()
+	db = db.Table("user").Select("id,password,provider").Where("id=? AND is_delete=0", req.UserId).Scan(&user)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	if user.Id == "" {
+		return errors.New("the user not exists")
+	}
+	if user.Provider != auth.LocalProvider {
+		return errors.Errorf("the password is managed by %s", user.Provider)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)) != nil {
+		return errors.New("the old password is wrong")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "user", req.UserId)
+	tx = database.Update(tx, "user", map[string]interface{}{
+		"password":    string(hash),
+		"update_by":   req.UserId,
+		"update_time": time.Now().Unix(),
+	}, "id=?", req.UserId)
+	tx = RecordTable(tx, "user", "change password", req.UserId, req.Request, com.OpUpdate, before, req.UserId)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	return nil
+}
+
+type SetPasswordReq struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (c *SetPasswordReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Username, validation.Required),
+		validation.Field(&c.Password, validation.Required, validation.Length(8, 72)),
+	)
+}
+
+//SetPassword sets the password of a local user without the old one, it is only used by the server command,
+//e.g. to set the password of the seeded admin before the first login
+func (u *UserModel) SetPassword(req *SetPasswordReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+
+	var user struct {
+		Id string
+	}
+	db := database.Conn()
+	db = db.Table("user").Select("id").Where("username=? AND provider=? AND is_delete=0", req.Username, auth.LocalProvider).Scan(&user)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return errors.Wrap(db.Error, "db error")
+	}
+	if user.Id == "" {
+		return errors.New("the user not exists")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "user", user.Id)
+	tx = database.Update(tx, "user", map[string]interface{}{
+		"password":    string(hash),
+		"update_by":   user.Id,
+		"update_time": time.Now().Unix(),
+	}, "id=?", user.Id)
+	tx = RecordTable(tx, "user", "set password", user.Id, RequestInfo{}, com.OpUpdate, before, user.Id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/kms"
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v2"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type ValueType string
+
+const (
+	ValueString   ValueType = "string"
+	ValueInt      ValueType = "int"
+	ValueFloat    ValueType = "float"
+	ValueBool     ValueType = "bool"
+	ValueDuration ValueType = "duration"
+	ValueJson     ValueType = "json"
+	ValueYaml     ValueType = "yaml"
+)
+
+var valueTypeRule = validation.In(ValueString, ValueInt, ValueFloat, ValueBool, ValueDuration, ValueJson, ValueYaml)
+
+//constraints of the config value:
+//Pattern is a regexp which the value must match,
+//Min and Max limit the number of int and float, the seconds of duration and the length of string,
+//Schema is a JSON-Schema for json and yaml value.
+type ValueRule struct {
+	Pattern string          `json:"pattern,omitempty"`
+	Min     *float64        `json:"min,omitempty"`
+	Max     *float64        `json:"max,omitempty"`
+	Schema  json.RawMessage `json:"schema,omitempty"`
+}
+
+func (r ValueRule) IsEmpty() bool {
+	return r.Pattern == "" && r.Min == nil && r.Max == nil && len(r.Schema) == 0
+}
+
+func (r *ValueRule) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		return nil
+	default:
+		return errors.Errorf("unsupported value rule type: %T", src)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, r)
+}
+
+func (r ValueRule) Value() (driver.Value, error) {
+	if r.IsEmpty() {
+		return "", nil
+	}
+	data, err := json.Marshal(r)
+	return string(data), err
+}
+
+//check the rule itself before saving it
+func (r ValueRule) validate(valueType ValueType) error {
+	if r.Pattern != "" {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return errors.Wrap(err, "invalid pattern")
+		}
+	}
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return errors.New("min is greater than max")
+	}
+	if len(r.Schema) > 0 {
+		if valueType != ValueJson && valueType != ValueYaml {
+			return errors.Errorf("schema is not supported by %s value", valueType)
+		}
+		if _, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(r.Schema)); err != nil {
+			return errors.Wrap(err, "invalid schema")
+		}
+	}
+	return nil
+}
+
+func (r ValueRule) checkRange(num float64, unit string) error {
+	if r.Min != nil && num < *r.Min {
+		return errors.Errorf("%s %v is less than %v", unit, num, *r.Min)
+	}
+	if r.Max != nil && num > *r.Max {
+		return errors.Errorf("%s %v is greater than %v", unit, num, *r.Max)
+	}
+	return nil
+}
+
+func (r ValueRule) checkSchema(doc interface{}) error {
+	if len(r.Schema) == 0 {
+		return nil
+	}
+	res, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(r.Schema), gojsonschema.NewGoLoader(doc))
+	if err != nil {
+		return err
+	}
+	if !res.Valid() {
+		msgs := make([]string, 0, len(res.Errors()))
+		for _, e := range res.Errors() {
+			msgs = append(msgs, e.String())
+		}
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+//check the config value by its type and rule
+func validateValue(key, value string, valueType ValueType, rule ValueRule) error {
+	if kms.IsSealed(value) {
+		return errors.Errorf("config[%s] value looks like an encrypted secret", key)
+	}
+	err := checkValue(value, valueType, rule)
+	if err != nil {
+		return errors.Errorf("config[%s] is not a valid %s value: %s", key, valueType, err)
+	}
+	return nil
+}
+
+func checkValue(value string, valueType ValueType, rule ValueRule) error {
+	if rule.Pattern != "" {
+		matched, err := regexp.MatchString(rule.Pattern, value)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return errors.Errorf("not match pattern %s", rule.Pattern)
+		}
+	}
+
+	switch valueType {
+	case ValueString, "":
+		return rule.checkRange(float64(utf8.RuneCountInString(value)), "length")
+	case ValueInt:
+		num, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return err
+		}
+		return rule.checkRange(float64(num), "number")
+	case ValueFloat:
+		num, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return err
+		}
+		return rule.checkRange(num, "number")
+	case ValueBool:
+		_, err := strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	case ValueDuration:
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+		return rule.checkRange(d.Seconds(), "seconds")
+	case ValueJson:
+		var doc interface{}
+		if err := json.Unmarshal([]byte(value), &doc); err != nil {
+			return err
+		}
+		return rule.checkSchema(doc)
+	case ValueYaml:
+		var doc interface{}
+		if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+			return err
+		}
+		return rule.checkSchema(yamlToJson(doc))
+	default:
+		return errors.Errorf("unsupported value type")
+	}
+}
+
+//yaml decodes maps as map[interface{}]interface{} which json schema can not use
+func yamlToJson(doc interface{}) interface{} {
+	switch v := doc.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprintf("%v", k)] = yamlToJson(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = yamlToJson(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
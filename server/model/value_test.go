@@ -0,0 +1,132 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestCheckValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		valueType ValueType
+		rule      ValueRule
+		ok        bool
+	}{
+		{"string", "abc", ValueString, ValueRule{}, true},
+		{"string untyped", "abc", "", ValueRule{}, true},
+		{"string min length", "ab", ValueString, ValueRule{Min: floatPtr(2)}, true},
+		{"string under min length", "a", ValueString, ValueRule{Min: floatPtr(2)}, false},
+		{"string max length by runes", "中文字", ValueString, ValueRule{Max: floatPtr(3)}, true},
+		{"string over max length", "abcd", ValueString, ValueRule{Max: floatPtr(3)}, false},
+
+		{"int", "42", ValueInt, ValueRule{}, true},
+		{"int with spaces", " -7 ", ValueInt, ValueRule{}, true},
+		{"int of float", "4.2", ValueInt, ValueRule{}, false},
+		{"int of text", "x", ValueInt, ValueRule{}, false},
+		{"int at min", "1", ValueInt, ValueRule{Min: floatPtr(1), Max: floatPtr(10)}, true},
+		{"int at max", "10", ValueInt, ValueRule{Min: floatPtr(1), Max: floatPtr(10)}, true},
+		{"int under min", "0", ValueInt, ValueRule{Min: floatPtr(1), Max: floatPtr(10)}, false},
+		{"int over max", "11", ValueInt, ValueRule{Min: floatPtr(1), Max: floatPtr(10)}, false},
+
+		{"float", "0.5", ValueFloat, ValueRule{}, true},
+		{"float of int", "3", ValueFloat, ValueRule{}, true},
+		{"float of text", "half", ValueFloat, ValueRule{}, false},
+		{"float at max", "1.0", ValueFloat, ValueRule{Max: floatPtr(1)}, true},
+		{"float over max", "1.01", ValueFloat, ValueRule{Max: floatPtr(1)}, false},
+
+		{"bool true", "true", ValueBool, ValueRule{}, true},
+		{"bool 0", "0", ValueBool, ValueRule{}, true},
+		{"bool of text", "yes", ValueBool, ValueRule{}, false},
+
+		{"duration", "1m30s", ValueDuration, ValueRule{}, true},
+		{"duration without unit", "30", ValueDuration, ValueRule{}, false},
+		{"duration in range", "30s", ValueDuration, ValueRule{Min: floatPtr(30), Max: floatPtr(60)}, true},
+		{"duration over max", "61s", ValueDuration, ValueRule{Min: floatPtr(30), Max: floatPtr(60)}, false},
+
+		{"json object", `{"a":1}`, ValueJson, ValueRule{}, true},
+		{"json array", `[1,2]`, ValueJson, ValueRule{}, true},
+		{"json invalid", `{"a":}`, ValueJson, ValueRule{}, false},
+		{"json schema", `{"port":80}`, ValueJson, ValueRule{Schema: json.RawMessage(`{"type":"object","required":["port"]}`)}, true},
+		{"json schema missing", `{"host":"a"}`, ValueJson, ValueRule{Schema: json.RawMessage(`{"type":"object","required":["port"]}`)}, false},
+
+		{"yaml", "a:\n  b: 1\n", ValueYaml, ValueRule{}, true},
+		{"yaml invalid", "a: [1", ValueYaml, ValueRule{}, false},
+		{"yaml schema", "port: 80\n", ValueYaml, ValueRule{Schema: json.RawMessage(`{"type":"object","properties":{"port":{"type":"integer"}}}`)}, true},
+		{"yaml schema wrong type", "port: x\n", ValueYaml, ValueRule{Schema: json.RawMessage(`{"type":"object","properties":{"port":{"type":"integer"}}}`)}, false},
+
+		{"pattern", "v1.2", ValueString, ValueRule{Pattern: `^v\d+\.\d+$`}, true},
+		{"pattern not match", "1.2", ValueString, ValueRule{Pattern: `^v\d+\.\d+$`}, false},
+		{"enum by pattern", "debug", ValueString, ValueRule{Pattern: `^(debug|info|warn)$`}, true},
+		{"enum by pattern not in", "trace", ValueString, ValueRule{Pattern: `^(debug|info|warn)$`}, false},
+		{"pattern before type", "08", ValueInt, ValueRule{Pattern: `^[1-9]`}, false},
+
+		{"unsupported type", "x", ValueType("date"), ValueRule{}, false},
+	}
+	for _, tt := range tests {
+		err := checkValue(tt.value, tt.valueType, tt.rule)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: checkValue(%q, %s) = %v", tt.name, tt.value, tt.valueType, err)
+		}
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	tests := []struct {
+		name      string
+		valueType ValueType
+		rule      ValueRule
+		ok        bool
+	}{
+		{"empty", ValueString, ValueRule{}, true},
+		{"pattern", ValueString, ValueRule{Pattern: `^[a-z]+$`}, true},
+		{"bad pattern", ValueString, ValueRule{Pattern: `([a-z]`}, false},
+		{"equal min max", ValueInt, ValueRule{Min: floatPtr(1), Max: floatPtr(1)}, true},
+		{"min greater than max", ValueInt, ValueRule{Min: floatPtr(2), Max: floatPtr(1)}, false},
+		{"schema of json", ValueJson, ValueRule{Schema: json.RawMessage(`{"type":"object"}`)}, true},
+		{"schema of yaml", ValueYaml, ValueRule{Schema: json.RawMessage(`{"type":"object"}`)}, true},
+		{"schema of string", ValueString, ValueRule{Schema: json.RawMessage(`{"type":"object"}`)}, false},
+		{"bad schema", ValueJson, ValueRule{Schema: json.RawMessage(`{"type":1}`)}, false},
+		{"schema not json", ValueJson, ValueRule{Schema: json.RawMessage(`{`)}, false},
+	}
+	for _, tt := range tests {
+		err := tt.rule.validate(tt.valueType)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: validate = %v", tt.name, err)
+		}
+	}
+}
+
+func TestValueRuleScan(t *testing.T) {
+	var rule ValueRule
+	if err := rule.Scan([]byte(`{"pattern":"^a","min":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if rule.Pattern != "^a" || rule.Min == nil || *rule.Min != 1 || rule.Max != nil {
+		t.Errorf("scan: %+v", rule)
+	}
+
+	var empty ValueRule
+	if err := empty.Scan(""); err != nil || !empty.IsEmpty() {
+		t.Errorf("scan empty: %+v %v", empty, err)
+	}
+	if v, err := empty.Value(); err != nil || v != "" {
+		t.Errorf("value of empty rule: %v %v", v, err)
+	}
+	if err := empty.Scan(1); err == nil {
+		t.Error("scan of int should fail")
+	}
+}
+
+func TestValidateSealedValue(t *testing.T) {
+	if err := validateValue("password", "enc:v1:local:a2V5:Y3Q=", ValueString, ValueRule{}); err == nil {
+		t.Error("the sealed value should be refused")
+	}
+	if err := validateValue("port", "80", ValueInt, ValueRule{}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,869 @@
+package model
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/hackbeex/configcenter/server/core"
+	"github.com/hackbeex/configcenter/server/database"
+	"github.com/hackbeex/configcenter/server/kms"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+type WebhookEvent string
+
+const (
+	EventItemCreate  WebhookEvent = "item.create"
+	EventItemUpdate  WebhookEvent = "item.update"
+	EventItemDelete  WebhookEvent = "item.delete"
+	EventRelease     WebhookEvent = "release"
+	EventRollback    WebhookEvent = "rollback"
+	EventSync        WebhookEvent = "sync"
+	EventGrayRelease WebhookEvent = "gray.release"
+	EventGrayPromote WebhookEvent = "gray.promote"
+	EventGrayAbandon WebhookEvent = "gray.abandon"
+	EventPing        WebhookEvent = "ping" //only sent by the test, can not be subscribed
+)
+
+var webhookEventRule = validation.In(EventItemCreate, EventItemUpdate, EventItemDelete, EventRelease, EventRollback,
+	EventSync, EventGrayRelease, EventGrayPromote, EventGrayAbandon)
+
+//the event of the items changed by the operation
+var itemEvents = map[com.OpType]WebhookEvent{
+	com.OpCreate: EventItemCreate,
+	com.OpUpdate: EventItemUpdate,
+	com.OpDelete: EventItemDelete,
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "pending"
+	DeliverySuccess DeliveryStatus = "success"
+	DeliveryFailed  DeliveryStatus = "failed"
+)
+
+//the headers of the delivery request, the signature is `sha256=` and the hex hmac of the body by the secret
+const (
+	WebhookEventHeader     = "X-CC-Event"
+	WebhookDeliveryHeader  = "X-CC-Delivery"
+	WebhookSignatureHeader = "X-CC-Signature"
+)
+
+const (
+	webhookTimeout = 10 * time.Second
+	webhookLease   = 60 //the seconds a server holds the delivery it is sending
+)
+
+//the seconds to wait before each retry, the delivery fails after all of them
+var webhookRetryDelays = []int{10, 30, 60, 300, 1800}
+
+//the webhooks can only be sent to the public addresses, which are checked after the host is resolved when dialing,
+//and the redirects are not followed as they may go to anywhere
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: checkWebhookDial,
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+//the addresses of the server itself and the private networks, and the nat64 and 6to4 prefixes
+//which embed an ipv4 address and may reach them through the gateway
+var webhookDeniedNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"64:ff9b::/96",
+		"64:ff9b:1::/48",
+		"2002::/16",
+		"fc00::/7",
+		"fe80::/10",
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+//refuse the loopback, private, link-local and unspecified addresses
+func checkWebhookIp(ip net.IP) error {
+	if ip == nil {
+		return errors.New("invalid address")
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return errors.Errorf("the address %s is not allowed", ip)
+	}
+	for _, n := range webhookDeniedNets {
+		if n.Contains(ip) {
+			return errors.Errorf("the address %s is not allowed", ip)
+		}
+	}
+	return nil
+}
+
+//check the resolved address right before connecting, so the host can not be resolved to another address after checked
+func checkWebhookDial(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	return checkWebhookIp(net.ParseIP(host))
+}
+
+//WebhookModel sends the events of the app to the urls subscribing them.
+//the deliveries are stored in database first, then sent and retried by the servers claiming them through a lease.
+type WebhookModel struct {
+}
+
+type webhookInfo struct {
+	Id        string
+	AppId     string
+	Url       string
+	Secret    string
+	Events    []byte
+	IsEnabled int
+}
+
+func (w *WebhookModel) getInfo(webhookId string) (*webhookInfo, error) {
+	webhook := &webhookInfo{}
+	db := database.Conn()
+	db = db.Table("webhook").Select("id,app_id,url,secret,events,is_enabled").
+		Where("id=? AND is_delete=0", webhookId).Scan(webhook)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return webhook, errors.Wrap(db.Error, "db error")
+	}
+	if webhook.Id == "" {
+		return webhook, errors.New("the webhook not exists")
+	}
+	return webhook, nil
+}
+
+//the url must be absolute http or https, the host of ip is checked here and the others are checked when sent
+func validateWebhookUrl(value interface{}) error {
+	s, _ := value.(string)
+	if s == "" {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("must be a http or https url")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return checkWebhookIp(ip)
+	}
+	return nil
+}
+
+type CreateWebhookReq struct {
+	AppId   string         `json:"app_id"`
+	Url     string         `json:"url"`
+	Events  []WebhookEvent `json:"events"`
+	Secret  string         `json:"secret"` //generated if empty
+	Comment string         `json:"comment"`
+	UserId  string         `json:"-"`
+	Request RequestInfo    `json:"-"`
+}
+
+func (c *CreateWebhookReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.AppId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Url, validation.Required, validation.Length(1, 512), validation.By(validateWebhookUrl)),
+		validation.Field(&c.Events, validation.Required, validation.Each(webhookEventRule)),
+		validation.Field(&c.Secret, validation.Length(16, 128)),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type CreateWebhookResp struct {
+	Id     string `json:"id"`
+	Secret string `json:"secret"` //only returned here, keep it to verify the signature
+}
+
+func (w *WebhookModel) Create(req *CreateWebhookReq) (*CreateWebhookResp, error) {
+	resp := &CreateWebhookResp{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, req.AppId, "", "", ActionManage); err != nil {
+		return resp, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		b := make([]byte, 24)
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			log.Error(err)
+			return resp, err
+		}
+		secret = hex.EncodeToString(b)
+	}
+	//the secret is kept as it is if no key manager is configured
+	sealed, err := sealValue(secret, kms.Configured())
+	if err != nil {
+		return resp, err
+	}
+	events, _ := json.Marshal(req.Events)
+
+	now := time.Now().Unix()
+	id := uuid.NewV1().String()
+	tx := database.Conn().Begin()
+	tx = database.Insert(tx, "webhook", map[string]interface{}{
+		"id":          id,
+		"app_id":      req.AppId,
+		"url":         req.Url,
+		"secret":      sealed,
+		"events":      events,
+		"is_enabled":  1,
+		"comment":     req.Comment,
+		"create_by":   req.UserId,
+		"create_time": now,
+		"update_by":   req.UserId,
+		"update_time": now,
+	})
+	tx = RecordTable(tx, "webhook", "", req.UserId, req.Request, com.OpCreate, nil, id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return resp, errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+
+	resp.Id = id
+	resp.Secret = secret
+	return resp, nil
+}
+
+type UpdateWebhookReq struct {
+	Id        string         `json:"id"`
+	Url       string         `json:"url"`
+	Events    []WebhookEvent `json:"events"`
+	Secret    string         `json:"secret"` //keep the old secret if empty
+	IsEnabled bool           `json:"is_enabled"`
+	Comment   string         `json:"comment"`
+	UserId    string         `json:"-"`
+	Request   RequestInfo    `json:"-"`
+}
+
+func (c *UpdateWebhookReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Id, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Url, validation.Required, validation.Length(1, 512), validation.By(validateWebhookUrl)),
+		validation.Field(&c.Events, validation.Required, validation.Each(webhookEventRule)),
+		validation.Field(&c.Secret, validation.Length(16, 128)),
+		validation.Field(&c.Comment, validation.Length(1, 255)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+func (w *WebhookModel) Update(req *UpdateWebhookReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+	webhook, err := w.getInfo(req.Id)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, webhook.AppId, "", "", ActionManage); err != nil {
+		return err
+	}
+
+	events, _ := json.Marshal(req.Events)
+	isEnabled := 0
+	if req.IsEnabled {
+		isEnabled = 1
+	}
+	data := map[string]interface{}{
+		"url":         req.Url,
+		"events":      events,
+		"is_enabled":  isEnabled,
+		"comment":     req.Comment,
+		"update_by":   req.UserId,
+		"update_time": time.Now().Unix(),
+	}
+	if req.Secret != "" {
+		sealed, err := sealValue(req.Secret, kms.Configured())
+		if err != nil {
+			return err
+		}
+		data["secret"] = sealed
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "webhook", req.Id)
+	tx = database.Update(tx, "webhook", data, "id=?", req.Id)
+	tx = RecordTable(tx, "webhook", "", req.UserId, req.Request, com.OpUpdate, before, req.Id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+	return nil
+}
+
+type DeleteWebhookReq struct {
+	Id      string      `json:"id"`
+	UserId  string      `json:"-"`
+	Request RequestInfo `json:"-"`
+}
+
+func (c *DeleteWebhookReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Id, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//delete the webhook, the pending deliveries of it are not sent anymore
+func (w *WebhookModel) Delete(req *DeleteWebhookReq) error {
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return err
+	}
+	webhook, err := w.getInfo(req.Id)
+	if err != nil {
+		return err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, webhook.AppId, "", "", ActionManage); err != nil {
+		return err
+	}
+
+	tx := database.Conn().Begin()
+	tx, before := selectRows(tx, "webhook", req.Id)
+	tx = database.Update(tx, "webhook", map[string]interface{}{
+		"is_delete":   1,
+		"update_by":   req.UserId,
+		"update_time": time.Now().Unix(),
+	}, "id=?", req.Id)
+	tx = RecordTable(tx, "webhook", "", req.UserId, req.Request, com.OpDelete, before, req.Id)
+	if tx.Error != nil {
+		tx.Rollback()
+		log.Error(tx.Error)
+		return errors.Wrap(tx.Error, "db error")
+	} else {
+		tx.Commit()
+	}
+	return nil
+}
+
+type WebhookListReq struct {
+	AppId  string `json:"app_id"`
+	UserId string `json:"-"`
+}
+
+func (c *WebhookListReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.AppId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type WebhookItem struct {
+	Id         string         `json:"id"`
+	AppId      string         `json:"app_id"`
+	Url        string         `json:"url"`
+	Events     []WebhookEvent `json:"events"`
+	IsEnabled  int            `json:"is_enabled"`
+	Comment    string         `json:"comment"`
+	CreateBy   string         `json:"create_by"`
+	CreateTime int            `json:"create_time"`
+	UpdateBy   string         `json:"update_by"`
+	UpdateTime int            `json:"update_time"`
+}
+
+type WebhookListResp struct {
+	List []WebhookItem `json:"list"`
+}
+
+//the webhooks of the app, the secrets are never returned
+func (w *WebhookModel) List(req *WebhookListReq) (*WebhookListResp, error) {
+	resp := &WebhookListResp{
+		List: []WebhookItem{},
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, req.AppId, "", "", ActionManage); err != nil {
+		return resp, err
+	}
+
+	var webhooks []struct {
+		Id         string
+		AppId      string
+		Url        string
+		Events     []byte
+		IsEnabled  int
+		Comment    string
+		CreateBy   string
+		CreateTime int
+		UpdateBy   string
+		UpdateTime int
+	}
+	db := database.Conn()
+	db = db.Table("webhook").Select("id,app_id,url,events,is_enabled,comment,create_by,create_time,update_by,update_time").
+		Where("app_id=? AND is_delete=0", req.AppId).Order("create_time").Find(&webhooks)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	for _, h := range webhooks {
+		var events []WebhookEvent
+		_ = json.Unmarshal(h.Events, &events)
+		resp.List = append(resp.List, WebhookItem{
+			Id:         h.Id,
+			AppId:      h.AppId,
+			Url:        h.Url,
+			Events:     events,
+			IsEnabled:  h.IsEnabled,
+			Comment:    h.Comment,
+			CreateBy:   h.CreateBy,
+			CreateTime: h.CreateTime,
+			UpdateBy:   h.UpdateBy,
+			UpdateTime: h.UpdateTime,
+		})
+	}
+	return resp, nil
+}
+
+type WebhookDeliveryListReq struct {
+	WebhookId string         `json:"webhook_id"`
+	Status    DeliveryStatus `json:"status"` //all if empty
+	Limit     int            `json:"limit"`
+	Offset    int            `json:"offset"`
+	UserId    string         `json:"-"`
+}
+
+func (c *WebhookDeliveryListReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.WebhookId, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.Status, validation.In(DeliveryPending, DeliverySuccess, DeliveryFailed)),
+		validation.Field(&c.Limit, validation.Max(100)),
+		validation.Field(&c.Offset, validation.Min(0)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+type WebhookDeliveryItem struct {
+	Id           string         `json:"id"`
+	WebhookId    string         `json:"webhook_id"`
+	Event        WebhookEvent   `json:"event"`
+	Payload      string         `json:"payload"`
+	Status       DeliveryStatus `json:"status"`
+	Attempts     int            `json:"attempts"`
+	NextTime     int            `json:"next_time"` //the time of the next attempt if pending
+	ResponseCode int            `json:"response_code"`
+	Message      string         `json:"message"` //the reason of the last failed attempt
+	CreateTime   int            `json:"create_time"`
+	UpdateTime   int            `json:"update_time"`
+}
+
+type WebhookDeliveryListResp struct {
+	Offset int                   `json:"offset"`
+	Total  int                   `json:"total"`
+	List   []WebhookDeliveryItem `json:"list"`
+}
+
+//the deliveries of the webhook from the newest
+func (w *WebhookModel) DeliveryList(req *WebhookDeliveryListReq) (*WebhookDeliveryListResp, error) {
+	resp := &WebhookDeliveryListResp{
+		List:   []WebhookDeliveryItem{},
+		Offset: -1,
+	}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+	webhook, err := w.getInfo(req.WebhookId)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, webhook.AppId, "", "", ActionManage); err != nil {
+		return resp, err
+	}
+
+	where := "webhook_id=? AND is_delete=0"
+	params := []interface{}{req.WebhookId}
+	if req.Status != "" {
+		where += " AND status=?"
+		params = append(params, req.Status)
+	}
+	db := database.Conn()
+	db = db.Table("webhook_delivery").
+		Select("id,webhook_id,event,payload,status,attempts,next_time,response_code,message,create_time,update_time").
+		Where(where, params...).Order("create_time DESC").Offset(req.Offset).Limit(req.Limit).Find(&resp.List)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	if len(resp.List) < req.Limit {
+		resp.Offset = -1
+	} else {
+		resp.Offset = req.Offset + len(resp.List)
+	}
+
+	db = database.Conn()
+	db = db.Table("webhook_delivery").Where(where, params...).Count(&resp.Total)
+	if db.Error != nil {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	return resp, nil
+}
+
+type TestWebhookReq struct {
+	Id     string `json:"id"`
+	UserId string `json:"-"`
+}
+
+func (c *TestWebhookReq) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Id, validation.Required, validation.Length(36, 36)),
+		validation.Field(&c.UserId, validation.Required, validation.Length(36, 36)),
+	)
+}
+
+//send a ping event to the webhook right now, it is not retried if failed
+func (w *WebhookModel) Test(req *TestWebhookReq) (*WebhookDeliveryItem, error) {
+	resp := &WebhookDeliveryItem{}
+
+	if err := req.Validate(); err != nil {
+		log.Warn(err)
+		return resp, err
+	}
+	webhook, err := w.getInfo(req.Id)
+	if err != nil {
+		return resp, err
+	}
+	permMdl := PermissionModel{}
+	if err := permMdl.check(req.UserId, webhook.AppId, "", "", ActionManage); err != nil {
+		return resp, err
+	}
+
+	now := int(time.Now().Unix())
+	payload := &WebhookPayload{
+		Event:    EventPing,
+		Env:      core.GetServer().Env,
+		AppId:    webhook.AppId,
+		Operator: req.UserId,
+		Time:     now,
+		Data: map[string]interface{}{
+			"webhook_id": webhook.Id,
+		},
+	}
+	userMdl := UserModel{}
+	if user, err := userMdl.Info(req.UserId); err == nil {
+		payload.OperatorName = user.Username
+	}
+	delivery := w.newDelivery(webhook, payload, now)
+	_, err = w.send(webhook, delivery["id"].(string), EventPing, delivery["payload"].(string))
+
+	//the response of the url is neither kept nor returned, the test should not probe the urls
+	delivery["attempts"] = 1
+	delivery["status"] = DeliverySuccess
+	if err != nil {
+		delivery["status"] = DeliveryFailed
+		delivery["message"] = "the ping is not delivered"
+	}
+	db := database.Insert(database.Conn(), "webhook_delivery", delivery)
+	if db.Error != nil {
+		log.Error(db.Error)
+		return resp, errors.Wrap(db.Error, "db error")
+	}
+
+	resp.Id = delivery["id"].(string)
+	resp.WebhookId = webhook.Id
+	resp.Event = EventPing
+	resp.Payload = delivery["payload"].(string)
+	resp.Status = delivery["status"].(DeliveryStatus)
+	resp.Attempts = 1
+	if message, ok := delivery["message"].(string); ok {
+		resp.Message = message
+	}
+	resp.CreateTime = now
+	resp.UpdateTime = now
+	return resp, nil
+}
+
+//the body of the delivery request
+type WebhookPayload struct {
+	Id            string       `json:"id"` //the delivery id, the same as the header
+	Event         WebhookEvent `json:"event"`
+	Env           com.EnvType  `json:"env"`
+	AppId         string       `json:"app_id"`
+	AppName       string       `json:"app_name"`
+	ClusterId     string       `json:"cluster_id"`
+	ClusterName   string       `json:"cluster_name"`
+	NamespaceId   string       `json:"namespace_id"`
+	NamespaceName string       `json:"namespace_name"`
+	ReleaseId     string       `json:"release_id"`
+	Operator      string       `json:"operator"`
+	OperatorName  string       `json:"operator_name"`
+	Time          int          `json:"time"`
+	Data          interface{}  `json:"data"` //the detail of the event, never contains any value of the items
+}
+
+//queue the event of the namespace to the webhooks of the app subscribing it, the failure is only logged
+//as the operation is done already
+func (w *WebhookModel) fire(namespace *namespaceInfo, event WebhookEvent, releaseId, userId string, data interface{}) {
+	var webhooks []webhookInfo
+	db := database.Conn()
+	db = db.Table("webhook").Select("id,app_id,url,secret,events,is_enabled").
+		Where("app_id=? AND is_enabled=1 AND is_delete=0", namespace.AppId).Find(&webhooks)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return
+	}
+
+	var subscribed []*webhookInfo
+	for i := range webhooks {
+		var events []WebhookEvent
+		if err := json.Unmarshal(webhooks[i].Events, &events); err != nil {
+			log.Error(err)
+			continue
+		}
+		for _, e := range events {
+			if e == event {
+				subscribed = append(subscribed, &webhooks[i])
+				break
+			}
+		}
+	}
+	if len(subscribed) == 0 {
+		return
+	}
+
+	now := int(time.Now().Unix())
+	payload := &WebhookPayload{
+		Event:         event,
+		Env:           core.GetServer().Env,
+		AppId:         namespace.AppId,
+		ClusterId:     namespace.ClusterId,
+		NamespaceId:   namespace.Id,
+		NamespaceName: namespace.Name,
+		ReleaseId:     releaseId,
+		Operator:      userId,
+		Time:          now,
+		Data:          data,
+	}
+	cfgMdl := ConfigModel{}
+	if names, err := cfgMdl.peerConfigReq(namespace, "", false); err == nil {
+		payload.AppName = names.AppName
+		payload.ClusterName = names.ClusterName
+	}
+	userMdl := UserModel{}
+	if user, err := userMdl.Info(userId); err == nil {
+		payload.OperatorName = user.Username
+	}
+
+	var deliveries []map[string]interface{}
+	for _, webhook := range subscribed {
+		deliveries = append(deliveries, w.newDelivery(webhook, payload, now))
+	}
+	db = database.InsertMany(database.Conn(), "webhook_delivery", deliveries)
+	if db.Error != nil {
+		log.Error(db.Error)
+		return
+	}
+}
+
+//the row of the pending delivery of the payload to the webhook
+func (w *WebhookModel) newDelivery(webhook *webhookInfo, payload *WebhookPayload, now int) map[string]interface{} {
+	id := uuid.NewV1().String()
+	p := *payload
+	p.Id = id
+	body, _ := json.Marshal(&p)
+	return map[string]interface{}{
+		"id":          id,
+		"webhook_id":  webhook.Id,
+		"app_id":      webhook.AppId,
+		"event":       p.Event,
+		"payload":     string(body),
+		"status":      DeliveryPending,
+		"next_time":   now,
+		"create_time": now,
+		"update_time": now,
+	}
+}
+
+//send the deliveries due now, those of a server which has been down are taken over after the lease expires
+func (w *WebhookModel) DispatchDue(serverId string) {
+	now := time.Now().Unix()
+	var deliveries []struct {
+		Id string
+	}
+	db := database.Conn()
+	db = db.Table("webhook_delivery").Select("id").
+		Where("status=? AND next_time<=? AND lease_expire<? AND is_delete=0", DeliveryPending, now, now).
+		Order("next_time").Limit(100).Find(&deliveries)
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		log.Error(db.Error)
+		return
+	}
+
+	for _, item := range deliveries {
+		if w.claim(item.Id, serverId) {
+			w.deliver(item.Id)
+		}
+	}
+}
+
+//take the delivery by the lease, only one server succeeds
+func (w *WebhookModel) claim(deliveryId, serverId string) bool {
+	now := time.Now().Unix()
+	db := database.Conn()
+	db = db.Table("webhook_delivery").
+		Where("id=? AND status=? AND lease_expire<?", deliveryId, DeliveryPending, now).
+		Updates(map[string]interface{}{
+			"lease_owner":  serverId,
+			"lease_expire": now + webhookLease,
+		})
+	if db.Error != nil {
+		log.Error(db.Error)
+		return false
+	}
+	return db.RowsAffected > 0
+}
+
+//make an attempt of the delivery, it is retried later if failed until no retry left
+func (w *WebhookModel) deliver(deliveryId string) {
+	var delivery struct {
+		Id        string
+		WebhookId string
+		Event     WebhookEvent
+		Payload   string
+		Attempts  int
+	}
+	db := database.Conn()
+	db = db.Table("webhook_delivery").Select("id,webhook_id,event,payload,attempts").Where("id=?", deliveryId).Scan(&delivery)
+	if db.Error != nil {
+		log.Error(db.Error)
+		return
+	}
+
+	now := time.Now().Unix()
+	data := map[string]interface{}{
+		"lease_owner":  "",
+		"lease_expire": 0,
+		"update_time":  now,
+	}
+	webhook, err := w.getInfo(delivery.WebhookId)
+	if err == nil && webhook.IsEnabled != 1 {
+		err = errors.New("the webhook is disabled")
+	}
+	if err != nil {
+		data["status"] = DeliveryFailed
+		data["message"] = err.Error()
+	} else {
+		code, err := w.send(webhook, delivery.Id, delivery.Event, delivery.Payload)
+		attempts := delivery.Attempts + 1
+		data["attempts"] = attempts
+		data["response_code"] = code
+		data["message"] = ""
+		if err == nil {
+			data["status"] = DeliverySuccess
+		} else if attempts > len(webhookRetryDelays) {
+			data["status"] = DeliveryFailed
+			data["message"] = deliveryMessage(err)
+		} else {
+			data["next_time"] = now + int64(webhookRetryDelays[attempts-1])
+			data["message"] = deliveryMessage(err)
+		}
+	}
+
+	db = database.Update(database.Conn(), "webhook_delivery", data, "id=?", delivery.Id)
+	if db.Error != nil {
+		log.Error(db.Error)
+	}
+}
+
+//post the payload signed by the secret of the webhook, succeed only if the response code is 2xx
+func (w *WebhookModel) send(webhook *webhookInfo, deliveryId string, event WebhookEvent, payload string) (int, error) {
+	secret, err := openValue(webhook.Secret)
+	if err != nil {
+		return 0, err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader([]byte(payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, string(event))
+	req.Header.Set(WebhookDeliveryHeader, deliveryId)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		log.Warnf("webhook[%s] delivery[%s]: %s", webhook.Id, deliveryId, err)
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 1<<20))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		log.Warnf("webhook[%s] delivery[%s]: response %s", webhook.Id, deliveryId, res.Status)
+		return res.StatusCode, fmt.Errorf("response %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+//the reason of the failed attempt kept in the delivery
+func deliveryMessage(err error) string {
+	message := err.Error()
+	if len(message) > 1024 {
+		message = message[:1024]
+	}
+	return message
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckWebhookIp(t *testing.T) {
+	tests := []struct {
+		ip string
+		ok bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.32.0.1", true},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"198.18.0.1", false},
+		{"198.20.0.1", true},
+		{"64:ff9b::7f00:1", false},
+		{"64:ff9b:1::a00:1", false},
+		{"2002:7f00:1::1", false},
+	}
+	for _, tt := range tests {
+		if err := checkWebhookIp(net.ParseIP(tt.ip)); (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v", tt.ip, err)
+		}
+	}
+}
+
+func TestValidateWebhookUrl(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://example.com/hook", true},
+		{"http://example.com:8080/hook", true},
+		{"https://8.8.8.8/hook", true},
+		{"ftp://example.com/hook", false},
+		{"/hook", false},
+		{"http://:80/hook", false},
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+	}
+	for _, tt := range tests {
+		if err := validateWebhookUrl(tt.url); (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v", tt.url, err)
+		}
+	}
+}
+
+func TestWebhookClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	//the host is resolved to the loopback address when dialing
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	if _, err := webhookClient.Post(url, "application/json", nil); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("the loopback address should be refused, got %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/hook", nil)
+	if err := webhookClient.CheckRedirect(req, []*http.Request{req}); err != http.ErrUseLastResponse {
+		t.Errorf("the redirect should not be followed, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package peer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/hackbeex/configcenter/local"
+	"github.com/hackbeex/configcenter/util"
+	"github.com/hackbeex/configcenter/util/com"
+	"github.com/hackbeex/configcenter/util/log"
+	"github.com/hackbeex/configcenter/util/response"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+//the servers of different envs call each other with the shared token in this header
+const TokenHeader = "X-Peer-Token"
+
+//the login token of the acting user on the server called, the user is authenticated by the env itself
+const UserTokenHeader = "X-Peer-User-Token"
+
+const defaultTimeout = 10
+
+type serverInfo struct {
+	Id     string        `json:"id"`
+	Host   string        `json:"host"`
+	Port   int           `json:"port"`
+	Env    com.EnvType   `json:"env"`
+	Status com.RunStatus `json:"status"`
+}
+
+func CheckToken(token string) error {
+	expect := local.Conf.Server.Peer.Token
+	if expect == "" {
+		return errors.New("peer api is disabled")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expect)) != 1 {
+		return errors.New("invalid peer token")
+	}
+	return nil
+}
+
+//the digest of the secret, the same secret has the same digest in all envs sharing the token,
+//so that the secrets can be compared without leaving the server
+func Digest(value string) string {
+	mac := hmac.New(sha256.New, []byte("configcenter peer:"+local.Conf.Server.Peer.Token))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func transportKey() []byte {
+	key := sha256.Sum256([]byte("configcenter peer secret:" + local.Conf.Server.Peer.Token))
+	return key[:]
+}
+
+//encrypt the secret sent to the server of another env, which may not share the key manager
+func Seal(plaintext string) (string, error) {
+	ciphertext, err := util.AesGcmEncrypt(transportKey(), []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func Open(sealed string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid peer secret")
+	}
+	plaintext, err := util.AesGcmDecrypt(transportKey(), ciphertext)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid peer secret")
+	}
+	return string(plaintext), nil
+}
+
+//find the online server of the env from the discover server
+func findServer(env com.EnvType) (serverInfo, error) {
+	var svr serverInfo
+	discover := local.Conf.Discover
+	url := fmt.Sprintf("http://%s:%d/api/v1/discover/server/fetch", discover.ListenHost, discover.ListenPort)
+	res, err := util.HttpPostJson(url, nil)
+	if err != nil {
+		log.Error(err)
+		return svr, err
+	}
+	defer res.Body.Close()
+
+	var listResp struct {
+		response.BaseResult
+		Data struct {
+			List []serverInfo `json:"list"`
+		} `json:"data"`
+	}
+	if err := util.HttpParseResponseToJson(res, &listResp); err != nil {
+		return svr, err
+	}
+	for _, item := range listResp.Data.List {
+		if item.Env == env && item.Status == com.OnlineStatus {
+			return item, nil
+		}
+	}
+	return svr, errors.Errorf("no server of %s env online", env)
+}
+
+//post the request to the peer api of the server of the env on behalf of the user logged in there by the user token,
+//data is the data field of the response
+func Call(env com.EnvType, path, userToken string, req interface{}, data interface{}) error {
+	conf := local.Conf.Server.Peer
+	if conf.Token == "" {
+		return errors.New("peer token is not configured")
+	}
+	svr, err := findServer(env)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("http://%s:%d%s", svr.Host, svr.Port, path)
+	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(TokenHeader, conf.Token)
+	httpReq.Header.Set(UserTokenHeader, userToken)
+
+	timeout := conf.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	client := http.Client{Timeout: time.Duration(timeout) * time.Second}
+	res, err := client.Do(httpReq)
+	if err != nil {
+		log.Warnf("call %s server[%s] fail: %s", env, url, err)
+		return errors.Wrapf(err, "call %s server", env)
+	}
+	defer res.Body.Close()
+
+	raw, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		response.BaseResult
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		log.Warnf("invalid response of %s server[%s]: %s", env, url, err)
+		return errors.Errorf("invalid response of %s server", env)
+	}
+	if result.Code != http.StatusOK {
+		return errors.Errorf("%s server: %s", env, result.Message)
+	}
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(result.Data, data)
+}
@@ -44,3 +44,12 @@ func OK(c *gin.Context) {
 func Data(c *gin.Context, data interface{}) {
 	JSON(c, data, nil)
 }
+
+//stop the request with the code, e.g. 401 when not login
+func Abort(c *gin.Context, code int, err error) {
+	c.AbortWithStatusJSON(http.StatusOK, Result{
+		Code:    code,
+		Message: err.Error(),
+		Data:    map[string]string{},
+	})
+}
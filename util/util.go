@@ -2,6 +2,9 @@ package util
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/json"
 	"github.com/hackbeex/configcenter/util/log"
 	"github.com/pkg/errors"
@@ -46,6 +49,19 @@ func HttpPostJson(url string, data []byte) (*http.Response, error) {
 	return http.Post(url, "application/json", bytes.NewReader(data))
 }
 
+func HttpPostJsonWithHeader(url string, data []byte, header map[string]string) (*http.Response, error) {
+	log.Debugf("request url[%s], data[%s]", url, string(data))
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return http.DefaultClient.Do(req)
+}
+
 func HttpParseResponseToJson(res *http.Response, resp interface{}) error {
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
@@ -60,3 +76,36 @@ func HttpParseResponseToJson(res *http.Response, resp interface{}) error {
 	}
 	return nil
 }
+
+//encrypt by AES-GCM with the 16, 24 or 32 bytes key, the random nonce is put before the ciphertext
+func AesGcmEncrypt(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func AesGcmDecrypt(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce := ciphertext[:gcm.NonceSize()]
+	return gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], nil)
+}